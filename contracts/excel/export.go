@@ -10,6 +10,11 @@ type FileStorage interface {
 	Url(fileKey string) string
 }
 
+// ReadStorage 读取导出模板、图片等文件的存储，storage.FileSystem 已实现
+type ReadStorage interface {
+	GetStream(ctx context.Context, file string) (io.ReadCloser, error)
+}
+
 // Exporter 导出接口
 type Exporter interface {
 	// Export 导出到本地文件，返回本地文件路径
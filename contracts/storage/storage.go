@@ -76,3 +76,73 @@ type FileSystem interface {
 	// Url get the URL for the file at the given path.
 	Url(file string) string
 }
+
+// Signer 生成预签名地址，浏览器使用该地址直接上传、下载文件，FileSystem可选实现
+type Signer interface {
+	// PresignGet 下载地址，ttl后失效
+	PresignGet(ctx context.Context, file string, ttl time.Duration) (string, error)
+	// PresignPut 上传地址，ttl后失效，上传时请求头Content-Type需与contentType一致
+	PresignPut(ctx context.Context, file string, ttl time.Duration, contentType string) (string, error)
+}
+
+// UploadPart 已上传的分片
+type UploadPart struct {
+	Number int    `json:"number"` //分片序号，从1开始
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// MultipartUploader 分片上传，保存 InitUpload 返回的uploadId即可在中断后通过 ListParts 查询已上传分片并继续上传，FileSystem可选实现
+type MultipartUploader interface {
+	// InitUpload 初始化分片上传，返回uploadId
+	InitUpload(ctx context.Context, file string, contentType string) (string, error)
+	// UploadPart 上传分片，number从1开始，除最后一个分片外，分片大小不能小于5MB
+	UploadPart(ctx context.Context, file, uploadId string, number int, r io.Reader, size int64) (UploadPart, error)
+	// ListParts 已上传的分片，按序号排序
+	ListParts(ctx context.Context, file, uploadId string) ([]UploadPart, error)
+	// CompleteUpload 按序号合并分片
+	CompleteUpload(ctx context.Context, file, uploadId string, parts []UploadPart) error
+	// AbortUpload 取消上传并删除已上传的分片
+	AbortUpload(ctx context.Context, file, uploadId string) error
+}
+
+// PutOptions 上传时设置的对象元信息，为空的字段不设置
+type PutOptions struct {
+	ContentType        string            `json:"content_type"` //为空时按后缀或内容判断
+	ContentDisposition string            `json:"content_disposition"`
+	CacheControl       string            `json:"cache_control"`
+	Metadata           map[string]string `json:"metadata"`      //自定义元信息，key不区分大小写
+	StorageClass       string            `json:"storage_class"` //存储类型，取值与各云存储一致，如 STANDARD、STANDARD_IA
+	ACL                string            `json:"acl"`           //访问权限，如 private、public-read
+}
+
+// ObjectInfo 对象信息
+type ObjectInfo struct {
+	Key                string            `json:"key"`
+	Size               int64             `json:"size"`
+	ContentType        string            `json:"content_type"`
+	ContentDisposition string            `json:"content_disposition"`
+	CacheControl       string            `json:"cache_control"`
+	ETag               string            `json:"etag"` //不含引号
+	LastModified       time.Time         `json:"last_modified"`
+	StorageClass       string            `json:"storage_class"`
+	Metadata           map[string]string `json:"metadata"` //key为小写
+}
+
+// Metadata 上传时设置对象元信息及查询对象信息，FileSystem可选实现
+type Metadata interface {
+	PutWithOptions(ctx context.Context, file string, r io.Reader, opts PutOptions) error
+	Stat(ctx context.Context, file string) (*ObjectInfo, error)
+}
+
+// RangeGetter 范围读取，FileSystem可选实现
+type RangeGetter interface {
+	// GetRange 从offset开始读取length字节，length<=0时读取到文件末尾，offset不小于文件大小时返回 ErrInvalidRange（空文件offset为0时返回空内容）
+	GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error)
+}
+
+// CredentialsProvider 签发临时凭证，客户端使用临时凭证直接上传文件
+type CredentialsProvider interface {
+	// Credentials 只能上传到prefix目录下的临时凭证，prefix不能为根目录
+	Credentials(ctx context.Context, prefix string) (*Credentials, error)
+}
@@ -0,0 +1,248 @@
+package export
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldSeparator 嵌套字段路径分隔符，如 user.address.city
+const FieldSeparator = "."
+
+// methodSuffix 字段以()结尾表示调用无参方法取值，如 FullName()
+const methodSuffix = "()"
+
+// accessor 字段取值器，取不到值时返回无效的reflect.Value
+type accessor func(v reflect.Value) reflect.Value
+
+type accessorKey struct {
+	typ  reflect.Type
+	path string
+}
+
+// accessorCache 按类型+字段路径缓存编译好的取值器，避免每行数据重复反射解析
+var accessorCache sync.Map
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// getAccessor 获取类型typ上字段路径path的取值器
+func getAccessor(typ reflect.Type, path string) accessor {
+	key := accessorKey{typ: typ, path: path}
+	if fn, ok := accessorCache.Load(key); ok {
+		return fn.(accessor)
+	}
+	fn, _ := accessorCache.LoadOrStore(key, compileAccessor(typ, path))
+	return fn.(accessor)
+}
+
+func compileAccessor(typ reflect.Type, path string) accessor {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		next := compileMethod(typ, path)
+		if next == nil {
+			next = chainAccessor(reflect.Value.Elem, getAccessor(typ.Elem(), path))
+		}
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				return nilValue
+			}
+			return next(v)
+		}
+	case reflect.Interface:
+		return func(v reflect.Value) reflect.Value {
+			if v.IsNil() {
+				return nilValue
+			}
+			v = v.Elem()
+			return getAccessor(v.Type(), path)(v)
+		}
+	case reflect.Map:
+		return compileMapAccessor(typ, path)
+	case reflect.Struct:
+		return compileStructAccessor(typ, path)
+	case reflect.Slice, reflect.Array:
+		return compileIndexAccessor(typ, path)
+	default:
+		if fn := compileMethod(typ, path); fn != nil {
+			return fn
+		}
+		return nilAccessor
+	}
+}
+
+// compileMapAccessor map优先使用完整路径作为key，取不到再按路径逐级查找
+func compileMapAccessor(typ reflect.Type, path string) accessor {
+	if fn := compileMethod(typ, path); fn != nil {
+		return fn
+	}
+	if typ.Key().Kind() != reflect.String {
+		return nilAccessor
+	}
+	keyType := typ.Key()
+	fullKey := reflect.ValueOf(path).Convert(keyType)
+	name, rest, nested := strings.Cut(path, FieldSeparator)
+	if !nested {
+		return func(v reflect.Value) reflect.Value {
+			return v.MapIndex(fullKey)
+		}
+	}
+	nameKey := reflect.ValueOf(name).Convert(keyType)
+	next := getAccessor(typ.Elem(), rest)
+	return func(v reflect.Value) reflect.Value {
+		if val := v.MapIndex(fullKey); val.IsValid() {
+			return val
+		}
+		val := v.MapIndex(nameKey)
+		if !val.IsValid() {
+			return nilValue
+		}
+		return next(val)
+	}
+}
+
+// compileStructAccessor 结构体字段按export tag匹配，没有tag时按字段名匹配
+func compileStructAccessor(typ reflect.Type, path string) accessor {
+	if idx, ok := structFieldIndex(typ, path); ok {
+		return func(v reflect.Value) reflect.Value {
+			return v.Field(idx)
+		}
+	}
+	if fn := compileMethod(typ, path); fn != nil {
+		return fn
+	}
+	name, rest, nested := strings.Cut(path, FieldSeparator)
+	if !nested {
+		return nilAccessor
+	}
+	if fn := compileMethod(typ, name); fn != nil {
+		next := getAccessor(methodResultType(typ, name), rest)
+		return chainAccessor(fn, next)
+	}
+	idx, ok := structFieldIndex(typ, name)
+	if !ok {
+		return nilAccessor
+	}
+	next := getAccessor(typ.Field(idx).Type, rest)
+	return func(v reflect.Value) reflect.Value {
+		return next(v.Field(idx))
+	}
+}
+
+// compileIndexAccessor 数组/切片按下标取值，如 items.0.name
+func compileIndexAccessor(typ reflect.Type, path string) accessor {
+	if fn := compileMethod(typ, path); fn != nil {
+		return fn
+	}
+	name, rest, nested := strings.Cut(path, FieldSeparator)
+	i, err := strconv.Atoi(name)
+	if err != nil || i < 0 {
+		return nilAccessor
+	}
+	fn := func(v reflect.Value) reflect.Value {
+		if i >= v.Len() {
+			return nilValue
+		}
+		return v.Index(i)
+	}
+	if !nested {
+		return fn
+	}
+	return chainAccessor(fn, getAccessor(typ.Elem(), rest))
+}
+
+// compileMethod 编译无参方法调用，方法可以返回(value)或(value, error)
+func compileMethod(typ reflect.Type, path string) accessor {
+	name, ok := strings.CutSuffix(path, methodSuffix)
+	if !ok || strings.Contains(name, FieldSeparator) {
+		return nil
+	}
+	if m, ok := typ.MethodByName(name); ok && isGetter(m.Type) {
+		idx := m.Index
+		return func(v reflect.Value) reflect.Value {
+			return callGetter(v.Method(idx))
+		}
+	}
+	if typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Interface {
+		return nil
+	}
+	//指针接收者的方法
+	m, ok := reflect.PointerTo(typ).MethodByName(name)
+	if !ok || !isGetter(m.Type) {
+		return nil
+	}
+	idx := m.Index
+	return func(v reflect.Value) reflect.Value {
+		if !v.CanAddr() {
+			ptr := reflect.New(typ)
+			ptr.Elem().Set(v)
+			v = ptr.Elem()
+		}
+		return callGetter(v.Addr().Method(idx))
+	}
+}
+
+// methodResultType 方法返回值类型，调用前需确保compileMethod成功
+func methodResultType(typ reflect.Type, name string) reflect.Type {
+	name = strings.TrimSuffix(name, methodSuffix)
+	if m, ok := typ.MethodByName(name); ok {
+		return m.Type.Out(0)
+	}
+	m, _ := reflect.PointerTo(typ).MethodByName(name)
+	return m.Type.Out(0)
+}
+
+// isGetter 判断方法是否无参(仅接收者)且返回(value)或(value, error)
+func isGetter(mt reflect.Type) bool {
+	if mt.NumIn() != 1 {
+		return false
+	}
+	switch mt.NumOut() {
+	case 1:
+		return true
+	case 2:
+		return mt.Out(1) == errorType
+	default:
+		return false
+	}
+}
+
+func callGetter(m reflect.Value) reflect.Value {
+	out := m.Call(nil)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nilValue
+	}
+	return out[0]
+}
+
+func chainAccessor(fn, next accessor) accessor {
+	return func(v reflect.Value) reflect.Value {
+		val := fn(v)
+		if !val.IsValid() {
+			return nilValue
+		}
+		return next(val)
+	}
+}
+
+func nilAccessor(reflect.Value) reflect.Value {
+	return nilValue
+}
+
+// structFieldIndex 查找导出字段下标，有export tag的字段只按tag匹配
+func structFieldIndex(typ reflect.Type, name string) (int, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := field.Name
+		if k, ok := field.Tag.Lookup(TagName); ok {
+			key = k
+		}
+		if key == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
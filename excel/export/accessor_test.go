@@ -0,0 +1,65 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testAddress struct {
+	City string `export:"city"`
+}
+
+type testUser struct {
+	First   string
+	Last    string
+	Address *testAddress `export:"address"`
+	Tags    []string
+	Extra   map[string]any
+}
+
+func (u testUser) FullName() string {
+	return u.First + " " + u.Last
+}
+
+func (u *testUser) Initials() string {
+	return u.First[:1] + u.Last[:1]
+}
+
+func TestAccessor(t *testing.T) {
+	u := testUser{
+		First:   "Ada",
+		Last:    "Lovelace",
+		Address: &testAddress{City: "London"},
+		Tags:    []string{"math", "poetry"},
+		Extra:   map[string]any{"team": map[string]any{"name": "engine"}},
+	}
+	for _, test := range []struct {
+		row      any
+		path     string
+		expected any
+	}{
+		{u, "First", "Ada"},
+		{u, "address.city", "London"},
+		{&u, "address.city", "London"},
+		{u, "FullName()", "Ada Lovelace"},
+		{u, "Initials()", "AL"},
+		{&u, "Initials()", "AL"},
+		{u, "Tags.1", "poetry"},
+		{u, "Tags.5", nil},
+		{u, "Extra.team.name", "engine"},
+		{u, "Missing.path", nil},
+		{testUser{}, "address.city", nil},
+		{map[string]any{"user": u}, "user.address.city", "London"},
+		{map[string]any{"user.name": "flat"}, "user.name", "flat"},
+		{map[string]any{"user": &u}, "user.FullName()", "Ada Lovelace"},
+	} {
+		val := getAccessor(reflect.TypeOf(test.row), test.path)(reflect.ValueOf(test.row))
+		var actual any
+		if val.IsValid() {
+			actual = val.Interface()
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %#v but got %#v", test.path, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/spf13/cast"
+	"github.com/xuri/excelize/v2"
+)
+
+// Aggregate 列汇总方式
+type Aggregate int
+
+const (
+	AggregateNone  Aggregate = iota //不汇总
+	AggregateSum                    //求和
+	AggregateAvg                    //平均值
+	AggregateCount                  //非空单元格计数
+	AggregateMin                    //最小值
+	AggregateMax                    //最大值
+)
+
+// DefaultTotalTitle DefaultSubtotalTitle 合计行、小计行默认标题
+const DefaultTotalTitle = "合计"
+const DefaultSubtotalTitle = "小计"
+
+// subtotalFunction excel SUBTOTAL函数编号，SUBTOTAL会忽略区域内其他SUBTOTAL单元格，合计行不会重复计算小计行
+func (a Aggregate) subtotalFunction() int {
+	switch a {
+	case AggregateSum:
+		return 9
+	case AggregateAvg:
+		return 1
+	case AggregateCount:
+		return 3
+	case AggregateMin:
+		return 5
+	case AggregateMax:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// aggregateStat 单列汇总数据
+type aggregateStat struct {
+	sum   float64
+	nums  int //数值个数
+	count int //非空个数
+	min   float64
+	max   float64
+}
+
+func (s *aggregateStat) add(v any) {
+	switch v.(type) {
+	case Image, *Image:
+		return
+	}
+	v, _ = richText(v)
+	if c, ok := v.(excelize.Cell); ok {
+		v = c.Value
+	} else if c, ok := v.(*excelize.Cell); ok && c != nil {
+		v = c.Value
+	}
+	v = normalizeValue(v)
+	if v == nil || v == "" {
+		return
+	}
+	s.count++
+	n, err := cast.ToFloat64E(v)
+	if err != nil {
+		return
+	}
+	if s.nums == 0 || n < s.min {
+		s.min = n
+	}
+	if s.nums == 0 || n > s.max {
+		s.max = n
+	}
+	s.nums++
+	s.sum += n
+}
+
+// value 汇总结果，没有数值时返回nil
+func (s *aggregateStat) value(a Aggregate) any {
+	if a == AggregateCount {
+		return s.count
+	}
+	if s.nums == 0 {
+		return nil
+	}
+	switch a {
+	case AggregateSum:
+		return s.sum
+	case AggregateAvg:
+		return s.sum / float64(s.nums)
+	case AggregateMin:
+		return s.min
+	case AggregateMax:
+		return s.max
+	default:
+		return nil
+	}
+}
+
+// aggregator 导出数据时逐行汇总，每个导出文件单独汇总
+type aggregator struct {
+	columns       *columns
+	formatter     *cellFormatter
+	totalTitle    string
+	subtotalTitle string
+	groupIdx      int //分组列，-1表示不分组
+	groupKey      string
+	grouped       bool //是否已有分组数据
+	groupFirst    int  //当前分组第一行
+	total         []aggregateStat
+	group         []aggregateStat
+}
+
+// newAggregator 没有配置汇总列时返回nil
+func newAggregator(c *columns, f *cellFormatter, opt *options) *aggregator {
+	enabled := false
+	for _, h := range c.headers {
+		if h.Aggregate != AggregateNone {
+			enabled = true
+			break
+		}
+	}
+	if !enabled {
+		return nil
+	}
+	a := &aggregator{
+		columns:       c,
+		formatter:     f,
+		totalTitle:    opt.totalTitle,
+		subtotalTitle: opt.subtotalTitle,
+		groupIdx:      -1,
+		total:         make([]aggregateStat, c.nums),
+		group:         make([]aggregateStat, c.nums),
+	}
+	if idx, ok := c.keyIndex[opt.groupBy]; ok && opt.groupBy != "" {
+		a.groupIdx = idx
+	}
+	return a
+}
+
+// groupChanged 判断行数据的分组是否变化，变化时需要先输出上一分组的小计行再调用enterGroup
+func (a *aggregator) groupChanged(rowData reflect.Value) (string, bool) {
+	if a.groupIdx < 0 {
+		return "", false
+	}
+	key := cast.ToString(normalizeValue(a.columns.fieldValue(rowData, a.groupIdx)))
+	return key, a.grouped && key != a.groupKey
+}
+
+// enterGroup 进入分组，row为该行所在行数
+func (a *aggregator) enterGroup(key string, row int) {
+	if a.groupIdx < 0 || (a.grouped && key == a.groupKey) {
+		return
+	}
+	a.grouped = true
+	a.groupKey = key
+	a.groupFirst = row
+	a.group = make([]aggregateStat, a.columns.nums)
+}
+
+// add 汇总一行数据
+func (a *aggregator) add(values []any) {
+	for i, h := range a.columns.headers {
+		if h.Aggregate == AggregateNone {
+			continue
+		}
+		a.total[i].add(values[i])
+		if a.groupIdx >= 0 {
+			a.group[i].add(values[i])
+		}
+	}
+}
+
+// labelIndex 标题所在列，优先使用preferred，该列需汇总时使用第一个不汇总的列
+func (a *aggregator) labelIndex(preferred int) int {
+	if preferred >= 0 && a.columns.headers[preferred].Aggregate == AggregateNone {
+		return preferred
+	}
+	for i, h := range a.columns.headers {
+		if h.Aggregate == AggregateNone {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *aggregator) subtotalLabel() string {
+	if a.groupKey == "" {
+		return a.subtotalTitle
+	}
+	return a.groupKey + " " + a.subtotalTitle
+}
+
+// excelRow 生成excel汇总行，单元格为SUBTOTAL公式并带上计算好的值
+func (a *aggregator) excelRow(stats []aggregateStat, label string, labelIdx, firstRow, lastRow, colStart int, cellStyles []int) ([]any, error) {
+	values := make([]any, a.columns.nums)
+	if labelIdx >= 0 {
+		values[labelIdx] = label
+	}
+	for i, h := range a.columns.headers {
+		fn := h.Aggregate.subtotalFunction()
+		if fn == 0 {
+			continue
+		}
+		colName, err := excelize.ColumnNumberToName(colStart + i)
+		if err != nil {
+			return nil, err
+		}
+		cell := excelize.Cell{
+			Formula: fmt.Sprintf("SUBTOTAL(%d,%s%d:%s%d)", fn, colName, firstRow, colName, lastRow),
+			Value:   stats[i].value(h.Aggregate),
+		}
+		if h.Aggregate != AggregateCount {
+			cell.StyleID = cellStyles[i]
+		}
+		values[i] = cell
+	}
+	return values, nil
+}
+
+// excelSubtotalRow 当前分组的excel小计行，lastRow为分组最后一行
+func (a *aggregator) excelSubtotalRow(lastRow, colStart int, cellStyles []int) ([]any, error) {
+	return a.excelRow(a.group, a.subtotalLabel(), a.labelIndex(a.groupIdx), a.groupFirst, lastRow, colStart, cellStyles)
+}
+
+// excelTotalRow excel合计行，firstRow、lastRow为数据所在区域
+func (a *aggregator) excelTotalRow(firstRow, lastRow, colStart int, cellStyles []int) ([]any, error) {
+	return a.excelRow(a.total, a.totalTitle, a.labelIndex(-1), firstRow, lastRow, colStart, cellStyles)
+}
+
+// textRow 生成文本格式汇总行，直接写入计算好的值
+func (a *aggregator) textRow(stats []aggregateStat, label string, labelIdx int) []string {
+	values := make([]string, a.columns.nums)
+	if labelIdx >= 0 {
+		values[labelIdx] = label
+	}
+	for i, h := range a.columns.headers {
+		switch h.Aggregate {
+		case AggregateNone:
+		case AggregateCount:
+			values[i] = strconv.Itoa(stats[i].count)
+		default:
+			v := stats[i].value(h.Aggregate)
+			if f, ok := v.(float64); ok && h.CellType == CellTypeDefault {
+				v = roundFloat(f)
+			}
+			values[i] = a.formatter.textValue(h, v)
+		}
+	}
+	return values
+}
+
+func (a *aggregator) textSubtotalRow() []string {
+	return a.textRow(a.group, a.subtotalLabel(), a.labelIndex(a.groupIdx))
+}
+
+func (a *aggregator) textTotalRow() []string {
+	return a.textRow(a.total, a.totalTitle, a.labelIndex(-1))
+}
+
+// roundFloat 去掉浮点数累加产生的误差，如 0.1+0.2
+func roundFloat(f float64) float64 {
+	return math.Round(f*1e10) / 1e10
+}
@@ -0,0 +1,89 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+	"github.com/xuri/excelize/v2"
+)
+
+// aggregateHeaders 按部门分组，其中部门为空的分组没有金额
+func aggregateHeaders() (Headers, []any) {
+	h := Headers{
+		{Field: "dept", Title: "部门"},
+		{Field: "name", Title: "姓名", Aggregate: AggregateCount},
+		{Field: "amount", Title: "金额", Aggregate: AggregateSum},
+		{Field: "amount", Title: "平均", Aggregate: AggregateAvg},
+	}
+	data := []any{
+		map[string]any{"dept": "A", "name": "a1", "amount": 0.1},
+		map[string]any{"dept": "A", "name": "a2", "amount": 0.2},
+		map[string]any{"dept": "", "name": "", "amount": nil},
+		map[string]any{"dept": "B", "name": "b1", "amount": 3},
+	}
+	return h, data
+}
+
+func TestAggregateCsv(t *testing.T) {
+	h, data := aggregateHeaders()
+	var buf bytes.Buffer
+	if _, err := NewCsv(h, iterator.NewSliceIterator(data), WithGroupBy("dept")).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	//空分组的小计行计数为0，其余汇总为空
+	expected := "部门,姓名,金额,平均\nA,a1,0.1,0.1\nA,a2,0.2,0.2\nA 小计,2,0.3,0.15\n,,,\n小计,0,,\nB,b1,3,3\nB 小计,1,3,3\n合计,3,3.3,1.1\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	//自定义标题，不分组时只有合计行
+	buf.Reset()
+	if _, err := NewCsv(h, iterator.NewSliceIterator(data), WithAggregateTitle("Total", "")).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(buf.String(), "\n"); len(lines) != 7 || lines[5] != "Total,3,3.3,1.1" {
+		t.Errorf("unexpected total rows %q", lines)
+	}
+}
+
+func TestAggregateExcel(t *testing.T) {
+	h, data := aggregateHeaders()
+	var buf bytes.Buffer
+	if _, err := NewExcel(h, iterator.NewSliceIterator(data), WithForceSingleFile(), WithGroupBy("dept")).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	rows, err := fp.GetRows(DefaultSheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, ","))
+	}
+	expected := []string{"部门,姓名,金额,平均", "A,a1,0.1,0.1", "A,a2,0.2,0.2", "A 小计,2,0.3,0.15", "", "小计,0,,", "B,b1,3,3", "B 小计,1,3,3", "合计,3,3.3,1.1"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("rows = %q, want %q", lines, expected)
+	}
+	//小计行只统计本分组，合计行的SUBTOTAL会忽略其中的小计行
+	for cell, formula := range map[string]string{
+		"C4": "SUBTOTAL(9,C2:C3)",
+		"B6": "SUBTOTAL(3,B5:B5)",
+		"D6": "SUBTOTAL(1,D5:D5)",
+		"C8": "SUBTOTAL(9,C7:C7)",
+		"B9": "SUBTOTAL(3,B2:B8)",
+		"C9": "SUBTOTAL(9,C2:C8)",
+	} {
+		if f, _ := fp.GetCellFormula(DefaultSheetName, cell); f != formula {
+			t.Errorf("%s formula = %q, want %q", cell, f, formula)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package export
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/xuri/excelize/v2"
+)
+
+// CellType 单元格类型，决定写入的值类型及excel数字格式
+type CellType int
+
+const (
+	CellTypeDefault  CellType = iota //默认，按值原样写入
+	CellTypeText                     //文本，长数字ID等不会被转成科学计数法
+	CellTypeInteger                  //整数
+	CellTypeCurrency                 //金额
+	CellTypePercent                  //百分比，0.25 显示为 25.00%
+	CellTypeDate                     //日期
+	CellTypeDateTime                 //日期时间
+)
+
+// excelTextNumFmt excel内置文本格式 @
+const excelTextNumFmt = 49
+
+// Locale 区域格式，Format为excel数字格式，Layout为导出csv时使用的go时间格式
+type Locale struct {
+	DateFormat     string //excel日期格式
+	DateTimeFormat string //excel日期时间格式
+	IntegerFormat  string //excel整数格式
+	CurrencyFormat string //excel金额格式
+	PercentFormat  string //excel百分比格式
+	DateLayout     string //csv日期格式
+	DateTimeLayout string //csv日期时间格式
+	TrueText       string //bool为true时显示的文本，为空时按原值写入
+	FalseText      string //bool为false时显示的文本，为空时按原值写入
+}
+
+// LocaleZhCN 简体中文格式
+var LocaleZhCN = Locale{
+	DateFormat:     "yyyy-mm-dd",
+	DateTimeFormat: "yyyy-mm-dd hh:mm:ss",
+	IntegerFormat:  "0",
+	CurrencyFormat: "¥#,##0.00",
+	PercentFormat:  "0.00%",
+	DateLayout:     time.DateOnly,
+	DateTimeLayout: time.DateTime,
+}
+
+// LocaleEnUS 美式英文格式
+var LocaleEnUS = Locale{
+	DateFormat:     "mm/dd/yyyy",
+	DateTimeFormat: "mm/dd/yyyy hh:mm:ss",
+	IntegerFormat:  "0",
+	CurrencyFormat: "$#,##0.00",
+	PercentFormat:  "0.00%",
+	DateLayout:     "01/02/2006",
+	DateTimeLayout: "01/02/2006 15:04:05",
+}
+
+// formatters 全局默认格式化函数，按go类型注册 reflect.Type => func(any) any
+var formatters sync.Map
+
+// RegisterFormatter 注册某个go类型的默认格式化函数，如 decimal.Decimal、枚举类型等，
+// 在CellRender之后、按CellType处理之前调用
+func RegisterFormatter[T any](fn func(v T) any) {
+	formatters.Store(reflect.TypeOf((*T)(nil)).Elem(), func(v any) any {
+		return fn(v.(T))
+	})
+}
+
+// normalizeValue 统一单元格原始值：nil指针转为nil，指针解引用，应用已注册的格式化函数，
+// 枚举等实现了fmt.Stringer的自定义类型转为字符串，基础类型的别名转为基础类型
+func normalizeValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	if fn, ok := formatters.Load(reflect.TypeOf(v)); ok {
+		return fn.(func(any) any)(v)
+	}
+	switch v.(type) {
+	case time.Time, time.Duration, []byte, excelize.Cell, *excelize.Cell, []excelize.RichTextRun:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalizeValue(rv.Elem().Interface())
+	}
+	if rv.Type().PkgPath() == "" {
+		return v
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	default:
+		return v
+	}
+}
+
+// cellFormatter 按表头CellType及区域格式处理单元格值
+type cellFormatter struct {
+	locale     *Locale
+	formatTime bool //文本格式未指定CellType的时间是否按区域格式输出，否则保持原有的 cast.ToString 格式
+}
+
+// newCellFormatter locale为空时使用 LocaleZhCN
+func newCellFormatter(locale *Locale) *cellFormatter {
+	if locale == nil {
+		return &cellFormatter{locale: &LocaleZhCN}
+	}
+	return &cellFormatter{locale: locale, formatTime: true}
+}
+
+// numFmt 获取表头对应的excel数字格式，返回空表示不需要设置
+func (f *cellFormatter) numFmt(h Header) string {
+	if h.NumFmt != "" {
+		return h.NumFmt
+	}
+	switch h.CellType {
+	case CellTypeInteger:
+		return f.locale.IntegerFormat
+	case CellTypeCurrency:
+		return f.locale.CurrencyFormat
+	case CellTypePercent:
+		return f.locale.PercentFormat
+	case CellTypeDate:
+		return f.locale.DateFormat
+	case CellTypeDateTime:
+		return f.locale.DateTimeFormat
+	default:
+		return ""
+	}
+}
+
+// newStyle 生成列单元格样式，在列样式基础上加上数字格式，返回0表示不需要单元格样式
+// 流式写入时列样式不会作用到已写入的单元格，所以需要设置到每个单元格上
+func (f *cellFormatter) newStyle(fp *excelize.File, h Header) (int, error) {
+	style := excelize.Style{}
+	if h.ColStyle != nil {
+		style = *h.ColStyle
+	}
+	if h.CellType == CellTypeText && h.NumFmt == "" {
+		style.NumFmt = excelTextNumFmt
+	} else if numFmt := f.numFmt(h); numFmt != "" {
+		style.CustomNumFmt = &numFmt
+	} else if h.ColStyle == nil {
+		return 0, nil
+	}
+	return fp.NewStyle(&style)
+}
+
+// excelValue 转换成写入excel的值
+func (f *cellFormatter) excelValue(h Header, v any) any {
+	if r, ok := f.formatRich(h, v); ok {
+		return r
+	}
+	v = normalizeValue(v)
+	if v == nil {
+		return nil
+	}
+	switch h.CellType {
+	case CellTypeText:
+		return cast.ToString(v)
+	case CellTypeInteger:
+		if n, err := cast.ToInt64E(v); err == nil {
+			return n
+		}
+	case CellTypeCurrency, CellTypePercent:
+		if n, err := cast.ToFloat64E(v); err == nil {
+			return n
+		}
+	case CellTypeDate, CellTypeDateTime:
+		if t, ok := toTime(v); ok {
+			return t
+		}
+		return nil
+	default:
+		return f.boolText(v)
+	}
+	return v
+}
+
+// textValue 转换成写入csv等文本格式的文本
+func (f *cellFormatter) textValue(h Header, v any) string {
+	v, _ = richText(v)
+	v = normalizeValue(v)
+	if v == nil {
+		return ""
+	}
+	switch h.CellType {
+	case CellTypeInteger:
+		if n, err := cast.ToInt64E(v); err == nil {
+			return strconv.FormatInt(n, 10)
+		}
+	case CellTypeCurrency:
+		if n, err := cast.ToFloat64E(v); err == nil {
+			return strconv.FormatFloat(n, 'f', 2, 64)
+		}
+	case CellTypePercent:
+		if n, err := cast.ToFloat64E(v); err == nil {
+			return strconv.FormatFloat(n*100, 'f', 2, 64) + "%"
+		}
+	case CellTypeDate:
+		if t, ok := toTime(v); ok {
+			return t.Format(f.locale.DateLayout)
+		}
+		return ""
+	case CellTypeDateTime:
+		if t, ok := toTime(v); ok {
+			return t.Format(f.locale.DateTimeLayout)
+		}
+		return ""
+	case CellTypeDefault:
+		if t, ok := v.(time.Time); ok && f.formatTime {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(f.locale.DateTimeLayout)
+		}
+		v = f.boolText(v)
+	}
+	return cast.ToString(v)
+}
+
+func (f *cellFormatter) boolText(v any) any {
+	b, ok := v.(bool)
+	if !ok || f.locale.TrueText == "" || f.locale.FalseText == "" {
+		return v
+	}
+	if b {
+		return f.locale.TrueText
+	}
+	return f.locale.FalseText
+}
+
+// toTime 转换为时间，零值时间视为空
+func toTime(v any) (time.Time, bool) {
+	t, err := cast.ToTimeE(v)
+	if err != nil || t.IsZero() {
+		return time.Time{}, false
+	}
+	return t, true
+}
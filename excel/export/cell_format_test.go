@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opdss/common/iterator"
+	"github.com/xuri/excelize/v2"
+)
+
+// testLevel 注册了格式化函数的自定义类型
+type testLevel int
+
+func TestCellTypeExcel(t *testing.T) {
+	RegisterFormatter(func(v testLevel) any {
+		return "L" + strings.Repeat("*", int(v))
+	})
+	h := Headers{
+		{Field: "id", Title: "ID", CellType: CellTypeText},
+		{Field: "amount", Title: "金额", CellType: CellTypeCurrency},
+		//与上一列字段相同，按各自的表头处理
+		{Field: "amount", Title: "占比", CellType: CellTypePercent, CellRender: func(_ any, v any, _, _ int) any {
+			return v.(float64) / 100
+		}},
+		{Field: "day", Title: "日期", CellType: CellTypeDate},
+		{Field: "level", Title: "等级"},
+	}
+	day := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	data := []any{map[string]any{"id": 12345678901234567, "amount": 25.5, "day": day, "level": testLevel(2)}}
+	var buf bytes.Buffer
+	if _, err := NewExcel(h, iterator.NewSliceIterator(data), WithForceSingleFile(), WithLocale(LocaleEnUS)).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	for _, test := range []struct {
+		cell, value, numFmt string
+	}{
+		{"A2", "12345678901234567", ""},
+		{"B2", "$25.50", LocaleEnUS.CurrencyFormat},
+		{"C2", "25.50%", LocaleEnUS.PercentFormat},
+		{"D2", "03/05/2024", LocaleEnUS.DateFormat},
+		{"E2", "L**", ""},
+	} {
+		value, err := fp.GetCellValue(DefaultSheetName, test.cell)
+		if err != nil || value != test.value {
+			t.Errorf("%s = %q, %v, want %q", test.cell, value, err, test.value)
+		}
+		if test.numFmt == "" {
+			continue
+		}
+		styleId, _ := fp.GetCellStyle(DefaultSheetName, test.cell)
+		style, err := fp.GetStyle(styleId)
+		if err != nil || style.CustomNumFmt == nil || *style.CustomNumFmt != test.numFmt {
+			t.Errorf("%s style = %+v, %v, want %q", test.cell, style, err, test.numFmt)
+		}
+	}
+	if typ, _ := fp.GetCellType(DefaultSheetName, "A2"); typ != excelize.CellTypeSharedString && typ != excelize.CellTypeInlineString {
+		t.Errorf("A2 type = %v, want string", typ)
+	}
+}
+
+func TestCellTypeCsv(t *testing.T) {
+	h := Headers{
+		{Field: "amount", Title: "amount", CellType: CellTypeCurrency},
+		{Field: "amount", Title: "rate", CellType: CellTypePercent},
+		{Field: "at", Title: "at"},
+		{Field: "at", Title: "day", CellType: CellTypeDate},
+		{Field: "ok", Title: "ok"},
+	}
+	at := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	data := []any{map[string]any{"amount": 0.5, "at": at, "ok": true}}
+	export := func(opts ...Option) string {
+		var buf bytes.Buffer
+		if _, err := NewCsv(h, iterator.NewSliceIterator(data), opts...).ExportTo(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(buf.String(), "\n")[1]
+	}
+	//未设置区域格式时，未指定CellType的时间保持原有格式
+	if line := export(); line != "0.50,50.00%,2024-03-05 10:00:00 +0000 UTC,2024-03-05,true" {
+		t.Errorf("default locale = %q", line)
+	}
+	locale := LocaleEnUS
+	locale.TrueText, locale.FalseText = "Yes", "No"
+	if line := export(WithLocale(locale)); line != "0.50,50.00%,03/05/2024 10:00:00,03/05/2024,Yes" {
+		t.Errorf("en-US locale = %q", line)
+	}
+}
@@ -1,13 +1,17 @@
 package export
 
+import (
+	"reflect"
+	"sync"
+)
+
 type columns struct {
-	headers       Headers               //导出表配置
-	fields        []string              //导出字段名
-	titles        []string              //导出列名
-	nums          int                   //列数量
-	keyIndex      map[string]int        //列字段索引映射
-	nilKeyIndex   map[string]int        //用来做struct补充数据
-	columnRenders map[string]CellRender //列字段渲染函数映射
+	headers   Headers        //导出表配置
+	fields    []string       //导出字段名
+	titles    []string       //导出列名
+	nums      int            //列数量
+	keyIndex  map[string]int //列字段索引映射，多列使用相同字段时为第一列
+	accessors sync.Map       //行数据类型 => 每列取值器 reflect.Type => []accessor
 }
 
 func newColumns(headers Headers) *columns {
@@ -16,20 +20,18 @@ func newColumns(headers Headers) *columns {
 		panic("header is empty")
 	}
 	c := &columns{
-		headers:       headers,
-		fields:        make([]string, size),
-		titles:        make([]string, size),
-		nums:          size,
-		keyIndex:      make(map[string]int),
-		nilKeyIndex:   make(map[string]int),
-		columnRenders: make(map[string]CellRender),
+		headers:  headers,
+		fields:   make([]string, size),
+		titles:   make([]string, size),
+		nums:     size,
+		keyIndex: make(map[string]int),
 	}
 	for i := 0; i < size; i++ {
 		c.fields[i] = headers[i].Field
 		c.titles[i] = headers[i].Title
-		c.keyIndex[headers[i].Field] = i
-		c.nilKeyIndex[headers[i].Field] = 0
-		c.columnRenders[headers[i].Field] = headers[i].CellRender
+		if _, ok := c.keyIndex[headers[i].Field]; !ok {
+			c.keyIndex[headers[i].Field] = i
+		}
 	}
 	return c
 }
@@ -41,3 +43,42 @@ func (c *columns) getTitleToAny() []any {
 	}
 	return res
 }
+
+// getAccessors 获取行数据类型对应的每列取值器，同一类型只编译一次
+func (c *columns) getAccessors(typ reflect.Type) []accessor {
+	if fns, ok := c.accessors.Load(typ); ok {
+		return fns.([]accessor)
+	}
+	fns := make([]accessor, c.nums)
+	for i := range c.fields {
+		fns[i] = getAccessor(typ, c.fields[i])
+	}
+	c.accessors.Store(typ, fns)
+	return fns
+}
+
+// fieldValue 获取行数据第idx列的原始值
+func (c *columns) fieldValue(rowData reflect.Value, idx int) any {
+	for rowData.IsValid() && (rowData.Kind() == reflect.Ptr || rowData.Kind() == reflect.Interface) {
+		if rowData.IsNil() {
+			return nil
+		}
+		rowData = rowData.Elem()
+	}
+	if !rowData.IsValid() {
+		return nil
+	}
+	var val reflect.Value
+	switch rowData.Kind() {
+	case reflect.Map, reflect.Struct:
+		val = c.getAccessors(rowData.Type())[idx](rowData)
+	case reflect.Slice:
+		if idx < rowData.Len() {
+			val = rowData.Index(idx)
+		}
+	}
+	if val.IsValid() && val.CanInterface() {
+		return val.Interface()
+	}
+	return nil
+}
@@ -1,289 +1,213 @@
 package export
 
 import (
-	"archive/zip"
-	"context"
-	"encoding/csv"
-	"github.com/opdss/common/contracts/excel"
-	"github.com/spf13/cast"
+	"bufio"
 	"io"
-	"log"
-	"os"
-	"path/filepath"
-	"reflect"
-	"sync"
+	"strconv"
+	"strings"
+
+	"github.com/opdss/common/contracts/excel"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
 var _ excel.Exporter = (*Csv)(nil)
 
-type Csv struct {
-	dp      DataProvider
-	options *options
-	columns *columns
-	total   int
-}
+// utf8BOM excel打开utf-8编码csv需要的BOM头
+const utf8BOM = "\xEF\xBB\xBF"
 
-func NewCsv(h Headers, dp DataProvider, opts ...Option) *Csv {
-	e := &Csv{
-		dp:      dp,
-		columns: newColumns(h),
-		options: newOptions(opts...),
-	}
-	return e
+// CsvQuote csv字段加引号方式
+type CsvQuote int
+
+const (
+	CsvQuoteMinimal    CsvQuote = iota //只在包含分隔符、引号、换行或首字符为空格时加引号
+	CsvQuoteAll                        //全部字段加引号
+	CsvQuoteNonNumeric                 //非数字字段加引号
+)
+
+// csvOptions csv格式配置
+type csvOptions struct {
+	bom           bool              //写入utf-8 BOM头
+	encoding      encoding.Encoding //输出编码，为空时为utf-8
+	delimiter     rune              //字段分隔符
+	quote         CsvQuote          //加引号方式
+	crlf          bool              //使用\r\n换行
+	formulaEscape bool              //转义公式注入
 }
 
-func (c *Csv) Export(ctx context.Context) (filename string, err error) {
-	ef, err := c.export(ctx)
-	if err != nil {
-		return "", err
+// WithCsvBOM 写入utf-8 BOM头，windows下excel直接打开不会乱码，设置了WithCsvEncoding时不生效
+func WithCsvBOM() Option {
+	return func(opt *options) {
+		opt.csv.bom = true
 	}
-	defer func() {
-		_ = ef.Close()
-	}()
-	return ef.Save()
 }
 
-func (c *Csv) ExportTo(ctx context.Context, w io.Writer) (n int64, err error) {
-	ef, err := c.export(ctx)
-	if err != nil {
-		return 0, err
+// WithCsvEncoding 设置csv输出编码，如 simplifiedchinese.GBK、simplifiedchinese.GB18030，
+// 无法编码的字符替换为编码的替换字符
+func WithCsvEncoding(enc encoding.Encoding) Option {
+	return func(opt *options) {
+		opt.csv.encoding = enc
 	}
-	defer func() {
-		_ = ef.Close()
-	}()
-	return ef.WriteTo(w)
 }
 
-func (c *Csv) ExportToStorage(ctx context.Context, fs excel.FileStorage) (filename string, err error) {
-	ef, err := c.export(ctx)
-	if err != nil {
-		return "", err
-	}
-	defer func() {
-		_ = ef.Close()
-	}()
-	fileKey := filepath.Base(ef.Filepath())
-	fr, fw := io.Pipe()
-	wg := sync.WaitGroup{}
-	wg.Add(2)
-	var _err error
-	go func() {
-		defer wg.Done()
-		if _, _err = ef.WriteTo(fw); _err != nil {
-			log.Println("io pipe write error", _err.Error())
-		}
-		_ = fw.Close()
-	}()
-	go func() {
-		defer wg.Done()
-		if _err = fs.PutStream(ctx, fileKey, fr); _err != nil {
-			log.Println("io pipe read error", _err.Error())
+// WithCsvDelimiter 设置csv字段分隔符，默认逗号
+func WithCsvDelimiter(r rune) Option {
+	return func(opt *options) {
+		if r != 0 && r != '"' && r != '\r' && r != '\n' {
+			opt.csv.delimiter = r
 		}
-		_ = fr.Close()
-	}()
-	wg.Wait()
-	if _err != nil {
-		return "", _err
 	}
-	return fs.Url(fileKey), nil
 }
 
-func (c *Csv) export(ctx context.Context) (exportFile, error) {
-	if c.options.forceZip {
-		return c.exportZip(ctx, nil)
-	}
-	//先导出第一个文件
-	firstEf, err := newExportTmpFile(getFilename(c.options.filename, 0, CsvSuffix))
-	if err != nil {
-		return nil, err
+// WithCsvQuote 设置csv字段加引号方式，默认 CsvQuoteMinimal
+func WithCsvQuote(q CsvQuote) Option {
+	return func(opt *options) {
+		opt.csv.quote = q
 	}
-	hasMore, err := c.exportToWrite(ctx, firstEf)
-	if err != nil {
-		_ = firstEf.Close()
-		return nil, err
-	}
-	if !hasMore {
-		return firstEf, nil
+}
+
+// WithCsvCRLF 使用\r\n换行，默认\n
+func WithCsvCRLF() Option {
+	return func(opt *options) {
+		opt.csv.crlf = true
 	}
-	defer func() {
-		_ = firstEf.Close()
-		_ = os.Remove(firstEf.Filepath())
-	}()
-	//导出zip
-	return c.exportZip(ctx, firstEf)
 }
 
-func (c *Csv) exportZip(ctx context.Context, firstFile exportFile) (exportFile, error) {
-	var idx int
-	ef, err := newExportTmpFile(getFilename(c.options.filename, 0, ZipSuffix))
-	if err != nil {
-		return nil, err
+// WithCsvFormulaEscape 以 = + - @ 制表符或回车开头的非数字字段前加单引号，防止excel打开时执行公式
+func WithCsvFormulaEscape() Option {
+	return func(opt *options) {
+		opt.csv.formulaEscape = true
 	}
-	defer func() {
-		if err != nil {
-			_ = ef.Close()
-		}
-	}()
-	zw := zip.NewWriter(ef)
-	defer func() {
-		_ = zw.Close()
-	}()
-	var w io.Writer
-	//把外面传进来的加进去
-	if firstFile != nil {
-		w, err = newZipWriter(zw, firstFile.Filepath())
-		if err != nil {
-			return nil, err
-		}
-		if _, err = firstFile.WriteTo(w); err != nil {
-			return nil, err
-		}
-		idx++
+}
+
+type Csv struct {
+	*textExporter
+}
+
+func NewCsv(h Headers, dp DataProvider, opts ...Option) *Csv {
+	return &Csv{newTextExporter(csvFormat{}, h, dp, opts...)}
+}
+
+type csvFormat struct{}
+
+func (csvFormat) suffix() string {
+	return CsvSuffix
+}
+
+func (csvFormat) newWriter(w io.Writer, c *columns, f *cellFormatter, opt *options) textWriter {
+	cw := &csvWriter{columns: c, formatter: f, opt: opt.csv}
+	if cw.opt.delimiter == 0 {
+		cw.opt.delimiter = ','
 	}
-	//读取数据
-	hasMore := true
-	for {
-		if !hasMore {
-			break
-		}
-		w, err = newZipWriter(zw, getFilename(c.options.filename, idx, CsvSuffix))
-		if err != nil {
-			return nil, err
-		}
-		idx++
-		hasMore, err = c.exportToWrite(ctx, w)
-		if err != nil {
-			return nil, err
-		}
+	if opt.csv.encoding != nil {
+		cw.tw = transform.NewWriter(w, encoding.ReplaceUnsupported(opt.csv.encoding.NewEncoder()))
+		w = cw.tw
 	}
-	return ef, nil
+	cw.w = bufio.NewWriter(w)
+	return cw
 }
 
-func (c *Csv) exportToWrite(ctx context.Context, fp io.Writer) (bool, error) {
-	fw := csv.NewWriter(fp)
-	defer func() {
-		fw.Flush()
-	}()
-	// 写入CSV头部a
-	err := fw.Write(c.columns.titles)
-	if err != nil {
-		return false, err
-	}
-	row := 0
-	for {
-		row++
-		if !c.dp.Next() {
-			break
-		}
-		_v := c.dp.Value()
-		values := c.processRow(reflect.ValueOf(_v), row)
-		err = fw.Write(values)
-		if err != nil {
-			break
-		}
-		if !c.options.forceSingleFile && row >= c.options.singleFileMaxRows {
-			return true, nil
-		}
+type csvWriter struct {
+	w         *bufio.Writer
+	tw        *transform.Writer //转码时的编码writer，关闭时才会写入最后的数据
+	columns   *columns
+	formatter *cellFormatter
+	opt       csvOptions
+}
 
-		//检查是否超过最大导出限制
-		c.total++
-		if c.total > c.options.maxRows {
-			err = ErrMaximumLimit
-			break
-		}
-		//收到取消导出信号
-		select {
-		case <-ctx.Done():
-			err = ctx.Err()
-		default:
-		}
-		if err != nil {
-			break
+func (w *csvWriter) writeHeader() error {
+	if w.opt.bom && w.opt.encoding == nil {
+		if _, err := w.w.WriteString(utf8BOM); err != nil {
+			return err
 		}
 	}
-	return false, err
+	return w.write(w.columns.titles, false)
 }
 
-func (c *Csv) processRow(rowData reflect.Value, row int) []string {
-	if !rowData.IsValid() {
-		return make([]string, c.columns.nums)
-	}
-	switch rowData.Type().Kind() {
-	case reflect.Ptr:
-		return c.processRow(rowData.Elem(), row)
-	case reflect.Map:
-		return c.processRowFromMap(rowData, row)
-	case reflect.Struct:
-		return c.processRowFromStruct(rowData, row)
-	case reflect.Slice:
-		return c.processRowFromSlice(rowData, row)
-	default:
-		return make([]string, c.columns.nums)
+// writeRow 按表头单元格类型转换成csv文本
+func (w *csvWriter) writeRow(values []any) error {
+	_rowData := make([]string, len(values))
+	for i := range values {
+		_rowData[i] = w.formatter.textValue(w.columns.headers[i], values[i])
 	}
+	return w.write(_rowData, true)
 }
 
-func (c *Csv) processRowFromMap(rowData reflect.Value, row int) []string {
-	_rowData := make([]string, c.columns.nums)
-	for i := range c.columns.fields {
-		field := c.columns.fields[i]
-		_rowData[i] = c.processCell(field, rowData.MapIndex(reflect.ValueOf(field)), rowData, row, i+1)
+func (w *csvWriter) writeSummary(values []string) error {
+	return w.write(values, true)
+}
+
+func (w *csvWriter) close() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	if w.tw != nil {
+		return w.tw.Close()
 	}
-	return _rowData
+	return nil
 }
 
-func (c *Csv) processRowFromStruct(rowData reflect.Value, row int) []string {
-	_rowData := make([]string, c.columns.nums)
-	typ := rowData.Type()
-	gets := 0
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		key := field.Name
-		//读取tag
-		if k, ok := typ.Field(i).Tag.Lookup(TagName); ok {
-			key = k
+// write 写入一行，escape为true时按配置转义公式
+func (w *csvWriter) write(record []string, escape bool) error {
+	for i, field := range record {
+		if i > 0 {
+			_, _ = w.w.WriteRune(w.opt.delimiter)
 		}
-		if idx, ok := c.columns.keyIndex[key]; ok {
-			gets++
-			c.columns.nilKeyIndex[key] = row
-			_rowData[idx] = c.processCell(key, rowData.Field(i), rowData, row, idx+1)
+		if escape && w.opt.formulaEscape && isFormula(field) {
+			field = "'" + field
 		}
-		if gets == c.columns.nums {
-			break
+		if !w.needQuotes(field) {
+			_, _ = w.w.WriteString(field)
+			continue
 		}
+		_ = w.w.WriteByte('"')
+		_, _ = w.w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		_ = w.w.WriteByte('"')
 	}
-	//没取到的字段得补充下
-	var idx int
-	if gets != c.columns.nums {
-		for k, v := range c.columns.nilKeyIndex {
-			if v != row {
-				idx = c.columns.keyIndex[k]
-				_rowData[idx] = c.processCell(k, nilValue, rowData, row, idx+1)
-			}
-		}
+	var err error
+	if w.opt.crlf {
+		_, err = w.w.WriteString("\r\n")
+	} else {
+		err = w.w.WriteByte('\n')
 	}
-	return _rowData
+	return err
 }
 
-func (c *Csv) processRowFromSlice(rowData reflect.Value, row int) []string {
-	_rowData := make([]string, c.columns.nums)
-	l := rowData.Len()
-	for i := 0; i < c.columns.nums; i++ {
-		if i < l {
-			_rowData[i] = c.processCell(c.columns.fields[i], rowData.Index(i), rowData, row, i+1)
-		} else {
-			_rowData[i] = c.processCell(c.columns.fields[i], nilValue, rowData, row, i+1)
+// needQuotes 与 encoding/csv 的规则一致，另外支持全部加引号及非数字加引号
+func (w *csvWriter) needQuotes(field string) bool {
+	switch w.opt.quote {
+	case CsvQuoteAll:
+		return true
+	case CsvQuoteNonNumeric:
+		if !isNumeric(field) {
+			return true
 		}
 	}
-	return _rowData
+	if field == "" {
+		return false
+	}
+	if field == `\.` {
+		return true
+	}
+	if strings.ContainsRune(field, w.opt.delimiter) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	return field[0] == ' ' || field[0] == '\t'
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
 }
 
-func (c *Csv) processCell(field string, val reflect.Value, rowData reflect.Value, row, col int) string {
-	var v any
-	if val.IsValid() {
-		v = val.Interface()
+// isFormula 是否会被excel当作公式执行，负数等数字不处理
+func isFormula(s string) bool {
+	if s == "" || isNumeric(s) {
+		return false
 	}
-	if c.columns.columnRenders[field] != nil {
-		v = c.columns.columnRenders[field](rowData.Interface(), v, row, col)
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return true
 	}
-	return cast.ToString(v)
+	return false
 }
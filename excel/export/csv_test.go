@@ -0,0 +1,44 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestCsvDialect(t *testing.T) {
+	h := Headers{{Field: "name", Title: "名称"}, {Field: "amount", Title: "金额"}}
+	data := []any{
+		map[string]any{"name": "=HYPERLINK(\"x\")", "amount": -1.5},
+		map[string]any{"name": "a;b", "amount": 2},
+	}
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("名称;金额\r\n")
+	for _, test := range []struct {
+		opts     []Option
+		expected string
+	}{
+		{nil, "名称,金额\n\"=HYPERLINK(\"\"x\"\")\",-1.5\na;b,2\n"},
+		{[]Option{WithCsvBOM(), WithCsvFormulaEscape()}, utf8BOM + "名称,金额\n\"'=HYPERLINK(\"\"x\"\")\",-1.5\na;b,2\n"},
+		{[]Option{WithCsvDelimiter(';'), WithCsvQuote(CsvQuoteNonNumeric), WithCsvCRLF()}, "\"名称\";\"金额\"\r\n\"=HYPERLINK(\"\"x\"\")\";-1.5\r\n\"a;b\";2\r\n"},
+		{[]Option{WithCsvQuote(CsvQuoteAll)}, "\"名称\",\"金额\"\n\"=HYPERLINK(\"\"x\"\")\",\"-1.5\"\n\"a;b\",\"2\"\n"},
+	} {
+		var buf bytes.Buffer
+		if _, err := NewCsv(h, iterator.NewSliceIterator(data), test.opts...).ExportTo(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, buf.String())
+		}
+	}
+	var buf bytes.Buffer
+	_, err := NewCsv(h, iterator.NewSliceIterator[any](nil), WithCsvEncoding(simplifiedchinese.GBK), WithCsvDelimiter(';'), WithCsvCRLF()).ExportTo(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != gbk {
+		t.Errorf("expected gbk %q, got %q", gbk, buf.String())
+	}
+}
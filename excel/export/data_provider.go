@@ -8,7 +8,7 @@ import (
 // DataProvider 数据提供者
 type DataProvider iterator.Iterator[any]
 
-// CellRender 单元格数据渲染
+// CellRender 单元格数据渲染，导出excel时可以返回 Hyperlink、Image、Comment 富单元格
 // @rowData 整个行数据
 // @val 获取到的单元格元数据
 // @row 当前行数
@@ -17,11 +17,15 @@ type CellRender func(rowData any, val any, row int, col int) any
 
 // Header 表头
 type Header struct {
-	Field      string          //字段名
+	Field      string          //字段名,支持user.address.city多级路径及FullName()无参方法
 	Title      string          //列名
 	CellRender CellRender      //单元格数据处理
 	ColStyle   *excelize.Style //列样式,导出excel时支持
 	ColWidth   float64         //列宽度,导出excel时支持
+	CellType   CellType        //单元格类型,决定写入值的类型及数字格式
+	NumFmt     string          //自定义excel数字格式,如 0.000,优先于CellType默认格式
+	Aggregate  Aggregate       //汇总方式,在每个文件末尾输出合计行,配合WithGroupBy输出分组小计行
+	DropList   []string        //下拉选项,导出excel时给该列设置下拉数据验证,可作为导入模板
 }
 
 // Headers 表头
@@ -2,6 +2,7 @@ package export
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"github.com/opdss/common/contracts/excel"
 	"github.com/xuri/excelize/v2"
@@ -18,10 +19,13 @@ const DefaultSheetName = "Sheet1"
 var _ excel.Exporter = (*Excel)(nil)
 
 type Excel struct {
-	options *options
-	columns *columns
-	dp      DataProvider
-	total   int
+	options   *options
+	columns   *columns
+	formatter *cellFormatter
+	progress  *progressTracker
+	dp        DataProvider
+	total     int
+	template  []byte //模板内容，读取成功后拆分多个文件导出时复用
 }
 
 func NewExcel(h Headers, dp DataProvider, opts ...Option) *Excel {
@@ -30,11 +34,16 @@ func NewExcel(h Headers, dp DataProvider, opts ...Option) *Excel {
 		columns: newColumns(h),
 		options: newOptions(opts...),
 	}
+	e.formatter = newCellFormatter(e.options.locale)
+	e.progress = newProgressTracker(e.options.progress, e.options.progressInterval)
 	return e
 }
 
 // Export 导出到本地文件，返回本地文件路径
 func (e *Excel) Export(ctx context.Context) (string, error) {
+	if e.options.streaming {
+		return streamToFile(ctx, e)
+	}
 	ef, err := e.export(ctx)
 	if err != nil {
 		return "", err
@@ -49,6 +58,9 @@ func (e *Excel) Export(ctx context.Context) (string, error) {
 
 // ExportTo 导出到io.Writer
 func (e *Excel) ExportTo(ctx context.Context, w io.Writer) (n int64, err error) {
+	if e.options.streaming {
+		return streamTo(ctx, e, w)
+	}
 	ef, err := e.export(ctx)
 	if err != nil {
 		return
@@ -63,6 +75,9 @@ func (e *Excel) ExportTo(ctx context.Context, w io.Writer) (n int64, err error)
 
 // ExportToStorage 导出到文件存储，返回下载地址
 func (e *Excel) ExportToStorage(ctx context.Context, fs excel.FileStorage) (string, error) {
+	if e.options.streaming {
+		return streamToStorage(ctx, e, fs)
+	}
 	ef, err := e.export(ctx)
 	if err != nil {
 		return "", err
@@ -98,21 +113,35 @@ func (e *Excel) ExportToStorage(ctx context.Context, fs excel.FileStorage) (stri
 	return fs.Url(fk), nil
 }
 
+// Total 已导出数据行数
+func (e *Excel) Total() int {
+	return e.total
+}
+
+// OnProgress 追加导出进度回调，需在导出前调用
+func (e *Excel) OnProgress(fn ProgressFunc) {
+	e.progress.then(fn)
+}
+
 // 执行导出
 func (e *Excel) export(ctx context.Context) (ef exportFile, err error) {
+	e.progress.begin()
 	//强制打包zip
 	if e.options.forceZip {
 		return e.exportZip(ctx, nil)
 	}
 	//先导出第一个文件
-	firstFile := excelize.NewFile()
+	firstFile, err := e.newFile(ctx)
+	if err != nil {
+		return nil, err
+	}
 	hasMore, err := e.exportToExcelize(ctx, firstFile)
 	if err != nil {
 		_ = firstFile.Close()
 		return nil, err
 	}
 	if !hasMore {
-		return newExportExcel(getFilename(e.options.filename, 0, ExcelSuffix), firstFile), nil
+		return newExportExcel(getFilename(e.options.filename, 0, ExcelSuffix), firstFile, e.options.password), nil
 	}
 	defer func() {
 		_ = firstFile.Close()
@@ -121,6 +150,7 @@ func (e *Excel) export(ctx context.Context) (ef exportFile, err error) {
 	return e.exportZip(ctx, firstFile)
 }
 
+// exportZip 设置密码时只加密zip中的条目，excel本身不加密
 func (e *Excel) exportZip(ctx context.Context, firstFile *excelize.File) (exportFile, error) {
 	var idx int
 	ef, err := newExportTmpFile(getFilename(e.options.filename, idx, ZipSuffix))
@@ -136,58 +166,123 @@ func (e *Excel) exportZip(ctx context.Context, firstFile *excelize.File) (export
 	defer func() {
 		_ = zw.Close()
 	}()
-	var w io.Writer
+	var w io.WriteCloser
 	//把外面传进来的加进去
 	if firstFile != nil {
-		w, err = newZipWriter(zw, getFilename(e.options.filename, 0, ExcelSuffix))
+		w, err = newZipWriter(zw, getFilename(e.options.filename, 0, ExcelSuffix), e.options.password)
 		if err != nil {
 			return nil, err
 		}
 		if err = firstFile.Write(w); err != nil {
 			return nil, err
 		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
 		idx++
 	}
 	//读取数据
+	if err = e.writeZipParts(ctx, zw, idx); err != nil {
+		return nil, err
+	}
+	return ef, nil
+}
+
+// writeZipParts 从第idx个文件开始，把剩余数据逐个文件写入zip
+func (e *Excel) writeZipParts(ctx context.Context, zw *zip.Writer, idx int) error {
 	hasMore := true
-	for {
-		if !hasMore {
-			break
-		}
-		w, err = newZipWriter(zw, getFilename(e.options.filename, idx, ExcelSuffix))
+	for hasMore {
+		w, err := newZipWriter(zw, getFilename(e.options.filename, idx, ExcelSuffix), e.options.password)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		idx++
-		fw := excelize.NewFile()
-		hasMore, err = e.exportToExcelize(ctx, fw)
+		fw, err := e.newFile(ctx)
 		if err != nil {
-			_ = fw.Close()
-			return nil, err
+			return err
 		}
+		hasMore, err = e.exportToExcelize(ctx, fw)
 		//写入zip
-		if err = fw.Write(w); err != nil {
-			_ = fw.Close()
-			return nil, err
+		if err == nil {
+			err = fw.Write(w)
+		}
+		if err == nil {
+			err = w.Close()
 		}
 		_ = fw.Close()
+		if err != nil {
+			return err
+		}
 	}
-	return ef, nil
+	return nil
+}
+
+// StreamFilename 流式导出的文件名，强制单文件时为excel，否则为zip，用于确定下载文件类型
+func (e *Excel) StreamFilename() string {
+	if e.options.forceSingleFile {
+		return getFilename(e.options.filename, 0, ExcelSuffix)
+	}
+	return getFilename(e.options.filename, 0, ZipSuffix)
+}
+
+// streamTo 流式导出，zip中的每个excel生成后立即写入w
+func (e *Excel) streamTo(ctx context.Context, w io.Writer) error {
+	e.progress.begin()
+	if !e.options.forceSingleFile {
+		zw := zip.NewWriter(w)
+		err := e.writeZipParts(ctx, zw, 0)
+		if _err := zw.Close(); err == nil {
+			err = _err
+		}
+		return err
+	}
+	fp, err := e.newFile(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	if _, err = e.exportToExcelize(ctx, fp); err != nil {
+		return err
+	}
+	return fp.Write(w, excelize.Options{Password: e.options.password})
+}
+
+// newFile 创建导出文件，设置了模板时从模板打开
+func (e *Excel) newFile(ctx context.Context) (*excelize.File, error) {
+	if e.options.template == nil {
+		return excelize.NewFile(), nil
+	}
+	if e.template == nil {
+		content, err := e.options.template.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e.template = content
+	}
+	return excelize.OpenReader(bytes.NewReader(e.template))
 }
 
 func (e *Excel) exportToExcelize(ctx context.Context, fp *excelize.File) (hasMore bool, err error) {
+	if e.options.template != nil {
+		if hasMore, err = e.exportToTemplate(ctx, fp); err == nil {
+			e.progress.addFile()
+		}
+		return
+	}
 	row := e.options.rowStart + 1
 	col := e.options.colStart + 1
 
-	//设置列相关属性
-	if err = e.setColStyle(col, fp); err != nil {
-		return false, err
-	}
-
 	fw, err := fp.NewStreamWriter(DefaultSheetName)
 	if err != nil {
 		return
 	}
+	//设置列相关属性
+	cellStyles, err := e.setColStyle(DefaultSheetName, col, fp, fw)
+	if err != nil {
+		return false, err
+	}
 	//设置导出表头
 	cell, err := excelize.CoordinatesToCellName(col, row)
 	if err != nil {
@@ -197,33 +292,77 @@ func (e *Excel) exportToExcelize(ctx context.Context, fp *excelize.File) (hasMor
 		return
 	}
 	//开始写入数据
+	_, hasMore, err = e.writeRows(ctx, fp, fw, row, col, cellStyles)
+	if err != nil {
+		_ = fw.Flush()
+		return
+	}
+	if err = fw.Flush(); err == nil {
+		e.progress.addFile()
+	}
+	return
+}
+
+// writeRows 从第row+1行第col列开始写入数据，返回最后写入的行数
+func (e *Excel) writeRows(ctx context.Context, fp *excelize.File, fw *excelize.StreamWriter, row, col int, cellStyles []int, opts ...excelize.RowOpts) (lastRow int, hasMore bool, err error) {
+	var cell string
+	if err = addDataValidations(fp, fw.Sheet, e.columns.headers, row+1, col); err != nil {
+		return
+	}
+	rich := newRichWriter(fp, fw.Sheet)
+	agg := newAggregator(e.columns, e.formatter, e.options)
+	dataStart := row + 1
+	lastRow = row //最后一行数据所在行数
+	nums := 0     //当前文件数据行数
 	for {
 		row++
 		if !e.dp.Next() {
 			break
 		}
 		_v := e.dp.Value()
-		values := e.processRow(reflect.ValueOf(_v), _v, row)
+		rowData := reflect.ValueOf(_v)
+		if agg != nil {
+			//分组变化，先输出上一分组小计
+			groupKey, changed := agg.groupChanged(rowData)
+			if changed {
+				if err = e.writeSubtotalRow(fw, agg, row, lastRow, col, cellStyles); err != nil {
+					break
+				}
+				row++
+			}
+			agg.enterGroup(groupKey, row)
+		}
+		values := e.processRow(rowData, _v, row)
+		if agg != nil {
+			agg.add(values)
+		}
+		if err = rich.write(ctx, values, cellStyles, row, col); err != nil {
+			break
+		}
+		setCellStyles(values, cellStyles)
 		cell, err = excelize.CoordinatesToCellName(col, row)
 		if err != nil {
 			log.Println(err)
 			break
 		}
-		err = fw.SetRow(cell, values)
+		err = fw.SetRow(cell, values, opts...)
 		if err != nil {
 			log.Println(err)
 			break
 		}
-		if !e.options.forceSingleFile && row-e.options.rowStart-1 >= e.options.singleFileMaxRows {
-			hasMore = true
-			break
-		}
+		lastRow = row
+		nums++
+		e.progress.addRow()
 		//检查是否超过最大导出限制
 		e.total++
 		if e.total > e.options.maxRows {
 			err = ErrMaximumLimit
 			break
 		}
+		if !e.options.forceSingleFile && nums >= e.options.singleFileMaxRows {
+			hasMore = true
+			break
+		}
 		//收到取消导出信号
 		select {
 		case <-ctx.Done():
@@ -234,41 +373,92 @@ func (e *Excel) exportToExcelize(ctx context.Context, fp *excelize.File) (hasMor
 			break
 		}
 	}
-	if err != nil {
-		_ = fw.Flush()
-		return
+	//输出最后一个分组小计及合计
+	if err == nil && agg != nil && nums > 0 {
+		lastRow, err = e.writeTotalRows(fw, agg, dataStart, lastRow, col, cellStyles)
 	}
-	err = fw.Flush()
 	return
 }
 
-// setColStyle 设置列相关属性
-func (e *Excel) setColStyle(colStart int, fp *excelize.File) error {
+// writeSubtotalRow 在第row行写入当前分组的小计行，lastRow为分组最后一行数据所在行数
+func (e *Excel) writeSubtotalRow(fw *excelize.StreamWriter, agg *aggregator, row, lastRow, col int, cellStyles []int) error {
+	values, err := agg.excelSubtotalRow(lastRow, col, cellStyles)
+	if err != nil {
+		return err
+	}
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return err
+	}
+	return fw.SetRow(cell, values)
+}
+
+// writeTotalRows 在数据末尾写入最后一个分组的小计行及合计行，返回合计行所在行数
+func (e *Excel) writeTotalRows(fw *excelize.StreamWriter, agg *aggregator, dataStart, lastRow, col int, cellStyles []int) (int, error) {
+	row := lastRow + 1
+	if agg.grouped {
+		if err := e.writeSubtotalRow(fw, agg, row, lastRow, col, cellStyles); err != nil {
+			return 0, err
+		}
+		row++
+	}
+	values, err := agg.excelTotalRow(dataStart, row-1, col, cellStyles)
+	if err != nil {
+		return 0, err
+	}
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return 0, err
+	}
+	return row, fw.SetRow(cell, values)
+}
+
+// setColStyle 设置列相关属性，返回每列单元格样式，0表示不设置，fw为空时不设置列宽
+func (e *Excel) setColStyle(sheet string, colStart int, fp *excelize.File, fw *excelize.StreamWriter) ([]int, error) {
+	cellStyles := make([]int, e.columns.nums)
 	//设置列宽度
 	for i, h := range e.columns.headers {
 		var colName string
 		colName, err := excelize.ColumnNumberToName(colStart + i)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		//设置宽度
-		if h.ColWidth > 0 {
-			if err = fp.SetColWidth(DefaultSheetName, colName, colName, h.ColWidth); err != nil {
-				return err
+		if h.ColWidth > 0 && fw != nil {
+			if err = fw.SetColWidth(colStart+i, colStart+i, h.ColWidth); err != nil {
+				return nil, err
 			}
 		}
 		//设置列样式
 		if h.ColStyle != nil {
 			styleId, err := fp.NewStyle(h.ColStyle)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			if err = fp.SetColStyle(DefaultSheetName, colName, styleId); err != nil {
-				return err
+			if err = fp.SetColStyle(sheet, colName, styleId); err != nil {
+				return nil, err
 			}
 		}
+		//设置单元格数字格式
+		if cellStyles[i], err = e.formatter.newStyle(fp, h); err != nil {
+			return nil, err
+		}
+	}
+	return cellStyles, nil
+}
+
+// setCellStyles 给行数据设置单元格样式，CellRender已返回excelize.Cell的保持不变
+func setCellStyles(values []any, cellStyles []int) {
+	for i := range values {
+		if cellStyles[i] == 0 || values[i] == nil {
+			continue
+		}
+		switch values[i].(type) {
+		case excelize.Cell, *excelize.Cell:
+			continue
+		}
+		values[i] = excelize.Cell{StyleID: cellStyles[i], Value: values[i]}
 	}
-	return nil
 }
 
 func (e *Excel) TestProcessRow(rowData reflect.Value, row int) []any {
@@ -282,10 +472,8 @@ func (e *Excel) processRow(rowData reflect.Value, rawData any, row int) []any {
 	switch rowData.Type().Kind() {
 	case reflect.Ptr:
 		return e.processRow(rowData.Elem(), rawData, row)
-	case reflect.Map:
-		return e.processRowFromMap(rowData, rawData, row)
-	case reflect.Struct:
-		return e.processRowFromStruct(rowData, rawData, row)
+	case reflect.Map, reflect.Struct:
+		return e.processRowFromField(rowData, rawData, row)
 	case reflect.Slice:
 		return e.processRowFromSlice(rowData, rawData, row)
 	default:
@@ -293,45 +481,12 @@ func (e *Excel) processRow(rowData reflect.Value, rawData any, row int) []any {
 	}
 }
 
-func (e *Excel) processRowFromMap(rowData reflect.Value, rawData any, row int) []any {
+// processRowFromField 按字段路径取值，支持map、struct的多级嵌套字段及无参方法
+func (e *Excel) processRowFromField(rowData reflect.Value, rawData any, row int) []any {
 	_rowData := make([]any, e.columns.nums)
-	for i := range e.columns.fields {
-		field := e.columns.fields[i]
-		val := rowData.MapIndex(reflect.ValueOf(field))
-		_rowData[i] = e.processCell(field, val, rawData, row, e.options.colStart+i+1)
-	}
-	return _rowData
-}
-
-func (e *Excel) processRowFromStruct(rowData reflect.Value, rawData any, row int) []any {
-	_rowData := make([]any, e.columns.nums)
-	typ := rowData.Type()
-	gets := 0
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		key := field.Name
-		//读取tag
-		if k, ok := typ.Field(i).Tag.Lookup(TagName); ok {
-			key = k
-		}
-		if idx, ok := e.columns.keyIndex[key]; ok {
-			gets++
-			e.columns.nilKeyIndex[key] = row
-			_rowData[idx] = e.processCell(key, rowData.Field(i), rawData, row, e.options.colStart+idx+1)
-		}
-		if gets == e.columns.nums {
-			break
-		}
-	}
-	//没取到的字段得补充下
-	if gets != e.columns.nums {
-		var idx int
-		for k, v := range e.columns.nilKeyIndex {
-			if v != row {
-				idx = e.columns.keyIndex[k]
-				_rowData[idx] = e.processCell(k, nilValue, rawData, row, e.options.colStart+idx+1)
-			}
-		}
+	accessors := e.columns.getAccessors(rowData.Type())
+	for i := range accessors {
+		_rowData[i] = e.processCell(i, accessors[i](rowData), rawData, row, e.options.colStart+i+1)
 	}
 	return _rowData
 }
@@ -342,21 +497,23 @@ func (e *Excel) processRowFromSlice(rowData reflect.Value, rawData any, row int)
 	l := rowData.Len()
 	for i := 0; i < e.columns.nums; i++ {
 		if i < l {
-			_rowData[i] = e.processCell(e.columns.fields[i], rowData.Index(i), rawData, row, e.options.colStart+i+1)
+			_rowData[i] = e.processCell(i, rowData.Index(i), rawData, row, e.options.colStart+i+1)
 		} else {
-			_rowData[i] = e.processCell(e.columns.fields[i], nilValue, rawData, row, e.options.colStart+i+1)
+			_rowData[i] = e.processCell(i, nilValue, rawData, row, e.options.colStart+i+1)
 		}
 	}
 	return _rowData
 }
 
-func (e *Excel) processCell(field string, val reflect.Value, rawData any, row, col int) any {
+// processCell 处理第idx列的单元格，多列可以使用相同的Field，按列位置取表头
+func (e *Excel) processCell(idx int, val reflect.Value, rawData any, row, col int) any {
 	var v any
-	if val.IsValid() {
+	if val.IsValid() && val.CanInterface() {
 		v = val.Interface()
 	}
-	if e.columns.columnRenders[field] != nil {
-		return e.columns.columnRenders[field](rawData, v, row, col)
+	h := e.columns.headers[idx]
+	if h.CellRender != nil {
+		v = h.CellRender(rawData, v, row, col)
 	}
-	return v
+	return e.formatter.excelValue(h, v)
 }
@@ -17,6 +17,9 @@ const MaxRows = 1000000          //最大导出数据,防止dataProvider出错
 const ExcelSuffix = "xlsx"
 const CsvSuffix = "csv"
 const ZipSuffix = "zip"
+const JsonLinesSuffix = "jsonl"
+const HtmlSuffix = "html"
+const MarkdownSuffix = "md"
 
 // ToExcelStream 导出excel的快捷方法
 func ToExcelStream(ctx context.Context, h Headers, dp DataProvider, w io.Writer, opt ...Option) (int64, error) {
@@ -47,3 +50,48 @@ func ToCsvFile(ctx context.Context, h Headers, dp DataProvider, opt ...Option) (
 func ToCsvStorage(ctx context.Context, h Headers, dp DataProvider, fs excel.FileStorage, opt ...Option) (string, error) {
 	return NewCsv(h, dp, opt...).ExportToStorage(ctx, fs)
 }
+
+// ToJsonLinesStream 导出json lines的快捷方法
+func ToJsonLinesStream(ctx context.Context, h Headers, dp DataProvider, w io.Writer, opt ...Option) (int64, error) {
+	return NewJsonLines(h, dp, opt...).ExportTo(ctx, w)
+}
+
+// ToJsonLinesFile 导出json lines的快捷方法
+func ToJsonLinesFile(ctx context.Context, h Headers, dp DataProvider, opt ...Option) (string, error) {
+	return NewJsonLines(h, dp, opt...).Export(ctx)
+}
+
+// ToJsonLinesStorage 导出json lines到oss的快捷方法
+func ToJsonLinesStorage(ctx context.Context, h Headers, dp DataProvider, fs excel.FileStorage, opt ...Option) (string, error) {
+	return NewJsonLines(h, dp, opt...).ExportToStorage(ctx, fs)
+}
+
+// ToHtmlStream 导出html的快捷方法
+func ToHtmlStream(ctx context.Context, h Headers, dp DataProvider, w io.Writer, opt ...Option) (int64, error) {
+	return NewHtml(h, dp, opt...).ExportTo(ctx, w)
+}
+
+// ToHtmlFile 导出html的快捷方法
+func ToHtmlFile(ctx context.Context, h Headers, dp DataProvider, opt ...Option) (string, error) {
+	return NewHtml(h, dp, opt...).Export(ctx)
+}
+
+// ToHtmlStorage 导出html到oss的快捷方法
+func ToHtmlStorage(ctx context.Context, h Headers, dp DataProvider, fs excel.FileStorage, opt ...Option) (string, error) {
+	return NewHtml(h, dp, opt...).ExportToStorage(ctx, fs)
+}
+
+// ToMarkdownStream 导出markdown的快捷方法
+func ToMarkdownStream(ctx context.Context, h Headers, dp DataProvider, w io.Writer, opt ...Option) (int64, error) {
+	return NewMarkdown(h, dp, opt...).ExportTo(ctx, w)
+}
+
+// ToMarkdownFile 导出markdown的快捷方法
+func ToMarkdownFile(ctx context.Context, h Headers, dp DataProvider, opt ...Option) (string, error) {
+	return NewMarkdown(h, dp, opt...).Export(ctx)
+}
+
+// ToMarkdownStorage 导出markdown到oss的快捷方法
+func ToMarkdownStorage(ctx context.Context, h Headers, dp DataProvider, fs excel.FileStorage, opt ...Option) (string, error) {
+	return NewMarkdown(h, dp, opt...).ExportToStorage(ctx, fs)
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/opdss/common/contracts/excel"
+)
+
+var _ excel.Exporter = (*Html)(nil)
+
+// Html 导出html表格，http、https、mailto超链接单元格输出为<a>标签，小计、合计行带 class="summary"
+type Html struct {
+	*textExporter
+}
+
+func NewHtml(h Headers, dp DataProvider, opts ...Option) *Html {
+	return &Html{newTextExporter(htmlFormat{}, h, dp, opts...)}
+}
+
+type htmlFormat struct{}
+
+func (htmlFormat) suffix() string {
+	return HtmlSuffix
+}
+
+func (htmlFormat) newWriter(w io.Writer, c *columns, f *cellFormatter, _ *options) textWriter {
+	return &htmlWriter{w: bufio.NewWriter(w), columns: c, formatter: f}
+}
+
+type htmlWriter struct {
+	w         *bufio.Writer
+	columns   *columns
+	formatter *cellFormatter
+}
+
+func (w *htmlWriter) writeHeader() error {
+	_, _ = w.w.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"></head>\n<body>\n<table border=\"1\">\n<thead>\n<tr>")
+	for _, title := range w.columns.titles {
+		_, _ = w.w.WriteString("<th>" + html.EscapeString(title) + "</th>")
+	}
+	_, err := w.w.WriteString("</tr>\n</thead>\n<tbody>\n")
+	return err
+}
+
+func (w *htmlWriter) writeRow(values []any) error {
+	_, _ = w.w.WriteString("<tr>")
+	for i, h := range w.columns.headers {
+		_, _ = w.w.WriteString("<td>" + w.cell(h, values[i]) + "</td>")
+	}
+	_, err := w.w.WriteString("</tr>\n")
+	return err
+}
+
+func (w *htmlWriter) writeSummary(values []string) error {
+	_, _ = w.w.WriteString("<tr class=\"summary\">")
+	for _, v := range values {
+		_, _ = w.w.WriteString("<th>" + html.EscapeString(v) + "</th>")
+	}
+	_, err := w.w.WriteString("</tr>\n")
+	return err
+}
+
+func (w *htmlWriter) close() error {
+	_, _ = w.w.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	return w.w.Flush()
+}
+
+// cell 单元格html内容
+func (w *htmlWriter) cell(h Header, v any) string {
+	text := html.EscapeString(w.formatter.textValue(h, v))
+	link, ok := v.(Hyperlink)
+	if p, _ok := v.(*Hyperlink); _ok && p != nil {
+		link, ok = *p, true
+	}
+	if !ok || !safeLinkUrl(link.Url) {
+		return text
+	}
+	a := "<a href=\"" + html.EscapeString(link.Url) + "\""
+	if link.Tooltip != "" {
+		a += " title=\"" + html.EscapeString(link.Tooltip) + "\""
+	}
+	return a + ">" + text + "</a>"
+}
+
+// safeLinkUrl 只输出http、https、mailto链接，javascript:、data:等链接作为文本输出
+func safeLinkUrl(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
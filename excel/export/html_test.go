@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opdss/common/iterator"
+)
+
+// textTestData html、markdown、json lines共用的测试数据，包含需要转义的内容、超链接、空时间及汇总列
+func textTestData() (Headers, []any) {
+	h := Headers{
+		{Field: "name", Title: "名称|<b>", CellRender: func(row any, v any, _, _ int) any {
+			if u := row.(map[string]any)["url"].(string); u != "" {
+				return Hyperlink{Text: v, Url: u, Tooltip: "\"打开\""}
+			}
+			return v
+		}},
+		{Field: "at", Title: "时间"},
+		{Field: "amount", Title: "金额", Aggregate: AggregateSum},
+	}
+	at := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	data := []any{
+		map[string]any{"name": "a<b>&[1]", "url": "https://example.com/a b?x=(1)|2", "at": at, "amount": 1.5},
+		map[string]any{"name": "x|y\nz", "url": "javascript:alert(1)", "at": time.Time{}, "amount": 2},
+		map[string]any{"name": "mail", "url": "mailto:a@example.com", "at": at, "amount": nil},
+	}
+	return h, data
+}
+
+func TestHtml(t *testing.T) {
+	h, data := textTestData()
+	const head = "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"></head>\n<body>\n<table border=\"1\">\n<thead>\n" +
+		"<tr><th>名称|&lt;b&gt;</th><th>时间</th><th>金额</th></tr>\n</thead>\n<tbody>\n"
+	const tail = "<tr class=\"summary\"><th>合计</th><th></th><th>3.5</th></tr>\n</tbody>\n</table>\n</body>\n</html>\n"
+	for _, test := range []struct {
+		opts     []Option
+		expected string
+	}{
+		//javascript:链接作为文本输出
+		{nil, head +
+			"<tr><td><a href=\"https://example.com/a b?x=(1)|2\" title=\"&#34;打开&#34;\">a&lt;b&gt;&amp;[1]</a></td><td>2024-03-05 10:00:00 +0000 UTC</td><td>1.5</td></tr>\n" +
+			"<tr><td>x|y\nz</td><td>0001-01-01 00:00:00 +0000 UTC</td><td>2</td></tr>\n" +
+			"<tr><td><a href=\"mailto:a@example.com\" title=\"&#34;打开&#34;\">mail</a></td><td>2024-03-05 10:00:00 +0000 UTC</td><td></td></tr>\n" + tail},
+		{[]Option{WithLocale(LocaleEnUS)}, head +
+			"<tr><td><a href=\"https://example.com/a b?x=(1)|2\" title=\"&#34;打开&#34;\">a&lt;b&gt;&amp;[1]</a></td><td>03/05/2024 10:00:00</td><td>1.5</td></tr>\n" +
+			"<tr><td>x|y\nz</td><td></td><td>2</td></tr>\n" +
+			"<tr><td><a href=\"mailto:a@example.com\" title=\"&#34;打开&#34;\">mail</a></td><td>03/05/2024 10:00:00</td><td></td></tr>\n" + tail},
+	} {
+		var buf bytes.Buffer
+		if _, err := NewHtml(h, iterator.NewSliceIterator(data), test.opts...).ExportTo(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opdss/common/contracts/excel"
+)
+
+var ErrJobNotFound = errors.New("export job not found")
+
+// JobStatus 导出任务状态
+type JobStatus string
+
+const (
+	JobPending  JobStatus = "pending"  //等待执行
+	JobRunning  JobStatus = "running"  //执行中
+	JobDone     JobStatus = "done"     //导出完成
+	JobFailed   JobStatus = "failed"   //导出失败
+	JobCanceled JobStatus = "canceled" //已取消
+)
+
+// Job 异步导出任务
+type Job struct {
+	Id        string    `json:"id" gorm:"primaryKey;size:36"`
+	Name      string    `json:"name" gorm:"size:255"`
+	Status    JobStatus `json:"status" gorm:"size:16;index"`
+	Url       string    `json:"url" gorm:"size:1024"` //导出完成后的下载地址
+	Rows      int       `json:"rows"`                 //已导出数据行数
+	Files     int       `json:"files"`                //已生成文件数
+	Error     string    `json:"error" gorm:"size:1024"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Job) TableName() string {
+	return "export_jobs"
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// JobStore 导出任务状态存储，多实例部署时使用共享存储即可在任意实例查询、取消任务
+type JobStore interface {
+	Save(ctx context.Context, job *Job) error
+	// Get 任务不存在时返回 ErrJobNotFound
+	Get(ctx context.Context, id string) (*Job, error)
+}
+
+// DefaultJobProgressInterval 默认保存任务进度的最小时间间隔
+const DefaultJobProgressInterval = time.Second
+
+type JobOption func(m *JobManager)
+
+// WithJobTimeout 单个任务最长执行时间
+func WithJobTimeout(d time.Duration) JobOption {
+	return func(m *JobManager) {
+		m.timeout = d
+	}
+}
+
+// WithJobProgressInterval 导出进度回调时至少间隔d才保存进度并检查任务是否已取消，d<=0时使用默认值
+func WithJobProgressInterval(d time.Duration) JobOption {
+	return func(m *JobManager) {
+		m.progressInterval = d
+	}
+}
+
+// WithJobConcurrency 同时执行的任务数量，超出的任务保持pending等待
+func WithJobConcurrency(n int) JobOption {
+	return func(m *JobManager) {
+		if n > 0 {
+			m.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// JobManager 异步导出任务管理，在后台执行 Exporter.ExportToStorage 并记录任务状态
+type JobManager struct {
+	store            JobStore
+	fs               excel.FileStorage
+	timeout          time.Duration
+	progressInterval time.Duration
+	sem              chan struct{}
+	cancels          sync.Map   //任务id => context.CancelFunc
+	mu               sync.Mutex //任务状态变更时先比较存储中的状态再保存
+	wg               sync.WaitGroup
+}
+
+func NewJobManager(store JobStore, fs excel.FileStorage, opts ...JobOption) *JobManager {
+	m := &JobManager{store: store, fs: fs}
+	for i := range opts {
+		opts[i](m)
+	}
+	if m.progressInterval <= 0 {
+		m.progressInterval = DefaultJobProgressInterval
+	}
+	return m
+}
+
+// Submit 提交导出任务，立即返回pending状态的任务，通过 Get 查询进度及结果
+// exporter实现了 OnProgress 时会在导出过程中更新已导出行数及文件数
+func (m *JobManager) Submit(ctx context.Context, name string, exporter excel.Exporter) (*Job, error) {
+	now := time.Now()
+	job := &Job{
+		Id:        uuid.New().String(),
+		Name:      name,
+		Status:    JobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+	//任务不随请求结束而取消
+	var jobCtx context.Context
+	var cancel context.CancelFunc
+	if m.timeout > 0 {
+		jobCtx, cancel = context.WithTimeout(context.Background(), m.timeout)
+	} else {
+		jobCtx, cancel = context.WithCancel(context.Background())
+	}
+	m.cancels.Store(job.Id, cancel)
+	m.wg.Add(1)
+	_job := *job
+	go m.run(jobCtx, cancel, &_job, exporter)
+	return job, nil
+}
+
+// Get 查询任务
+func (m *JobManager) Get(ctx context.Context, id string) (*Job, error) {
+	return m.store.Get(ctx, id)
+}
+
+// Cancel 取消任务，任务在其他实例执行时，由执行实例在下次保存进度时检查并取消
+func (m *JobManager) Cancel(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Finished() {
+		return nil
+	}
+	job.Status = JobCanceled
+	job.UpdatedAt = time.Now()
+	if err = m.store.Save(ctx, job); err != nil {
+		return err
+	}
+	if cancel, ok := m.cancels.Load(id); ok {
+		cancel.(context.CancelFunc)()
+	}
+	return nil
+}
+
+// Wait 等待当前实例所有任务结束
+func (m *JobManager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *JobManager) run(ctx context.Context, cancel context.CancelFunc, job *Job, exporter excel.Exporter) {
+	defer func() {
+		cancel()
+		m.cancels.Delete(job.Id)
+		m.wg.Done()
+	}()
+	if m.sem != nil {
+		select {
+		case m.sem <- struct{}{}:
+			defer func() {
+				<-m.sem
+			}()
+		case <-ctx.Done():
+			m.finish(job, JobPending, ctx.Err())
+			return
+		}
+	}
+	//等待期间已被取消
+	job.Status = JobRunning
+	if ok, _ := m.transition(job, JobPending); !ok {
+		return
+	}
+	if p, ok := exporter.(interface{ OnProgress(ProgressFunc) }); ok {
+		saved := time.Now()
+		p.OnProgress(func(pg Progress) {
+			job.Rows, job.Files = pg.Rows, pg.Files
+			//按时间间隔保存进度，最终进度在任务结束时保存
+			if time.Since(saved) < m.progressInterval {
+				return
+			}
+			saved = time.Now()
+			//其他实例取消了任务
+			if ok, err := m.transition(job, JobRunning); err == nil && !ok {
+				cancel()
+			}
+		})
+	}
+	url, err := exporter.ExportToStorage(ctx, m.fs)
+	if t, ok := exporter.(interface{ Total() int }); ok {
+		job.Rows = t.Total()
+	}
+	job.Url = url
+	m.finish(job, JobRunning, err)
+}
+
+// finish 记录任务结果，任务状态仍为from时才保存，不会覆盖已取消的任务
+func (m *JobManager) finish(job *Job, from JobStatus, err error) {
+	switch {
+	case err == nil:
+		job.Status = JobDone
+	case errors.Is(err, context.Canceled):
+		job.Status = JobCanceled
+	default:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	}
+	if err != nil {
+		job.Url = ""
+	}
+	_, _ = m.transition(job, from)
+}
+
+// transition 存储中的任务状态为from时保存job，状态已变化(如已取消)时返回false
+func (m *JobManager) transition(job *Job, from JobStatus) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	//任务执行的context可能已取消，状态保存单独设置超时
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stored, err := m.store.Get(ctx, job.Id)
+	if err != nil {
+		log.Println("export job get error", job.Id, err.Error())
+		return false, err
+	}
+	if stored.Status != from {
+		return false, nil
+	}
+	job.UpdatedAt = time.Now()
+	if err = m.store.Save(ctx, job); err != nil {
+		log.Println("export job save error", job.Id, err.Error())
+		return false, err
+	}
+	return true, nil
+}
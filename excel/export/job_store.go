@@ -0,0 +1,88 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// DefaultJobKeyPrefix DefaultJobTTL redis任务存储默认key前缀及过期时间
+const DefaultJobKeyPrefix = "export:job:"
+const DefaultJobTTL = 7 * 24 * time.Hour
+
+var _ JobStore = (*RedisJobStore)(nil)
+var _ JobStore = (*GormJobStore)(nil)
+
+// RedisJobStore 基于redis的任务存储，任务以json保存，过期自动删除
+type RedisJobStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisJobStore prefix为空时使用 DefaultJobKeyPrefix，ttl<=0时使用 DefaultJobTTL
+func NewRedisJobStore(client *redis.Client, prefix string, ttl time.Duration) *RedisJobStore {
+	if prefix == "" {
+		prefix = DefaultJobKeyPrefix
+	}
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+	return &RedisJobStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisJobStore) Save(ctx context.Context, job *Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+job.Id, b, s.ttl).Err()
+}
+
+func (s *RedisJobStore) Get(ctx context.Context, id string) (*Job, error) {
+	b, err := s.client.Get(ctx, s.prefix+id).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	job := &Job{}
+	if err = json.Unmarshal(b, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GormJobStore 基于数据库的任务存储，表名 export_jobs
+type GormJobStore struct {
+	db *gorm.DB
+}
+
+func NewGormJobStore(db *gorm.DB) *GormJobStore {
+	return &GormJobStore{db: db}
+}
+
+// Migrate 创建或更新任务表
+func (s *GormJobStore) Migrate() error {
+	return s.db.AutoMigrate(&Job{})
+}
+
+func (s *GormJobStore) Save(ctx context.Context, job *Job) error {
+	return s.db.WithContext(ctx).Save(job).Error
+}
+
+func (s *GormJobStore) Get(ctx context.Context, id string) (*Job, error) {
+	job := &Job{}
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Take(job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
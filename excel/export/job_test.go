@@ -0,0 +1,153 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opdss/common/contracts/excel"
+	"github.com/opdss/common/iterator"
+)
+
+type testJobStore struct {
+	jobs  sync.Map
+	saves atomic.Int32
+}
+
+func (s *testJobStore) Save(_ context.Context, job *Job) error {
+	s.saves.Add(1)
+	_job := *job
+	s.jobs.Store(job.Id, &_job)
+	return nil
+}
+
+func (s *testJobStore) Get(_ context.Context, id string) (*Job, error) {
+	job, ok := s.jobs.Load(id)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	_job := *job.(*Job)
+	return &_job, nil
+}
+
+type testFileStorage struct{}
+
+func (testFileStorage) PutStream(_ context.Context, _ string, rs io.Reader) error {
+	_, err := io.Copy(io.Discard, rs)
+	return err
+}
+
+func (testFileStorage) Url(fileKey string) string {
+	return "https://example.com/" + fileKey
+}
+
+func TestJobManager(t *testing.T) {
+	data := make([]any, 25)
+	for i := range data {
+		data[i] = map[string]any{"id": i}
+	}
+	var progress []Progress
+	exporter := NewCsv(Headers{{Field: "id", Title: "ID"}}, iterator.NewSliceIterator(data),
+		WithFilename("job"), WithSingleFileMaxRows(10), WithProgress(func(p Progress) {
+			progress = append(progress, p)
+		}, 10))
+	m := NewJobManager(&testJobStore{}, testFileStorage{})
+	job, err := m.Submit(context.Background(), "users", exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobPending {
+		t.Fatalf("expected pending, got %s", job.Status)
+	}
+	m.Wait()
+	job, err = m.Get(context.Background(), job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobDone || job.Rows != 25 || job.Files != 3 || job.Url != "https://example.com/job_0.zip" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	last := progress[len(progress)-1]
+	if last.Rows != 25 || last.Files != 3 {
+		t.Fatalf("unexpected progress %+v", last)
+	}
+	if _, err = m.Get(context.Background(), "missing"); err != ErrJobNotFound {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// blockingExporter 导出时等待context取消，取消后仍返回导出成功
+type blockingExporter struct {
+	started chan struct{}
+	ctxErr  error
+}
+
+func (e *blockingExporter) Export(context.Context) (string, error) {
+	return "", nil
+}
+
+func (e *blockingExporter) ExportTo(context.Context, io.Writer) (int64, error) {
+	return 0, nil
+}
+
+func (e *blockingExporter) ExportToStorage(ctx context.Context, _ excel.FileStorage) (string, error) {
+	close(e.started)
+	<-ctx.Done()
+	e.ctxErr = ctx.Err()
+	return "https://example.com/late.csv", nil
+}
+
+func TestJobManagerCancel(t *testing.T) {
+	exporter := &blockingExporter{started: make(chan struct{})}
+	m := NewJobManager(&testJobStore{}, testFileStorage{})
+	job, err := m.Submit(context.Background(), "users", exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-exporter.started
+	if err = m.Cancel(context.Background(), job.Id); err != nil {
+		t.Fatal(err)
+	}
+	m.Wait()
+	if !errors.Is(exporter.ctxErr, context.Canceled) {
+		t.Fatalf("expected exporter context canceled, got %v", exporter.ctxErr)
+	}
+	//取消后才完成的任务不会覆盖取消状态
+	job, err = m.Get(context.Background(), job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobCanceled || job.Url != "" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+}
+
+func TestJobManagerProgressInterval(t *testing.T) {
+	data := make([]any, 100)
+	for i := range data {
+		data[i] = map[string]any{"id": i}
+	}
+	exporter := NewCsv(Headers{{Field: "id", Title: "ID"}}, iterator.NewSliceIterator(data), WithProgress(nil, 1))
+	store := &testJobStore{}
+	m := NewJobManager(store, testFileStorage{}, WithJobProgressInterval(time.Hour))
+	job, err := m.Submit(context.Background(), "users", exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Wait()
+	//每行都回调进度，间隔内只保存pending、running及结束状态
+	if n := store.saves.Load(); n != 3 {
+		t.Fatalf("expected 3 saves, got %d", n)
+	}
+	job, err = m.Get(context.Background(), job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobDone || job.Rows != 100 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+}
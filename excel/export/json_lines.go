@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/opdss/common/contracts/excel"
+	"github.com/spf13/cast"
+	"github.com/xuri/excelize/v2"
+)
+
+var _ excel.Exporter = (*JsonLines)(nil)
+
+// JsonLines 导出json lines(ndjson)，每行一个json对象，key为表头Field，不输出表头及小计、合计行
+type JsonLines struct {
+	*textExporter
+}
+
+func NewJsonLines(h Headers, dp DataProvider, opts ...Option) *JsonLines {
+	return &JsonLines{newTextExporter(jsonLinesFormat{}, h, dp, opts...)}
+}
+
+type jsonLinesFormat struct{}
+
+func (jsonLinesFormat) suffix() string {
+	return JsonLinesSuffix
+}
+
+func (jsonLinesFormat) newWriter(w io.Writer, c *columns, f *cellFormatter, _ *options) textWriter {
+	return &jsonLinesWriter{w: bufio.NewWriter(w), columns: c, formatter: f}
+}
+
+type jsonLinesWriter struct {
+	w         *bufio.Writer
+	columns   *columns
+	formatter *cellFormatter
+}
+
+func (w *jsonLinesWriter) writeHeader() error {
+	return nil
+}
+
+// writeRow 按表头顺序写入json对象，encoding/json编码map时会对key排序，所以逐个字段写入
+func (w *jsonLinesWriter) writeRow(values []any) error {
+	_ = w.w.WriteByte('{')
+	for i, h := range w.columns.headers {
+		if i > 0 {
+			_ = w.w.WriteByte(',')
+		}
+		key, err := json.Marshal(h.Field)
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(w.jsonValue(h, values[i]))
+		if err != nil {
+			return err
+		}
+		_, _ = w.w.Write(key)
+		_ = w.w.WriteByte(':')
+		_, _ = w.w.Write(val)
+	}
+	_, err := w.w.WriteString("}\n")
+	return err
+}
+
+func (w *jsonLinesWriter) writeSummary([]string) error {
+	return nil
+}
+
+func (w *jsonLinesWriter) close() error {
+	return w.w.Flush()
+}
+
+// jsonValue 转换成写入json的值，数字、bool保持原类型，日期按区域格式转为文本
+func (w *jsonLinesWriter) jsonValue(h Header, v any) any {
+	v, _ = richText(v)
+	if c, ok := v.(excelize.Cell); ok {
+		v = c.Value
+	} else if c, ok := v.(*excelize.Cell); ok && c != nil {
+		v = c.Value
+	}
+	v = normalizeValue(v)
+	if v == nil {
+		return nil
+	}
+	switch h.CellType {
+	case CellTypeText:
+		return cast.ToString(v)
+	case CellTypeInteger:
+		if n, err := cast.ToInt64E(v); err == nil {
+			return n
+		}
+	case CellTypeCurrency, CellTypePercent:
+		if n, err := cast.ToFloat64E(v); err == nil {
+			return n
+		}
+	case CellTypeDate, CellTypeDateTime:
+		if s := w.formatter.textValue(h, v); s != "" {
+			return s
+		}
+		return nil
+	default:
+		switch _v := v.(type) {
+		case time.Time:
+			if _v.IsZero() {
+				return ""
+			}
+			return _v.Format(w.formatter.locale.DateTimeLayout)
+		case []byte:
+			return string(_v)
+		case []excelize.RichTextRun:
+			var text string
+			for _, r := range _v {
+				text += r.Text
+			}
+			return text
+		}
+	}
+	return v
+}
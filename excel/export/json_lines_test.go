@@ -0,0 +1,33 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+)
+
+func TestJsonLines(t *testing.T) {
+	h, data := textTestData()
+	for _, test := range []struct {
+		opts     []Option
+		expected string
+	}{
+		//不输出表头及合计行，超链接只输出文本，数字保持原类型
+		{nil, "{\"name\":\"a\\u003cb\\u003e\\u0026[1]\",\"at\":\"2024-03-05 10:00:00\",\"amount\":1.5}\n" +
+			"{\"name\":\"x|y\\nz\",\"at\":\"\",\"amount\":2}\n" +
+			"{\"name\":\"mail\",\"at\":\"2024-03-05 10:00:00\",\"amount\":null}\n"},
+		{[]Option{WithLocale(LocaleEnUS)}, "{\"name\":\"a\\u003cb\\u003e\\u0026[1]\",\"at\":\"03/05/2024 10:00:00\",\"amount\":1.5}\n" +
+			"{\"name\":\"x|y\\nz\",\"at\":\"\",\"amount\":2}\n" +
+			"{\"name\":\"mail\",\"at\":\"03/05/2024 10:00:00\",\"amount\":null}\n"},
+	} {
+		var buf bytes.Buffer
+		if _, err := NewJsonLines(h, iterator.NewSliceIterator(data), test.opts...).ExportTo(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, buf.String())
+		}
+	}
+}
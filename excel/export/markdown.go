@@ -0,0 +1,100 @@
+package export
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/opdss/common/contracts/excel"
+)
+
+var _ excel.Exporter = (*Markdown)(nil)
+
+// Markdown 导出markdown表格，http、https、mailto超链接单元格输出为 [text](url)，小计、合计行加粗
+type Markdown struct {
+	*textExporter
+}
+
+func NewMarkdown(h Headers, dp DataProvider, opts ...Option) *Markdown {
+	return &Markdown{newTextExporter(markdownFormat{}, h, dp, opts...)}
+}
+
+type markdownFormat struct{}
+
+func (markdownFormat) suffix() string {
+	return MarkdownSuffix
+}
+
+func (markdownFormat) newWriter(w io.Writer, c *columns, f *cellFormatter, _ *options) textWriter {
+	return &markdownWriter{w: bufio.NewWriter(w), columns: c, formatter: f}
+}
+
+// markdownEscaper 转义表格中的竖线，换行转为<br>
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// markdownLinkTextEscaper 超链接文本中的方括号
+var markdownLinkTextEscaper = strings.NewReplacer("[", "\\[", "]", "\\]")
+
+// markdownUrlEscaper 超链接地址中会截断链接或表格的字符
+var markdownUrlEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "|", "%7C")
+
+type markdownWriter struct {
+	w         *bufio.Writer
+	columns   *columns
+	formatter *cellFormatter
+}
+
+func (w *markdownWriter) writeHeader() error {
+	titles := make([]string, w.columns.nums)
+	for i, title := range w.columns.titles {
+		titles[i] = markdownEscaper.Replace(title)
+	}
+	if err := w.writeLine(titles); err != nil {
+		return err
+	}
+	sep := make([]string, w.columns.nums)
+	for i := range sep {
+		sep[i] = "---"
+	}
+	return w.writeLine(sep)
+}
+
+func (w *markdownWriter) writeRow(values []any) error {
+	cells := make([]string, len(values))
+	for i, h := range w.columns.headers {
+		cells[i] = w.cell(h, values[i])
+	}
+	return w.writeLine(cells)
+}
+
+func (w *markdownWriter) writeSummary(values []string) error {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		if v != "" {
+			cells[i] = "**" + markdownEscaper.Replace(v) + "**"
+		}
+	}
+	return w.writeLine(cells)
+}
+
+func (w *markdownWriter) close() error {
+	return w.w.Flush()
+}
+
+func (w *markdownWriter) writeLine(cells []string) error {
+	_, err := w.w.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	return err
+}
+
+// cell 单元格markdown内容
+func (w *markdownWriter) cell(h Header, v any) string {
+	text := markdownEscaper.Replace(w.formatter.textValue(h, v))
+	link, ok := v.(Hyperlink)
+	if p, _ok := v.(*Hyperlink); _ok && p != nil {
+		link, ok = *p, true
+	}
+	if !ok || !safeLinkUrl(link.Url) {
+		return text
+	}
+	return "[" + markdownLinkTextEscaper.Replace(text) + "](" + markdownUrlEscaper.Replace(link.Url) + ")"
+}
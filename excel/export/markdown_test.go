@@ -0,0 +1,38 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+)
+
+func TestMarkdown(t *testing.T) {
+	h, data := textTestData()
+	const head = "| 名称\\|<b> | 时间 | 金额 |\n| --- | --- | --- |\n"
+	for _, test := range []struct {
+		opts     []Option
+		expected string
+	}{
+		//链接地址中的括号、竖线编码，链接文本中的方括号转义
+		{nil, head +
+			"| [a<b>&\\[1\\]](https://example.com/a%20b?x=%281%29%7C2) | 2024-03-05 10:00:00 +0000 UTC | 1.5 |\n" +
+			"| x\\|y<br>z | 0001-01-01 00:00:00 +0000 UTC | 2 |\n" +
+			"| [mail](mailto:a@example.com) | 2024-03-05 10:00:00 +0000 UTC |  |\n" +
+			"| **合计** |  | **3.5** |\n"},
+		{[]Option{WithLocale(LocaleEnUS), WithAggregateTitle("Total", "")}, head +
+			"| [a<b>&\\[1\\]](https://example.com/a%20b?x=%281%29%7C2) | 03/05/2024 10:00:00 | 1.5 |\n" +
+			"| x\\|y<br>z |  | 2 |\n" +
+			"| [mail](mailto:a@example.com) | 03/05/2024 10:00:00 |  |\n" +
+			"| **Total** |  | **3.5** |\n"},
+	} {
+		var buf bytes.Buffer
+		if _, err := NewMarkdown(h, iterator.NewSliceIterator(data), test.opts...).ExportTo(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, buf.String())
+		}
+	}
+}
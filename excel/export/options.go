@@ -65,14 +65,52 @@ func WithForceSingleFile() Option {
 	}
 }
 
+// WithLocale 设置区域格式，影响日期、金额等单元格的显示格式，默认 LocaleZhCN，
+// 设置后导出csv等文本格式时，未指定CellType的时间也按 Locale.DateTimeLayout 输出
+func WithLocale(locale Locale) Option {
+	return func(opt *options) {
+		opt.locale = &locale
+	}
+}
+
+// WithGroupBy 按字段分组，分组值变化时输出小计行，需要数据已按该字段排序且表头配置了Aggregate
+func WithGroupBy(field string) Option {
+	return func(opt *options) {
+		opt.groupBy = field
+	}
+}
+
+// WithAggregateTitle 设置合计行、小计行标题，为空时使用默认标题
+func WithAggregateTitle(total, subtotal string) Option {
+	return func(opt *options) {
+		if total != "" {
+			opt.totalTitle = total
+		}
+		if subtotal != "" {
+			opt.subtotalTitle = subtotal
+		}
+	}
+}
+
 type options struct {
-	maxRows           int    //导出最大数量，避免数据提供商出错无限数据
-	singleFileMaxRows int    //单个文件导出最大数量，超出会自动切分
-	filename          string //文件名，不要加后缀，会自动加
-	rowStart          int    //从第几行开始写数据，仅导出 excel支持
-	colStart          int    //从第几列开始写数据，仅导出 excel支持
-	forceZip          bool   //是否强制zip压缩，即导出只有一个文件时也压缩成zip
-	forceSingleFile   bool   //是否强制单文件导出，为ture时即使数量超单文件大小也不会切片
+	maxRows           int          //导出最大数量，避免数据提供商出错无限数据
+	singleFileMaxRows int          //单个文件导出最大数量，超出会自动切分
+	filename          string       //文件名，不要加后缀，会自动加
+	rowStart          int          //从第几行开始写数据，仅导出 excel支持
+	colStart          int          //从第几列开始写数据，仅导出 excel支持
+	forceZip          bool         //是否强制zip压缩，即导出只有一个文件时也压缩成zip
+	forceSingleFile   bool         //是否强制单文件导出，为ture时即使数量超单文件大小也不会切片
+	locale            *Locale      //区域格式，为空时使用 LocaleZhCN
+	groupBy           string       //分组字段，分组变化时输出小计行
+	totalTitle        string       //合计行标题
+	subtotalTitle     string       //小计行标题
+	template          *Template    //excel导出模板
+	progress          ProgressFunc //导出进度回调
+	progressInterval  int          //每写入多少行回调一次进度
+	csv               csvOptions   //csv格式配置
+	streaming         bool         //流式导出，不生成临时文件
+	password          string       //导出文件密码
+	concurrency       int          //分片导出并发数
 }
 
 func newOptions(opts ...Option) *options {
@@ -84,6 +122,9 @@ func newOptions(opts ...Option) *options {
 		colStart:          0,
 		forceZip:          false,
 		forceSingleFile:   false,
+		totalTitle:        DefaultTotalTitle,
+		subtotalTitle:     DefaultSubtotalTitle,
+		concurrency:       DefaultConcurrency,
 	}
 	for i := range opts {
 		opts[i](o)
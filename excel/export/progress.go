@@ -0,0 +1,80 @@
+package export
+
+import "time"
+
+// DefaultProgressInterval 默认每写入多少行数据回调一次导出进度
+const DefaultProgressInterval = 1000
+
+// Progress 导出进度
+type Progress struct {
+	Rows    int           //已写入数据行数
+	Files   int           //已生成文件数
+	Elapsed time.Duration //已耗时
+}
+
+// ProgressFunc 导出进度回调，在导出协程中同步调用，不要执行耗时操作
+type ProgressFunc func(p Progress)
+
+// WithProgress 设置导出进度回调，每写入interval行数据及每生成一个文件时回调，interval<=0时使用默认值
+func WithProgress(fn ProgressFunc, interval int) Option {
+	return func(opt *options) {
+		opt.progress = fn
+		opt.progressInterval = interval
+	}
+}
+
+// progressTracker 导出进度统计
+type progressTracker struct {
+	fn       ProgressFunc
+	interval int
+	start    time.Time
+	rows     int
+	files    int
+}
+
+func newProgressTracker(fn ProgressFunc, interval int) *progressTracker {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	return &progressTracker{fn: fn, interval: interval}
+}
+
+// begin 开始导出，重置统计数据
+func (p *progressTracker) begin() {
+	p.start = time.Now()
+	p.rows = 0
+	p.files = 0
+}
+
+// addRow 写入一行数据
+func (p *progressTracker) addRow() {
+	p.rows++
+	if p.rows%p.interval == 0 {
+		p.report()
+	}
+}
+
+// addFile 生成一个文件
+func (p *progressTracker) addFile() {
+	p.files++
+	p.report()
+}
+
+func (p *progressTracker) report() {
+	if p.fn == nil {
+		return
+	}
+	p.fn(Progress{Rows: p.rows, Files: p.files, Elapsed: time.Since(p.start)})
+}
+
+// then 在原回调之后追加回调
+func (p *progressTracker) then(fn ProgressFunc) {
+	if prev := p.fn; prev != nil {
+		p.fn = func(pg Progress) {
+			prev(pg)
+			fn(pg)
+		}
+		return
+	}
+	p.fn = fn
+}
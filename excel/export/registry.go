@@ -0,0 +1,129 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("unknown export field")
+
+// Translator 按语言翻译列标题，key为表头Field，未找到时返回空
+type Translator func(lang, key string) string
+
+type RegistryOption func(r *ColumnRegistry)
+
+// WithTitles 设置某个语言的列标题 Field => Title
+func WithTitles(lang string, titles map[string]string) RegistryOption {
+	return func(r *ColumnRegistry) {
+		lang = strings.ToLower(lang)
+		if r.titles[lang] == nil {
+			r.titles[lang] = make(map[string]string, len(titles))
+		}
+		for field, title := range titles {
+			r.titles[lang][field] = title
+		}
+	}
+}
+
+// WithTranslator 设置列标题翻译函数，WithTitles中找不到时调用
+func WithTranslator(fn Translator) RegistryOption {
+	return func(r *ColumnRegistry) {
+		r.translator = fn
+	}
+}
+
+// Column 可导出的列，用于前端展示可选列
+type Column struct {
+	Field string `json:"field"`
+	Title string `json:"title"`
+}
+
+// ColumnRegistry 可导出列注册表，按用户选择的字段及顺序生成导出表头，创建后只读，可并发使用
+type ColumnRegistry struct {
+	headers    Headers
+	index      map[string]int
+	titles     map[string]map[string]string //语言 => Field => Title
+	translator Translator
+}
+
+func NewColumnRegistry(h Headers, opts ...RegistryOption) *ColumnRegistry {
+	r := &ColumnRegistry{
+		headers: h,
+		index:   make(map[string]int, len(h)),
+		titles:  make(map[string]map[string]string),
+	}
+	for i := range h {
+		r.index[h[i].Field] = i
+	}
+	for i := range opts {
+		opts[i](r)
+	}
+	return r
+}
+
+// Columns 所有可导出列及对应语言的标题
+func (r *ColumnRegistry) Columns(lang string) []Column {
+	res := make([]Column, len(r.headers))
+	for i, h := range r.headers {
+		res[i] = Column{Field: h.Field, Title: r.title(lang, h)}
+	}
+	return res
+}
+
+// Headers 按fields的顺序生成表头，fields为空时返回全部列，重复的字段只保留第一个，
+// 有未注册的字段时返回 ErrUnknownField
+func (r *ColumnRegistry) Headers(fields []string, lang string) (Headers, error) {
+	if len(fields) == 0 {
+		fields = make([]string, len(r.headers))
+		for i := range r.headers {
+			fields[i] = r.headers[i].Field
+		}
+	}
+	res := make(Headers, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	var unknown []string
+	for _, field := range fields {
+		idx, ok := r.index[field]
+		if !ok {
+			unknown = append(unknown, field)
+			continue
+		}
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		h := r.headers[idx]
+		h.Title = r.title(lang, h)
+		res = append(res, h)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownField, strings.Join(unknown, ","))
+	}
+	return res, nil
+}
+
+// title 列标题，依次查找 语言(zh-cn) => 主语言(zh) => 翻译函数 => 默认标题
+func (r *ColumnRegistry) title(lang string, h Header) string {
+	if lang == "" {
+		return h.Title
+	}
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	langs := []string{lang}
+	if i := strings.IndexByte(lang, '-'); i > 0 {
+		langs = append(langs, lang[:i])
+	}
+	for _, l := range langs {
+		if title, ok := r.titles[l][h.Field]; ok && title != "" {
+			return title
+		}
+	}
+	if r.translator != nil {
+		for _, l := range langs {
+			if title := r.translator(l, h.Field); title != "" {
+				return title
+			}
+		}
+	}
+	return h.Title
+}
@@ -0,0 +1,34 @@
+package export
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestColumnRegistry(t *testing.T) {
+	r := NewColumnRegistry(Headers{
+		{Field: "id", Title: "ID"},
+		{Field: "name", Title: "名称"},
+		{Field: "amount", Title: "金额", CellType: CellTypeCurrency},
+	}, WithTitles("en", map[string]string{"name": "Name"}), WithTranslator(func(lang, key string) string {
+		if lang == "en" && key == "amount" {
+			return "Amount"
+		}
+		return ""
+	}))
+	h, err := r.Headers([]string{"amount", "name", "amount"}, "en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{h[0].Title, h[1].Title}
+	if len(h) != 2 || !reflect.DeepEqual(titles, []string{"Amount", "Name"}) || h[0].CellType != CellTypeCurrency {
+		t.Fatalf("unexpected headers %+v", h)
+	}
+	if h, _ = r.Headers(nil, "zh-CN"); len(h) != 3 || h[1].Title != "名称" {
+		t.Fatalf("unexpected headers %+v", h)
+	}
+	if _, err = r.Headers([]string{"id", "password"}, ""); !errors.Is(err, ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+}
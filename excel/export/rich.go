@@ -0,0 +1,277 @@
+package export
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opdss/common/contracts/excel"
+	"github.com/xuri/excelize/v2"
+)
+
+// optionsSheetName 下拉选项过长时存放选项的隐藏工作表
+const optionsSheetName = "_options"
+
+// dropListMaxLength excel下拉列表直接写入选项时的最大长度
+const dropListMaxLength = 255
+
+// Hyperlink 超链接单元格，CellRender返回该类型时写入超链接，导出csv时写入Text，Text为空时写入Url
+type Hyperlink struct {
+	Text    any    //显示内容，为空时显示Url
+	Url     string //链接地址
+	Tooltip string //鼠标悬停提示
+}
+
+// NewStorageHyperlink 链接到文件存储中的文件
+func NewStorageHyperlink(fs excel.FileStorage, file string, text any) Hyperlink {
+	return Hyperlink{Text: text, Url: fs.Url(file)}
+}
+
+// Image 图片单元格，CellRender返回该类型时在单元格中嵌入图片，导出csv时写入Path
+type Image struct {
+	Path      string                   //图片路径，设置Storage时为存储中的文件key，否则为本地文件路径
+	Storage   excel.ReadStorage        //图片所在文件存储
+	Data      []byte                   //图片内容，设置后不再读取Path
+	Extension string                   //图片后缀，如 .png，为空时从Path获取
+	Format    *excelize.GraphicOptions //图片格式，默认自适应单元格大小
+}
+
+// Comment 带批注的单元格，导出csv时只写入Value
+type Comment struct {
+	Value  any    //单元格内容
+	Text   string //批注内容
+	Author string //批注作者
+}
+
+// richText 富单元格在csv等文本格式中的内容
+func richText(v any) (any, bool) {
+	switch r := v.(type) {
+	case Hyperlink:
+		if r.Text == nil || r.Text == "" {
+			return r.Url, true
+		}
+		return r.Text, true
+	case *Hyperlink:
+		return richText(*r)
+	case Image:
+		return r.Path, true
+	case *Image:
+		return r.Path, true
+	case Comment:
+		return r.Value, true
+	case *Comment:
+		return r.Value, true
+	default:
+		return v, false
+	}
+}
+
+// richValue 富单元格写入excel单元格的值
+func richValue(v any) any {
+	switch r := v.(type) {
+	case Hyperlink:
+		if r.Text == nil || r.Text == "" {
+			return r.Url
+		}
+		return r.Text
+	case Image:
+		return nil
+	case Comment:
+		return r.Value
+	default:
+		return v
+	}
+}
+
+// formatRich 按表头格式化富单元格中的值，非富单元格返回false
+func (f *cellFormatter) formatRich(h Header, v any) (any, bool) {
+	switch r := v.(type) {
+	case Hyperlink:
+		r.Text = f.excelValue(h, r.Text)
+		return r, true
+	case *Hyperlink:
+		return f.formatRich(h, *r)
+	case Image:
+		return r, true
+	case *Image:
+		return *r, true
+	case Comment:
+		r.Value = f.excelValue(h, r.Value)
+		return r, true
+	case *Comment:
+		return f.formatRich(h, *r)
+	default:
+		return v, false
+	}
+}
+
+// richWriter 单个excel文件的富单元格写入
+type richWriter struct {
+	fp        *excelize.File
+	sheet     string
+	linkStyle int
+}
+
+func newRichWriter(fp *excelize.File, sheet string) *richWriter {
+	return &richWriter{fp: fp, sheet: sheet}
+}
+
+// write 处理行数据中的富单元格，替换为单元格的值
+func (w *richWriter) write(ctx context.Context, values []any, cellStyles []int, row, col int) error {
+	for i, v := range values {
+		switch v.(type) {
+		case Hyperlink, Image, Comment:
+		default:
+			continue
+		}
+		cell, err := excelize.CoordinatesToCellName(col+i, row)
+		if err != nil {
+			return err
+		}
+		values[i] = richValue(v)
+		switch r := v.(type) {
+		case Hyperlink:
+			//没有单元格样式的列使用超链接样式
+			var linkStyle int
+			if linkStyle, err = w.addHyperlink(cell, r); err == nil && linkStyle != 0 && cellStyles[i] == 0 {
+				values[i] = excelize.Cell{StyleID: linkStyle, Value: values[i]}
+			}
+		case Image:
+			err = w.addImage(ctx, cell, r)
+		case Comment:
+			err = w.fp.AddComment(w.sheet, excelize.Comment{Cell: cell, Author: r.Author, Text: r.Text})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addHyperlink 设置超链接，返回超链接样式
+func (w *richWriter) addHyperlink(cell string, r Hyperlink) (int, error) {
+	if r.Url == "" {
+		return 0, nil
+	}
+	var opts []excelize.HyperlinkOpts
+	if r.Tooltip != "" {
+		opts = append(opts, excelize.HyperlinkOpts{Tooltip: &r.Tooltip})
+	}
+	if err := w.fp.SetCellHyperLink(w.sheet, cell, r.Url, "External", opts...); err != nil {
+		return 0, err
+	}
+	if w.linkStyle == 0 {
+		style, err := w.fp.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "0563C1", Underline: "single"}})
+		if err != nil {
+			return 0, err
+		}
+		w.linkStyle = style
+	}
+	return w.linkStyle, nil
+}
+
+func (w *richWriter) addImage(ctx context.Context, cell string, r Image) error {
+	data := r.Data
+	if data == nil && r.Path != "" {
+		var err error
+		if data, err = readFile(ctx, r.Storage, r.Path); err != nil {
+			return err
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	ext := r.Extension
+	if ext == "" {
+		ext = filepath.Ext(r.Path)
+	}
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	format := r.Format
+	if format == nil {
+		format = &excelize.GraphicOptions{AutoFit: true, Positioning: "oneCell"}
+	}
+	return w.fp.AddPictureFromBytes(w.sheet, cell, &excelize.Picture{Extension: strings.ToLower(ext), File: data, Format: format})
+}
+
+// readFile 从文件存储读取文件，fs为空时读取本地文件
+func readFile(ctx context.Context, fs excel.ReadStorage, file string) ([]byte, error) {
+	if fs == nil {
+		return os.ReadFile(file)
+	}
+	rs, err := fs.GetStream(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rs.Close()
+	}()
+	return io.ReadAll(rs)
+}
+
+// addDataValidations 给配置了下拉选项的列设置数据验证，从第row行开始到工作表末尾，导出文件可作为导入模板
+func addDataValidations(fp *excelize.File, sheet string, headers Headers, row, col int) error {
+	optionsCol := 0
+	for i, h := range headers {
+		if len(h.DropList) == 0 {
+			continue
+		}
+		start, err := excelize.CoordinatesToCellName(col+i, row)
+		if err != nil {
+			return err
+		}
+		end, err := excelize.CoordinatesToCellName(col+i, excelize.TotalRows)
+		if err != nil {
+			return err
+		}
+		dv := excelize.NewDataValidation(true)
+		dv.Sqref = start + ":" + end
+		if len(strings.Join(h.DropList, ",")) <= dropListMaxLength {
+			err = dv.SetDropList(h.DropList)
+		} else {
+			//选项过长时写入隐藏工作表再引用
+			optionsCol++
+			var ref string
+			if ref, err = setOptionsSheet(fp, optionsCol, h.DropList); err == nil {
+				dv.SetSqrefDropList(ref)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err = fp.AddDataValidation(sheet, dv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setOptionsSheet 把下拉选项写入隐藏工作表的第col列，返回引用区域
+func setOptionsSheet(fp *excelize.File, col int, options []string) (string, error) {
+	if idx, _ := fp.GetSheetIndex(optionsSheetName); idx == -1 {
+		if _, err := fp.NewSheet(optionsSheetName); err != nil {
+			return "", err
+		}
+		if err := fp.SetSheetVisible(optionsSheetName, false); err != nil {
+			return "", err
+		}
+	}
+	for i, option := range options {
+		cell, err := excelize.CoordinatesToCellName(col, i+1)
+		if err != nil {
+			return "", err
+		}
+		if err = fp.SetCellStr(optionsSheetName, cell, option); err != nil {
+			return "", err
+		}
+	}
+	colName, err := excelize.ColumnNumberToName(col)
+	if err != nil {
+		return "", err
+	}
+	return optionsSheetName + "!$" + colName + "$1:$" + colName + "$" + strconv.Itoa(len(options)), nil
+}
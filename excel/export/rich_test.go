@@ -0,0 +1,78 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+	"github.com/xuri/excelize/v2"
+)
+
+// richHeaders 超链接、批注及下拉选项列
+func richHeaders() (Headers, []any) {
+	h := Headers{
+		{Field: "name", Title: "名称", CellRender: func(_ any, v any, _, _ int) any {
+			return Hyperlink{Text: v, Url: "https://example.com/" + v.(string), Tooltip: "打开"}
+		}},
+		{Field: "url", Title: "链接", CellRender: func(_ any, v any, _, _ int) any {
+			return &Hyperlink{Url: v.(string)}
+		}},
+		{Field: "status", Title: "状态", DropList: []string{"启用", "停用"}, CellRender: func(_ any, v any, _, _ int) any {
+			return Comment{Value: v, Text: "备注", Author: "admin"}
+		}},
+	}
+	data := []any{map[string]any{"name": "a", "url": "https://example.com/b", "status": "启用"}}
+	return h, data
+}
+
+func TestRichExcel(t *testing.T) {
+	h, data := richHeaders()
+	var buf bytes.Buffer
+	if _, err := NewExcel(h, iterator.NewSliceIterator(data), WithForceSingleFile()).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	for cell, expected := range map[string][2]string{"A2": {"a", "https://example.com/a"}, "B2": {"https://example.com/b", "https://example.com/b"}} {
+		value, _ := fp.GetCellValue(DefaultSheetName, cell)
+		ok, link, err := fp.GetCellHyperLink(DefaultSheetName, cell)
+		if value != expected[0] || !ok || link != expected[1] || err != nil {
+			t.Errorf("%s = %q, link %v %q %v", cell, value, ok, link, err)
+		}
+		//未设置样式的超链接单元格使用超链接样式
+		styleId, _ := fp.GetCellStyle(DefaultSheetName, cell)
+		if style, err := fp.GetStyle(styleId); err != nil || style.Font == nil || style.Font.Underline != "single" {
+			t.Errorf("%s style = %+v, %v", cell, style, err)
+		}
+	}
+	if value, _ := fp.GetCellValue(DefaultSheetName, "C2"); value != "启用" {
+		t.Errorf("C2 = %q", value)
+	}
+	comments, err := fp.GetComments(DefaultSheetName)
+	if err != nil || len(comments) != 1 || comments[0].Cell != "C2" || comments[0].Author != "admin" || comments[0].Text != "备注" {
+		t.Errorf("comments = %+v, %v", comments, err)
+	}
+	dvs, err := fp.GetDataValidations(DefaultSheetName)
+	if err != nil || len(dvs) != 1 || !strings.HasPrefix(dvs[0].Sqref, "C2:") || dvs[0].Formula1 != "\"启用,停用\"" {
+		t.Errorf("data validations = %+v, %v", dvs, err)
+	}
+}
+
+func TestRichCsv(t *testing.T) {
+	h, data := richHeaders()
+	var buf bytes.Buffer
+	if _, err := NewCsv(h, iterator.NewSliceIterator(data)).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	//文本格式写入超链接文本或链接地址、批注单元格的值
+	if expected := "名称,链接,状态\na,https://example.com/b,启用\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
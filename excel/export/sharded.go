@@ -0,0 +1,199 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opdss/common/contracts/excel"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ excel.Exporter = (*Sharded)(nil)
+
+// DefaultConcurrency 分片导出默认并发数
+const DefaultConcurrency = 4
+
+// WithConcurrency 分片导出时同时读取的DataProvider数量
+func WithConcurrency(n int) Option {
+	return func(opt *options) {
+		if n > 0 {
+			opt.concurrency = n
+		}
+	}
+}
+
+// ExporterFactory 创建单个分片的导出器
+type ExporterFactory func(h Headers, dp DataProvider, opts ...Option) excel.Exporter
+
+// ExcelFactory CsvFactory 分片导出excel、csv
+var ExcelFactory ExporterFactory = func(h Headers, dp DataProvider, opts ...Option) excel.Exporter {
+	return NewExcel(h, dp, opts...)
+}
+var CsvFactory ExporterFactory = func(h Headers, dp DataProvider, opts ...Option) excel.Exporter {
+	return NewCsv(h, dp, opts...)
+}
+
+// Sharded 分片并行导出，多个DataProvider(如按日期范围或分库)由协程池并发读取，
+// 每个分片导出为zip中的一个文件，maxRows按所有分片合计，任一分片出错时取消全部分片
+type Sharded struct {
+	factory  ExporterFactory
+	headers  Headers
+	dps      []DataProvider
+	opts     []Option
+	options  *options
+	filename string //zip文件路径
+	progress *progressTracker
+	rows     atomic.Int64
+	exceeded atomic.Bool
+}
+
+func NewSharded(factory ExporterFactory, h Headers, dps []DataProvider, opts ...Option) *Sharded {
+	s := &Sharded{
+		factory: factory,
+		headers: h,
+		dps:     dps,
+		opts:    opts,
+		options: newOptions(opts...),
+	}
+	s.filename = getFilename(s.options.filename, 0, ZipSuffix)
+	s.progress = newProgressTracker(s.options.progress, s.options.progressInterval)
+	return s
+}
+
+// Export 导出zip到本地文件，返回本地文件路径
+func (s *Sharded) Export(ctx context.Context) (string, error) {
+	return streamToFile(ctx, s)
+}
+
+// ExportTo 导出zip到io.Writer，每个分片完成后立即写入
+func (s *Sharded) ExportTo(ctx context.Context, w io.Writer) (int64, error) {
+	return streamTo(ctx, s, w)
+}
+
+// ExportToStorage 导出zip到文件存储，返回下载地址
+func (s *Sharded) ExportToStorage(ctx context.Context, fs excel.FileStorage) (string, error) {
+	return streamToStorage(ctx, s, fs)
+}
+
+// Total 已导出数据行数
+func (s *Sharded) Total() int {
+	return int(s.rows.Load())
+}
+
+// OnProgress 追加导出进度回调，每个分片完成时回调，需在导出前调用
+func (s *Sharded) OnProgress(fn ProgressFunc) {
+	s.progress.then(fn)
+}
+
+// StreamFilename 分片导出总是zip
+func (s *Sharded) StreamFilename() string {
+	return s.filename
+}
+
+func (s *Sharded) streamTo(ctx context.Context, w io.Writer) error {
+	s.progress.begin()
+	dir, err := os.MkdirTemp("", "export_shard_")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	zw := zip.NewWriter(w)
+	mu := sync.Mutex{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.options.concurrency)
+	for i := range s.dps {
+		idx := i
+		g.Go(func() error {
+			//已有分片出错
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			path, err := s.exportShard(gctx, idx, dir)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = os.Remove(path)
+			}()
+			mu.Lock()
+			defer mu.Unlock()
+			if err = s.addToZip(zw, path, idx); err != nil {
+				return err
+			}
+			s.progress.rows = s.Total()
+			s.progress.addFile()
+			return nil
+		})
+	}
+	err = g.Wait()
+	if _err := zw.Close(); err == nil {
+		err = _err
+	}
+	return err
+}
+
+// exportShard 导出第idx个分片到dir目录下的单个文件，返回文件路径
+func (s *Sharded) exportShard(ctx context.Context, idx int, dir string) (string, error) {
+	dp := &limitedProvider{DataProvider: s.dps[idx], rows: &s.rows, exceeded: &s.exceeded, max: int64(s.options.maxRows)}
+	//分片文件不单独加密，由zip统一加密
+	opts := append(append([]Option{}, s.opts...),
+		WithForceSingleFile(),
+		WithFilename(filepath.Join(dir, fmt.Sprintf("part_%d", idx))),
+		WithPassword(""),
+		WithProgress(nil, 0),
+	)
+	path, err := s.factory(s.headers, dp, opts...).Export(ctx)
+	if err == nil && s.exceeded.Load() {
+		_ = os.Remove(path)
+		err = ErrMaximumLimit
+	}
+	return path, err
+}
+
+// addToZip 把分片文件写入zip，文件名为 导出文件名_分片序号.后缀
+func (s *Sharded) addToZip(zw *zip.Writer, path string, idx int) error {
+	name := strings.TrimSuffix(filepath.Base(s.filename), "_0."+ZipSuffix)
+	w, err := newZipWriter(zw, fmt.Sprintf("%s_%d%s", name, idx, filepath.Ext(path)), s.options.password)
+	if err != nil {
+		return err
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	if _, err = io.Copy(w, fp); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// limitedProvider 所有分片共享行数统计，超过maxRows时停止读取
+type limitedProvider struct {
+	DataProvider
+	rows     *atomic.Int64
+	exceeded *atomic.Bool
+	max      int64
+}
+
+func (p *limitedProvider) Next() bool {
+	if p.exceeded.Load() || !p.DataProvider.Next() {
+		return false
+	}
+	if p.rows.Add(1) > p.max {
+		p.exceeded.Store(true)
+		return false
+	}
+	return true
+}
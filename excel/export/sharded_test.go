@@ -0,0 +1,55 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+)
+
+func TestSharded(t *testing.T) {
+	h := Headers{{Field: "id", Title: "ID"}}
+	newProviders := func() []DataProvider {
+		dps := make([]DataProvider, 3)
+		for i := range dps {
+			data := make([]any, 10)
+			for j := range data {
+				data[j] = map[string]any{"id": i*10 + j}
+			}
+			dps[i] = iterator.NewSliceIterator(data)
+		}
+		return dps
+	}
+	var buf bytes.Buffer
+	s := NewSharded(CsvFactory, h, newProviders(), WithFilename("orders"), WithConcurrency(2))
+	n, err := s.ExportTo(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		if lines := bytes.Count(b, []byte("\n")); lines != 11 {
+			t.Errorf("%s expected 11 lines, got %d", f.Name, lines)
+		}
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "orders_0.csv" || names[2] != "orders_2.csv" || s.Total() != 30 {
+		t.Fatalf("unexpected zip entries %v total %d", names, s.Total())
+	}
+	_, err = NewSharded(ExcelFactory, h, newProviders(), WithMaxRows(25)).ExportTo(context.Background(), io.Discard)
+	if !errors.Is(err, ErrMaximumLimit) {
+		t.Fatalf("expected ErrMaximumLimit, got %v", err)
+	}
+}
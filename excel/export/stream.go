@@ -0,0 +1,85 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/opdss/common/contracts/excel"
+)
+
+// WithStreaming 流式导出，数据边读取边写入io.Writer或上传到文件存储，不生成临时文件。
+// 因为开始写入时无法确定是否需要切分，除非设置了WithForceSingleFile，否则总是导出为zip；
+// excel单个文件仍需在内存中生成后写入，超大文件时excelize会使用系统临时目录
+func WithStreaming() Option {
+	return func(opt *options) {
+		opt.streaming = true
+	}
+}
+
+// streamer 支持流式导出的导出器
+type streamer interface {
+	StreamFilename() string
+	streamTo(ctx context.Context, w io.Writer) error
+}
+
+// countWriter 统计写入字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// streamTo 流式导出到io.Writer，返回写入字节数
+func streamTo(ctx context.Context, s streamer, w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	err := s.streamTo(ctx, cw)
+	return cw.n, err
+}
+
+// streamToFile 直接写入最终的本地文件，失败时删除文件
+func streamToFile(ctx context.Context, s streamer) (string, error) {
+	filename := s.StreamFilename()
+	fp, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	err = s.streamTo(ctx, fp)
+	if _err := fp.Close(); err == nil {
+		err = _err
+	}
+	if err != nil {
+		_ = os.Remove(filename)
+		return "", err
+	}
+	return filename, nil
+}
+
+// streamToStorage 边导出边上传，导出出错时中断上传，上传出错时中断导出
+func streamToStorage(ctx context.Context, s streamer, fs excel.FileStorage) (string, error) {
+	fileKey := filepath.Base(s.StreamFilename())
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := s.streamTo(ctx, pw)
+		_ = pw.CloseWithError(err)
+		done <- err
+	}()
+	err := fs.PutStream(ctx, fileKey, pr)
+	//上传结束后导出协程可能阻塞在写入上，关闭读取端让其退出
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	if _err := <-done; _err != nil && !errors.Is(_err, io.ErrClosedPipe) {
+		err = _err
+	}
+	if err != nil {
+		return "", err
+	}
+	return fs.Url(fileKey), nil
+}
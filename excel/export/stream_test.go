@@ -0,0 +1,73 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+)
+
+type testPipeStorage struct {
+	files map[string][]byte
+	err   error
+}
+
+func (s *testPipeStorage) PutStream(_ context.Context, filename string, rs io.Reader) error {
+	if s.err != nil {
+		return s.err
+	}
+	b, err := io.ReadAll(rs)
+	if err != nil {
+		return err
+	}
+	s.files[filename] = b
+	return nil
+}
+
+func (s *testPipeStorage) Url(fileKey string) string {
+	return "/" + fileKey
+}
+
+func TestStreaming(t *testing.T) {
+	h := Headers{{Field: "id", Title: "ID"}}
+	data := make([]any, 25)
+	for i := range data {
+		data[i] = map[string]any{"id": i}
+	}
+	//zip中每个文件
+	var buf bytes.Buffer
+	n, err := NewCsv(h, iterator.NewSliceIterator(data), WithStreaming(), WithSingleFileMaxRows(10)).ExportTo(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 3 {
+		t.Fatalf("expected 3 zip entries, got %d", len(zr.File))
+	}
+	//强制单文件直接写入excel
+	fs := &testPipeStorage{files: map[string][]byte{}}
+	url, err := NewExcel(h, iterator.NewSliceIterator(data), WithStreaming(), WithForceSingleFile(), WithFilename("stream")).ExportToStorage(context.Background(), fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "/stream_0.xlsx" || len(fs.files["stream_0.xlsx"]) == 0 {
+		t.Fatalf("unexpected url %s", url)
+	}
+	//上传失败时中断导出
+	fs.err = errors.New("upload failed")
+	if _, err = NewCsv(h, iterator.NewSliceIterator(data), WithStreaming()).ExportToStorage(context.Background(), fs); !errors.Is(err, fs.err) {
+		t.Fatalf("expected upload error, got %v", err)
+	}
+	//导出失败时中断上传
+	fs.err = nil
+	if _, err = NewCsv(h, iterator.NewSliceIterator(data), WithStreaming(), WithMaxRows(5)).ExportToStorage(context.Background(), fs); !errors.Is(err, ErrMaximumLimit) {
+		t.Fatalf("expected ErrMaximumLimit, got %v", err)
+	}
+}
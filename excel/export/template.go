@@ -0,0 +1,338 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/opdss/common/contracts/excel"
+	"github.com/spf13/cast"
+	"github.com/xuri/excelize/v2"
+)
+
+var ErrTemplateMarker = errors.New("template table marker not found")
+
+// DefaultTableMarker 模板中数据表格开始位置的默认标记
+const DefaultTableMarker = "{{table}}"
+
+// placeholderRegexp 模板占位符，如 {{title}}
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// Template excel导出模板
+//
+// 模板中的 {{name}} 占位符会被替换为 Vars["name"]，数据从 TableMarker 所在单元格开始向右、向下写入，
+// 标记所在行各列的单元格样式作为数据行样式，标记行下方的内容会整体下移到数据之后。
+// 图片等浮动对象按原位置保留，不会随数据下移。
+type Template struct {
+	Path        string            //模板路径，设置Storage时为存储中的文件key，否则为本地文件路径
+	Storage     excel.ReadStorage //模板所在文件存储，为空时从本地读取
+	Sheet       string            //模板工作表，默认第一个工作表
+	Vars        map[string]any    //占位符数据
+	TableMarker string            //数据表格开始位置标记，默认 {{table}}
+}
+
+// NewTemplateExcel 基于模板导出excel
+func NewTemplateExcel(tpl *Template, h Headers, dp DataProvider, opts ...Option) *Excel {
+	return NewExcel(h, dp, append(opts, WithTemplate(tpl))...)
+}
+
+// WithTemplate 设置excel导出模板，仅导出 excel支持，设置后WithRowStart、WithColStart不生效
+func WithTemplate(tpl *Template) Option {
+	return func(opt *options) {
+		opt.template = tpl
+	}
+}
+
+// load 读取模板内容
+func (t *Template) load(ctx context.Context) ([]byte, error) {
+	if t.Storage == nil {
+		return os.ReadFile(t.Path)
+	}
+	rs, err := t.Storage.GetStream(ctx, t.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rs.Close()
+	}()
+	return io.ReadAll(rs)
+}
+
+func (t *Template) marker() string {
+	if t.TableMarker == "" {
+		return DefaultTableMarker
+	}
+	return t.TableMarker
+}
+
+// render 替换字符串中的占位符，整个单元格只有一个占位符时保留变量原始类型
+func (t *Template) render(s string) any {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	if m := placeholderRegexp.FindStringSubmatch(s); m != nil && m[0] == s {
+		if v, ok := t.Vars[m[1]]; ok {
+			return normalizeValue(v)
+		}
+		return s
+	}
+	return placeholderRegexp.ReplaceAllStringFunc(s, func(p string) string {
+		name := placeholderRegexp.FindStringSubmatch(p)[1]
+		if v, ok := t.Vars[name]; ok {
+			return cast.ToString(normalizeValue(v))
+		}
+		return p
+	})
+}
+
+// templateCell 模板单元格
+type templateCell struct {
+	value   any
+	formula string
+	style   int
+}
+
+func (c templateCell) empty() bool {
+	return c.value == nil && c.formula == "" && c.style == 0
+}
+
+// templateLayout 模板工作表内容
+type templateLayout struct {
+	sheet     string
+	rows      [][]templateCell //按行列存储的单元格，下标从0开始
+	heights   []float64
+	widths    []float64
+	merges    [][4]int //合并单元格 startCol, startRow, endCol, endRow
+	markerRow int
+	markerCol int
+}
+
+// readTemplate 读取模板工作表的单元格、行高、列宽及合并单元格，并定位数据表格标记
+func readTemplate(fp *excelize.File, tpl *Template) (*templateLayout, error) {
+	sheet := tpl.Sheet
+	if sheet == "" {
+		sheet = fp.GetSheetName(0)
+	}
+	rows, err := fp.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	maxRow, maxCol := len(rows), 0
+	for i := range rows {
+		maxCol = max(maxCol, len(rows[i]))
+	}
+	//只有样式没有值的单元格不会出现在GetRows里，按工作表尺寸补齐
+	if dimension, err := fp.GetSheetDimension(sheet); err == nil && dimension != "" {
+		ref := dimension[strings.LastIndex(dimension, ":")+1:]
+		if col, row, err := excelize.CellNameToCoordinates(ref); err == nil {
+			maxRow, maxCol = max(maxRow, row), max(maxCol, col)
+		}
+	}
+	layout := &templateLayout{
+		sheet:   sheet,
+		rows:    make([][]templateCell, maxRow),
+		heights: make([]float64, maxRow),
+		widths:  make([]float64, maxCol),
+	}
+	marker := tpl.marker()
+	for r := 1; r <= maxRow; r++ {
+		if layout.heights[r-1], err = fp.GetRowHeight(sheet, r); err != nil {
+			return nil, err
+		}
+		layout.rows[r-1] = make([]templateCell, maxCol)
+		for c := 1; c <= maxCol; c++ {
+			cell, err := readTemplateCell(fp, sheet, c, r)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := cell.value.(string); ok {
+				if strings.TrimSpace(s) == marker {
+					layout.markerRow, layout.markerCol = r, c
+					cell.value = nil
+				} else {
+					cell.value = tpl.render(s)
+				}
+			}
+			layout.rows[r-1][c-1] = cell
+		}
+	}
+	if layout.markerRow == 0 {
+		return nil, ErrTemplateMarker
+	}
+	for c := 1; c <= maxCol; c++ {
+		name, err := excelize.ColumnNumberToName(c)
+		if err != nil {
+			return nil, err
+		}
+		if layout.widths[c-1], err = fp.GetColWidth(sheet, name); err != nil {
+			return nil, err
+		}
+	}
+	mergeCells, err := fp.GetMergeCells(sheet)
+	if err != nil {
+		return nil, err
+	}
+	for _, mc := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(mc.GetStartAxis())
+		if err != nil {
+			return nil, err
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(mc.GetEndAxis())
+		if err != nil {
+			return nil, err
+		}
+		layout.merges = append(layout.merges, [4]int{startCol, startRow, endCol, endRow})
+		//合并区域内读取到的都是左上角单元格的值，只保留左上角
+		for r := startRow; r <= min(endRow, maxRow); r++ {
+			for c := startCol; c <= min(endCol, maxCol); c++ {
+				if r != startRow || c != startCol {
+					layout.rows[r-1][c-1].value = nil
+				}
+			}
+		}
+	}
+	return layout, nil
+}
+
+func readTemplateCell(fp *excelize.File, sheet string, col, row int) (cell templateCell, err error) {
+	name, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return
+	}
+	if cell.style, err = fp.GetCellStyle(sheet, name); err != nil {
+		return
+	}
+	if cell.formula, err = fp.GetCellFormula(sheet, name); err != nil || cell.formula != "" {
+		return
+	}
+	raw, err := fp.GetCellValue(sheet, name, excelize.Options{RawCellValue: true})
+	if err != nil || raw == "" {
+		return
+	}
+	typ, err := fp.GetCellType(sheet, name)
+	if err != nil {
+		return
+	}
+	cell.value = raw
+	switch typ {
+	case excelize.CellTypeNumber, excelize.CellTypeUnset:
+		if n, _err := strconv.ParseFloat(raw, 64); _err == nil {
+			cell.value = n
+		}
+	case excelize.CellTypeBool:
+		cell.value = raw == "1"
+	}
+	return
+}
+
+// values 第r行的单元格数据，用于流式写入
+func (l *templateLayout) values(r int) []any {
+	cells := l.rows[r-1]
+	values := make([]any, len(cells))
+	for i, c := range cells {
+		if c.empty() {
+			continue
+		}
+		values[i] = excelize.Cell{StyleID: c.style, Value: c.value, Formula: c.formula}
+	}
+	return values
+}
+
+// rowOpts 第r行的行属性
+func (l *templateLayout) rowOpts(r int) excelize.RowOpts {
+	return excelize.RowOpts{Height: l.heights[r-1]}
+}
+
+// exportToTemplate 按模板写入数据，标记行之前的内容原样写入，之后的内容下移到数据之后
+func (e *Excel) exportToTemplate(ctx context.Context, fp *excelize.File) (hasMore bool, err error) {
+	layout, err := readTemplate(fp, e.options.template)
+	if err != nil {
+		return false, err
+	}
+	fw, err := fp.NewStreamWriter(layout.sheet)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = fw.Flush()
+		}
+	}()
+	//列宽，表头设置了列宽时覆盖模板列宽
+	widths := layout.widths
+	for i, h := range e.columns.headers {
+		c := layout.markerCol + i
+		for len(widths) < c {
+			widths = append(widths, 0)
+		}
+		if h.ColWidth > 0 {
+			widths[c-1] = h.ColWidth
+		}
+	}
+	for i, w := range widths {
+		if w == 0 {
+			continue
+		}
+		if err = fw.SetColWidth(i+1, i+1, w); err != nil {
+			return false, err
+		}
+	}
+	//数据行样式，优先使用模板中标记行的样式
+	cellStyles, err := e.setColStyle(layout.sheet, layout.markerCol, fp, nil)
+	if err != nil {
+		return false, err
+	}
+	for i := range cellStyles {
+		if c := layout.markerCol + i; c <= len(layout.widths) && layout.rows[layout.markerRow-1][c-1].style != 0 {
+			cellStyles[i] = layout.rows[layout.markerRow-1][c-1].style
+		}
+	}
+	//标记行之前的内容
+	for r := 1; r < layout.markerRow; r++ {
+		if err = fw.SetRow("A"+strconv.Itoa(r), layout.values(r), layout.rowOpts(r)); err != nil {
+			return false, err
+		}
+	}
+	//数据
+	lastRow, hasMore, err := e.writeRows(ctx, fp, fw, layout.markerRow-1, layout.markerCol, cellStyles, layout.rowOpts(layout.markerRow))
+	if err != nil {
+		return false, err
+	}
+	//标记行之后的内容下移
+	offset := lastRow - layout.markerRow
+	for r := layout.markerRow + 1; r <= len(layout.rows); r++ {
+		if err = fw.SetRow("A"+strconv.Itoa(r+offset), layout.values(r), layout.rowOpts(r)); err != nil {
+			return false, err
+		}
+	}
+	for _, mc := range layout.merges {
+		switch {
+		case mc[3] < layout.markerRow:
+		case mc[1] > layout.markerRow:
+			mc[1], mc[3] = mc[1]+offset, mc[3]+offset
+		default:
+			//与标记行重叠的合并单元格会覆盖数据，丢弃
+			continue
+		}
+		if err = mergeCell(fw, mc); err != nil {
+			return false, err
+		}
+	}
+	return hasMore, fw.Flush()
+}
+
+func mergeCell(fw *excelize.StreamWriter, mc [4]int) error {
+	start, err := excelize.CoordinatesToCellName(mc[0], mc[1])
+	if err != nil {
+		return err
+	}
+	end, err := excelize.CoordinatesToCellName(mc[2], mc[3])
+	if err != nil {
+		return err
+	}
+	return fw.MergeCell(start, end)
+}
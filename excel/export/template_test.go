@@ -0,0 +1,146 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+	"github.com/xuri/excelize/v2"
+)
+
+// newTestTemplate 标题、占位符、数据标记行及标记行下方的合并单元格
+func newTestTemplate(t *testing.T, marker string) string {
+	fp := excelize.NewFile()
+	defer func() {
+		_ = fp.Close()
+	}()
+	styleId, err := fp.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := map[string]any{"A1": "{{title}}", "A2": "共{{ count }}条{{missing}}", "B2": "{{count}}", "A4": marker, "A6": "合计"}
+	for cell, v := range cells {
+		if err = fp.SetCellValue(DefaultSheetName, cell, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = fp.SetCellStyle(DefaultSheetName, "A4", "B4", styleId); err != nil {
+		t.Fatal(err)
+	}
+	if err = fp.MergeCell(DefaultSheetName, "A6", "B6"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tpl.xlsx")
+	if err = fp.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTemplateExcel(t *testing.T) {
+	path := newTestTemplate(t, DefaultTableMarker)
+	h := Headers{{Field: "id", Title: "ID"}, {Field: "name", Title: "名称"}}
+	data := []any{map[string]any{"id": 1, "name": "a"}, map[string]any{"id": 2, "name": "b"}, map[string]any{"id": 3, "name": "c"}}
+	tpl := &Template{Path: path, Vars: map[string]any{"title": "订单报表", "count": 3}}
+	var buf bytes.Buffer
+	if _, err := NewTemplateExcel(tpl, h, iterator.NewSliceIterator(data), WithForceSingleFile()).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	rows, err := fp.GetRows(DefaultSheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, ","))
+	}
+	//占位符替换，数据从标记行开始，标记行下方的内容下移
+	expected := []string{"订单报表", "共3条{{missing}},3", "", "1,a", "2,b", "3,c", "", "合计"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("rows = %q, want %q", lines, expected)
+	}
+	//单个占位符保留变量类型
+	if typ, _ := fp.GetCellType(DefaultSheetName, "B2"); typ == excelize.CellTypeSharedString || typ == excelize.CellTypeInlineString {
+		t.Errorf("B2 type = %v, want number", typ)
+	}
+	//每个数据行使用标记行的样式
+	markerStyle, _ := fp.GetCellStyle(DefaultSheetName, "A4")
+	for _, cell := range []string{"A5", "B6"} {
+		if style, _ := fp.GetCellStyle(DefaultSheetName, cell); style == 0 || style != markerStyle {
+			t.Errorf("%s style = %d, want %d", cell, style, markerStyle)
+		}
+	}
+	merges, err := fp.GetMergeCells(DefaultSheetName)
+	if err != nil || len(merges) != 1 || merges[0].GetStartAxis() != "A8" || merges[0].GetEndAxis() != "B8" {
+		t.Errorf("merges = %v, %v", merges, err)
+	}
+}
+
+func TestTemplateMarker(t *testing.T) {
+	path := newTestTemplate(t, "[data]")
+	h := Headers{{Field: "id", Title: "ID"}}
+	data := []any{map[string]any{"id": 1}}
+	_, err := NewTemplateExcel(&Template{Path: path}, h, iterator.NewSliceIterator(data), WithForceSingleFile()).ExportTo(context.Background(), &bytes.Buffer{})
+	if !errors.Is(err, ErrTemplateMarker) {
+		t.Fatalf("expected ErrTemplateMarker, got %v", err)
+	}
+	var buf bytes.Buffer
+	_, err = NewTemplateExcel(&Template{Path: path, TableMarker: "[data]"}, h, iterator.NewSliceIterator(data), WithForceSingleFile()).ExportTo(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+	if v, _ := fp.GetCellValue(DefaultSheetName, "A4"); v != "1" {
+		t.Errorf("A4 = %q", v)
+	}
+}
+
+// flakyStorage 第一次读取失败的模板存储
+type flakyStorage struct {
+	calls int
+}
+
+func (s *flakyStorage) GetStream(_ context.Context, file string) (io.ReadCloser, error) {
+	s.calls++
+	if s.calls == 1 {
+		return nil, errors.New("temporary error")
+	}
+	return os.Open(file)
+}
+
+func TestTemplateLoad(t *testing.T) {
+	fs := &flakyStorage{}
+	tpl := &Template{Path: newTestTemplate(t, DefaultTableMarker), Storage: fs}
+	h := Headers{{Field: "id", Title: "ID"}}
+	data := []any{map[string]any{"id": 1}, map[string]any{"id": 2}, map[string]any{"id": 3}}
+	//读取失败不影响之后使用同一模板的导出
+	if _, err := NewTemplateExcel(tpl, h, iterator.NewSliceIterator(data), WithForceSingleFile()).ExportTo(context.Background(), &bytes.Buffer{}); err == nil {
+		t.Fatal("expected load error")
+	}
+	//拆分多个文件时模板只读取一次
+	if _, err := NewTemplateExcel(tpl, h, iterator.NewSliceIterator(data), WithSingleFileMaxRows(1)).ExportTo(context.Background(), &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if fs.calls != 2 {
+		t.Fatalf("template loaded %d times", fs.calls)
+	}
+}
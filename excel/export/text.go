@@ -0,0 +1,371 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/opdss/common/contracts/excel"
+)
+
+// textFormat 文本类导出格式，csv、json lines、html、markdown共用分片、打包及上传流程
+type textFormat interface {
+	suffix() string
+	newWriter(w io.Writer, c *columns, f *cellFormatter, opt *options) textWriter
+}
+
+// textWriter 单个文本文件的写入
+type textWriter interface {
+	writeHeader() error
+	// writeRow 写入一行数据，values为CellRender处理后的原始值
+	writeRow(values []any) error
+	// writeSummary 写入小计、合计行
+	writeSummary(values []string) error
+	// close 写入文件结尾并刷新缓冲，不关闭底层io.Writer
+	close() error
+}
+
+// textExporter 文本类导出的公共实现
+type textExporter struct {
+	format    textFormat
+	dp        DataProvider
+	options   *options
+	columns   *columns
+	formatter *cellFormatter
+	progress  *progressTracker
+	total     int
+}
+
+func newTextExporter(format textFormat, h Headers, dp DataProvider, opts ...Option) *textExporter {
+	t := &textExporter{
+		format:  format,
+		dp:      dp,
+		columns: newColumns(h),
+		options: newOptions(opts...),
+	}
+	t.formatter = newCellFormatter(t.options.locale)
+	t.progress = newProgressTracker(t.options.progress, t.options.progressInterval)
+	return t
+}
+
+func (t *textExporter) Export(ctx context.Context) (filename string, err error) {
+	if t.options.streaming {
+		return streamToFile(ctx, t)
+	}
+	ef, err := t.export(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = ef.Close()
+	}()
+	return ef.Save()
+}
+
+func (t *textExporter) ExportTo(ctx context.Context, w io.Writer) (n int64, err error) {
+	if t.options.streaming {
+		return streamTo(ctx, t, w)
+	}
+	ef, err := t.export(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = ef.Close()
+	}()
+	return ef.WriteTo(w)
+}
+
+func (t *textExporter) ExportToStorage(ctx context.Context, fs excel.FileStorage) (filename string, err error) {
+	if t.options.streaming {
+		return streamToStorage(ctx, t, fs)
+	}
+	ef, err := t.export(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = ef.Close()
+	}()
+	fileKey := filepath.Base(ef.Filepath())
+	fr, fw := io.Pipe()
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	var _err error
+	go func() {
+		defer wg.Done()
+		if _, _err = ef.WriteTo(fw); _err != nil {
+			log.Println("io pipe write error", _err.Error())
+		}
+		_ = fw.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		if _err = fs.PutStream(ctx, fileKey, fr); _err != nil {
+			log.Println("io pipe read error", _err.Error())
+		}
+		_ = fr.Close()
+	}()
+	wg.Wait()
+	if _err != nil {
+		return "", _err
+	}
+	return fs.Url(fileKey), nil
+}
+
+// Total 已导出数据行数
+func (t *textExporter) Total() int {
+	return t.total
+}
+
+// OnProgress 追加导出进度回调，需在导出前调用
+func (t *textExporter) OnProgress(fn ProgressFunc) {
+	t.progress.then(fn)
+}
+
+func (t *textExporter) export(ctx context.Context) (exportFile, error) {
+	t.progress.begin()
+	//设置了密码时只能通过zip加密
+	if t.options.forceZip || t.options.password != "" {
+		return t.exportZip(ctx, nil)
+	}
+	//先导出第一个文件
+	firstEf, err := newExportTmpFile(getFilename(t.options.filename, 0, t.format.suffix()))
+	if err != nil {
+		return nil, err
+	}
+	hasMore, err := t.exportToWrite(ctx, firstEf)
+	if err != nil {
+		_ = firstEf.Close()
+		return nil, err
+	}
+	if !hasMore {
+		return firstEf, nil
+	}
+	defer func() {
+		_ = firstEf.Close()
+		_ = os.Remove(firstEf.Filepath())
+	}()
+	//导出zip
+	return t.exportZip(ctx, firstEf)
+}
+
+func (t *textExporter) exportZip(ctx context.Context, firstFile exportFile) (exportFile, error) {
+	var idx int
+	ef, err := newExportTmpFile(getFilename(t.options.filename, 0, ZipSuffix))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = ef.Close()
+		}
+	}()
+	zw := zip.NewWriter(ef)
+	defer func() {
+		_ = zw.Close()
+	}()
+	var w io.WriteCloser
+	//把外面传进来的加进去
+	if firstFile != nil {
+		w, err = newZipWriter(zw, firstFile.Filepath(), t.options.password)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = firstFile.WriteTo(w); err != nil {
+			return nil, err
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+		idx++
+	}
+	//读取数据
+	if err = t.writeZipParts(ctx, zw, idx); err != nil {
+		return nil, err
+	}
+	return ef, nil
+}
+
+// writeZipParts 从第idx个文件开始，把剩余数据逐个文件写入zip
+func (t *textExporter) writeZipParts(ctx context.Context, zw *zip.Writer, idx int) error {
+	hasMore := true
+	for hasMore {
+		w, err := newZipWriter(zw, getFilename(t.options.filename, idx, t.format.suffix()), t.options.password)
+		if err != nil {
+			return err
+		}
+		idx++
+		if hasMore, err = t.exportToWrite(ctx, w); err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamFilename 流式导出的文件名，强制单文件时为数据文件，否则为zip，用于确定下载文件类型
+func (t *textExporter) StreamFilename() string {
+	if t.singleFile() {
+		return getFilename(t.options.filename, 0, t.format.suffix())
+	}
+	return getFilename(t.options.filename, 0, ZipSuffix)
+}
+
+// singleFile 流式导出时是否直接输出单个文件，设置了密码时需要打包为加密zip
+func (t *textExporter) singleFile() bool {
+	return t.options.forceSingleFile && t.options.password == ""
+}
+
+// streamTo 流式导出，数据边读取边写入w
+func (t *textExporter) streamTo(ctx context.Context, w io.Writer) error {
+	t.progress.begin()
+	if t.singleFile() {
+		_, err := t.exportToWrite(ctx, w)
+		return err
+	}
+	zw := zip.NewWriter(w)
+	err := t.writeZipParts(ctx, zw, 0)
+	if _err := zw.Close(); err == nil {
+		err = _err
+	}
+	return err
+}
+
+func (t *textExporter) exportToWrite(ctx context.Context, fp io.Writer) (hasMore bool, err error) {
+	tw := t.format.newWriter(fp, t.columns, t.formatter, t.options)
+	defer func() {
+		if _err := tw.close(); err == nil {
+			err = _err
+		}
+		if err == nil {
+			t.progress.addFile()
+		}
+	}()
+	//写入表头
+	if err = tw.writeHeader(); err != nil {
+		return false, err
+	}
+	agg := newAggregator(t.columns, t.formatter, t.options)
+	row := 0
+	for {
+		row++
+		if !t.dp.Next() {
+			break
+		}
+		_v := t.dp.Value()
+		rowData := reflect.ValueOf(_v)
+		if agg != nil {
+			//分组变化，先输出上一分组小计
+			groupKey, changed := agg.groupChanged(rowData)
+			if changed {
+				if err = tw.writeSummary(agg.textSubtotalRow()); err != nil {
+					break
+				}
+			}
+			agg.enterGroup(groupKey, row)
+		}
+		values := t.processRow(rowData, row)
+		if agg != nil {
+			agg.add(values)
+		}
+		err = tw.writeRow(values)
+		if err != nil {
+			break
+		}
+		t.progress.addRow()
+		//检查是否超过最大导出限制
+		t.total++
+		if t.total > t.options.maxRows {
+			err = ErrMaximumLimit
+			break
+		}
+		if !t.options.forceSingleFile && row >= t.options.singleFileMaxRows {
+			return true, t.writeTotalRows(tw, agg, row)
+		}
+		//收到取消导出信号
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		default:
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = t.writeTotalRows(tw, agg, row-1)
+	}
+	return false, err
+}
+
+// writeTotalRows 在数据末尾写入最后一个分组的小计行及合计行，nums为数据行数
+func (t *textExporter) writeTotalRows(tw textWriter, agg *aggregator, nums int) error {
+	if agg == nil || nums == 0 {
+		return nil
+	}
+	if agg.grouped {
+		if err := tw.writeSummary(agg.textSubtotalRow()); err != nil {
+			return err
+		}
+	}
+	return tw.writeSummary(agg.textTotalRow())
+}
+
+func (t *textExporter) processRow(rowData reflect.Value, row int) []any {
+	if !rowData.IsValid() {
+		return make([]any, t.columns.nums)
+	}
+	switch rowData.Type().Kind() {
+	case reflect.Ptr:
+		return t.processRow(rowData.Elem(), row)
+	case reflect.Map, reflect.Struct:
+		return t.processRowFromField(rowData, row)
+	case reflect.Slice:
+		return t.processRowFromSlice(rowData, row)
+	default:
+		return make([]any, t.columns.nums)
+	}
+}
+
+// processRowFromField 按字段路径取值，支持map、struct的多级嵌套字段及无参方法
+func (t *textExporter) processRowFromField(rowData reflect.Value, row int) []any {
+	_rowData := make([]any, t.columns.nums)
+	accessors := t.columns.getAccessors(rowData.Type())
+	for i := range accessors {
+		_rowData[i] = t.processCell(i, accessors[i](rowData), rowData, row, i+1)
+	}
+	return _rowData
+}
+
+func (t *textExporter) processRowFromSlice(rowData reflect.Value, row int) []any {
+	_rowData := make([]any, t.columns.nums)
+	l := rowData.Len()
+	for i := 0; i < t.columns.nums; i++ {
+		if i < l {
+			_rowData[i] = t.processCell(i, rowData.Index(i), rowData, row, i+1)
+		} else {
+			_rowData[i] = t.processCell(i, nilValue, rowData, row, i+1)
+		}
+	}
+	return _rowData
+}
+
+// processCell 处理第idx列的单元格，多列可以使用相同的Field，按列位置取表头
+func (t *textExporter) processCell(idx int, val reflect.Value, rowData reflect.Value, row, col int) any {
+	var v any
+	if val.IsValid() && val.CanInterface() {
+		v = val.Interface()
+	}
+	if render := t.columns.headers[idx].CellRender; render != nil {
+		v = render(rowData.Interface(), v, row, col)
+	}
+	return v
+}
@@ -1,14 +1,12 @@
 package export
 
 import (
-	"archive/zip"
 	"fmt"
 	"github.com/xuri/excelize/v2"
 	"golang.org/x/exp/rand"
 	"io"
 	"os"
 	"path"
-	filepath2 "path/filepath"
 	"time"
 )
 
@@ -63,12 +61,14 @@ var _ exportFile = (*exportExcel)(nil)
 type exportExcel struct {
 	filepath string
 	fp       *excelize.File
+	opts     excelize.Options //写入选项，设置了密码时加密
 }
 
-func newExportExcel(filepath string, fp *excelize.File) *exportExcel {
+func newExportExcel(filepath string, fp *excelize.File, password string) *exportExcel {
 	return &exportExcel{
 		filepath: filepath,
 		fp:       fp,
+		opts:     excelize.Options{Password: password},
 	}
 }
 
@@ -77,7 +77,7 @@ func (e *exportExcel) Filepath() string {
 }
 
 func (e *exportExcel) WriteTo(w io.Writer) (n int64, err error) {
-	return e.fp.WriteTo(w)
+	return e.fp.WriteTo(w, e.opts)
 }
 
 func (e *exportExcel) Close() error {
@@ -85,7 +85,7 @@ func (e *exportExcel) Close() error {
 }
 
 func (e *exportExcel) Save() (string, error) {
-	return e.filepath, e.fp.SaveAs(e.filepath)
+	return e.filepath, e.fp.SaveAs(e.filepath, e.opts)
 }
 
 // getFilename 生成导出文件名
@@ -108,11 +108,3 @@ func getFilename(filename string, idx int, suf string) string {
 func randInt(min, max int) int {
 	return rand.Intn(max-min) + min
 }
-
-func newZipWriter(zw *zip.Writer, filepath string) (io.Writer, error) {
-	return zw.CreateHeader(&zip.FileHeader{
-		Name:     filepath2.Base(filepath),
-		Method:   zip.Deflate,
-		Modified: time.Now(),
-	})
-}
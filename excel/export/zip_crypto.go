@@ -0,0 +1,212 @@
+package export
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+	filepath2 "path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// WinZip AES加密(AE-2)参数，AES-256
+const (
+	zipMethodAES       = 99
+	zipAESExtraID      = 0x9901
+	zipAESVersion      = 2 //AE-2，不写入CRC
+	zipAESStrength     = 3 //AES-256
+	zipAESKeyLen       = 32
+	zipAESSaltLen      = 16
+	zipAESMacLen       = 10
+	zipAESIterations   = 1000
+	zipFlagEncrypted   = 0x1
+	zipFlagDescriptor  = 0x8
+	zipFlagUTF8        = 0x800
+	zipAESPasswordSize = 2
+)
+
+// WithPassword 设置导出文件密码：excel单文件使用excel自带加密，zip使用WinZip AES-256加密，
+// 设置后csv等文本格式总是打包为加密zip。打包为zip时只加密zip，其中的excel不再单独加密，解压后打开无需密码。
+// 密码只用于加密，不会记录日志
+func WithPassword(password string) Option {
+	return func(opt *options) {
+		opt.password = password
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// newZipWriter 在zip中创建文件，password不为空时使用WinZip AES-256加密，写入完成后需要Close
+func newZipWriter(zw *zip.Writer, filepath, password string) (io.WriteCloser, error) {
+	if password == "" {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     filepath2.Base(filepath),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		return nopWriteCloser{w}, err
+	}
+	return newAESZipWriter(zw, filepath2.Base(filepath), password)
+}
+
+// aesZipWriter WinZip AES加密文件写入，数据先deflate压缩，再AES-CTR加密并计算HMAC-SHA1
+type aesZipWriter struct {
+	fh        *zip.FileHeader
+	raw       io.Writer //zip原始数据写入
+	comp      *flate.Writer
+	block     cipher.Block
+	mac       hash.Hash
+	counter   [aes.BlockSize]byte
+	keystream [aes.BlockSize]byte
+	pos       int //当前keystream已使用长度
+	rawSize   uint64
+	encSize   uint64
+	err       error
+}
+
+func newAESZipWriter(zw *zip.Writer, name, password string) (*aesZipWriter, error) {
+	salt := make([]byte, zipAESSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := pbkdf2.Key([]byte(password), salt, zipAESIterations, 2*zipAESKeyLen+zipAESPasswordSize, sha1.New)
+	block, err := aes.NewCipher(key[:zipAESKeyLen])
+	if err != nil {
+		return nil, err
+	}
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], zipAESExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], zipAESVersion)
+	copy(extra[6:], "AE")
+	extra[8] = zipAESStrength
+	binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+	fh := &zip.FileHeader{
+		Name:           name,
+		Method:         zipMethodAES,
+		Flags:          zipFlagEncrypted | zipFlagDescriptor,
+		Extra:          extra,
+		CreatorVersion: 20,
+		ReaderVersion:  51,
+	}
+	if !isASCII(name) && utf8.ValidString(name) {
+		fh.Flags |= zipFlagUTF8
+	}
+	fh.ModifiedDate, fh.ModifiedTime = msDosTime(time.Now())
+	//设置了数据描述符，大小在写入完成后更新到FileHeader，由zip.Writer写入数据描述符及中央目录
+	raw, err := zw.CreateRaw(fh)
+	if err != nil {
+		return nil, err
+	}
+	w := &aesZipWriter{
+		fh:    fh,
+		raw:   raw,
+		block: block,
+		mac:   hmac.New(sha1.New, key[zipAESKeyLen:2*zipAESKeyLen]),
+		pos:   aes.BlockSize,
+	}
+	if _, err = raw.Write(salt); err != nil {
+		return nil, err
+	}
+	if _, err = raw.Write(key[2*zipAESKeyLen:]); err != nil {
+		return nil, err
+	}
+	if w.comp, err = flate.NewWriter(encryptWriter{w}, flate.DefaultCompression); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *aesZipWriter) Write(p []byte) (int, error) {
+	n, err := w.comp.Write(p)
+	w.rawSize += uint64(n)
+	return n, err
+}
+
+// Close 写入认证码并更新文件大小，不关闭zip.Writer
+func (w *aesZipWriter) Close() error {
+	if err := w.comp.Close(); err != nil {
+		return err
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if _, err := w.raw.Write(w.mac.Sum(nil)[:zipAESMacLen]); err != nil {
+		return err
+	}
+	compressed := zipAESSaltLen + zipAESPasswordSize + w.encSize + zipAESMacLen
+	w.fh.CompressedSize64 = compressed
+	w.fh.UncompressedSize64 = w.rawSize
+	w.fh.CompressedSize = uint32(min(compressed, 0xFFFFFFFF))
+	w.fh.UncompressedSize = uint32(min(w.rawSize, 0xFFFFFFFF))
+	return nil
+}
+
+// encrypt AES-CTR加密，计数器为小端序且从1开始
+func (w *aesZipWriter) encrypt(p []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	buf := make([]byte, len(p))
+	for i := range p {
+		if w.pos == aes.BlockSize {
+			for j := range w.counter {
+				w.counter[j]++
+				if w.counter[j] != 0 {
+					break
+				}
+			}
+			w.block.Encrypt(w.keystream[:], w.counter[:])
+			w.pos = 0
+		}
+		buf[i] = p[i] ^ w.keystream[w.pos]
+		w.pos++
+	}
+	w.mac.Write(buf)
+	w.encSize += uint64(len(buf))
+	_, w.err = w.raw.Write(buf)
+	return w.err
+}
+
+// encryptWriter 压缩后的数据加密写入
+type encryptWriter struct {
+	w *aesZipWriter
+}
+
+func (e encryptWriter) Write(p []byte) (int, error) {
+	if err := e.w.encrypt(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// msDosTime zip文件头中的MS-DOS日期时间
+func msDosTime(t time.Time) (date uint16, tm uint16) {
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tm = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return
+}
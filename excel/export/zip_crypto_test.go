@@ -0,0 +1,113 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/opdss/common/iterator"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// decryptAESZipFile 按WinZip AES规范解密zip中的文件
+func decryptAESZipFile(t *testing.T, f *zip.File, password string) []byte {
+	if f.Method != zipMethodAES || f.Flags&zipFlagEncrypted == 0 {
+		t.Fatalf("%s is not aes encrypted", f.Name)
+	}
+	rc, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(rc)
+	salt, pwv := raw[:zipAESSaltLen], raw[zipAESSaltLen:zipAESSaltLen+zipAESPasswordSize]
+	data, mac := raw[zipAESSaltLen+zipAESPasswordSize:len(raw)-zipAESMacLen], raw[len(raw)-zipAESMacLen:]
+	key := pbkdf2.Key([]byte(password), salt, zipAESIterations, 2*zipAESKeyLen+zipAESPasswordSize, sha1.New)
+	if !bytes.Equal(pwv, key[2*zipAESKeyLen:]) {
+		t.Fatal("password verification failed")
+	}
+	h := hmac.New(sha1.New, key[zipAESKeyLen:2*zipAESKeyLen])
+	h.Write(data)
+	if !bytes.Equal(mac, h.Sum(nil)[:zipAESMacLen]) {
+		t.Fatal("authentication code mismatch")
+	}
+	block, _ := aes.NewCipher(key[:zipAESKeyLen])
+	plain := make([]byte, len(data))
+	var counter, stream [aes.BlockSize]byte
+	for i := range data {
+		if i%aes.BlockSize == 0 {
+			for j := range counter {
+				if counter[j]++; counter[j] != 0 {
+					break
+				}
+			}
+			block.Encrypt(stream[:], counter[:])
+		}
+		plain[i] = data[i] ^ stream[i%aes.BlockSize]
+	}
+	b, err := io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// libarchiveAESZip 由 bsdtar 3.7.7 (libarchive) 生成的AE-2加密zip，密码为secret：
+// bsdtar --format zip --options zip:encryption=aes256 --passphrase secret -cf hello.zip hello.txt
+const libarchiveAESZip = "UEsDBBQACQBjABkGU10AAAAAAAAAAAAAAAAJACsAaGVsbG8udHh0dXgLAAEEAAAAAAQAAAAAAZkHAAIAQUUDCABVVA0AB/Jo1WryaNVq8mjVao1Y/UIaCIdHrd8wY6VSQ86JmISxU1Dc9d4A2P7HXjOhdDDsSvS86NKQ71ZBCrlQSwcIAAAAAC4AAAAQAAAAUEsBAhQDFAAJAGMAGQZTXQAAAAAuAAAAEAAAAAkAIwAAAAAAAAAAAKSBAAAAAGhlbGxvLnR4dHV4CwABBAAAAAAEAAAAAAGZBwACAEFFAwgAVVQFAAHyaNVqUEsFBgAAAAABAAEAWgAAAJAAAAAAAA=="
+
+// TestAESZipVector 用其他工具生成的文件验证解密代码符合WinZip AES规范，避免加解密同时出错
+func TestAESZipVector(t *testing.T) {
+	b, err := base64.StdEncoding.DecodeString(libarchiveAESZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "hello.txt" {
+		t.Fatalf("unexpected files %v", zr.File)
+	}
+	if got := string(decryptAESZipFile(t, zr.File[0], "secret")); got != "hello winzip aes" {
+		t.Fatalf("decrypted = %q", got)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	h := Headers{{Field: "id", Title: "ID"}}
+	data := []any{map[string]any{"id": 1}, map[string]any{"id": 2}}
+	var buf bytes.Buffer
+	n, err := NewCsv(h, iterator.NewSliceIterator(data), WithPassword("secret")).ExportTo(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || string(decryptAESZipFile(t, zr.File[0], "secret")) != "ID\n1\n2\n" {
+		t.Fatal("unexpected encrypted zip content")
+	}
+	buf.Reset()
+	if _, err = NewExcel(h, iterator.NewSliceIterator(data), WithPassword("secret")).ExportTo(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = excelize.OpenReader(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("expected password required")
+	}
+	fp, err := excelize.OpenReader(bytes.NewReader(buf.Bytes()), excelize.Options{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := fp.GetCellValue(DefaultSheetName, "A3"); v != "2" {
+		t.Fatalf("unexpected cell value %q", v)
+	}
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opdss/common/contracts/excel"
+	"github.com/opdss/common/excel/export"
+)
+
+// ExportFactory 根据请求创建导出器，opts需要传给 export.NewExcel 等构造函数，用于开启流式导出
+type ExportFactory func(c *gin.Context, opts ...export.Option) (excel.Exporter, error)
+
+// ExportCounter 预估导出数据行数，用于判断是否转为异步导出
+type ExportCounter func(c *gin.Context) (int, error)
+
+// exportContentTypes 导出文件后缀对应的Content-Type
+var exportContentTypes = map[string]string{
+	export.ExcelSuffix:     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	export.CsvSuffix:       "text/csv",
+	export.ZipSuffix:       "application/zip",
+	export.JsonLinesSuffix: "application/x-ndjson",
+	export.HtmlSuffix:      "text/html; charset=utf-8",
+	export.MarkdownSuffix:  "text/markdown; charset=utf-8",
+}
+
+type ExportOption func(h *exportHandler)
+
+// WithExportFilename 下载文件名，不用加后缀，默认 export
+func WithExportFilename(filename string) ExportOption {
+	return func(h *exportHandler) {
+		h.filename = filename
+	}
+}
+
+// WithExportAsync 预估行数超过threshold时转为异步导出任务，
+// location返回任务查询地址，响应303重定向到该地址，location为nil时响应202及任务信息
+func WithExportAsync(jobs *export.JobManager, threshold int, count ExportCounter, location func(c *gin.Context, job *export.Job) string) ExportOption {
+	return func(h *exportHandler) {
+		h.jobs = jobs
+		h.threshold = threshold
+		h.count = count
+		h.location = location
+	}
+}
+
+type exportHandler struct {
+	factory   ExportFactory
+	filename  string
+	jobs      *export.JobManager
+	threshold int
+	count     ExportCounter
+	location  func(c *gin.Context, job *export.Job) string
+}
+
+// ExportHandler 下载导出文件，数据边导出边以chunked方式写入响应，客户端断开时取消导出
+func ExportHandler(factory ExportFactory, opts ...ExportOption) gin.HandlerFunc {
+	h := &exportHandler{factory: factory, filename: "export"}
+	for i := range opts {
+		opts[i](h)
+	}
+	return h.handle
+}
+
+func (h *exportHandler) handle(c *gin.Context) {
+	if h.jobs != nil && h.threshold > 0 && h.count != nil {
+		n, err := h.count(c)
+		if err != nil {
+			exportError(c, err)
+			return
+		}
+		if n > h.threshold {
+			h.async(c)
+			return
+		}
+	}
+	exporter, err := h.factory(c, export.WithStreaming())
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	suffix := h.suffix(exporter)
+	contentType, ok := exportContentTypes[suffix]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", ContentDisposition("attachment", h.filename+"."+suffix))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	//请求的context在客户端断开时取消
+	n, err := exporter.ExportTo(c.Request.Context(), c.Writer)
+	if err == nil {
+		return
+	}
+	if n == 0 && !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.Writer.Header().Del("Content-Type")
+		exportError(c, err)
+		return
+	}
+	_ = c.Error(err)
+	c.Abort()
+	//客户端已断开
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	//已经开始输出，中断连接，避免客户端把不完整的文件当作下载成功
+	log.Println("export download error", err.Error())
+	abortConnection(c)
+}
+
+// abortConnection 关闭底层连接，chunked响应缺少结束块，客户端读取时报错
+// gin.Recovery 会吞掉 http.ErrAbortHandler，只在不支持Hijack(如HTTP/2)时使用
+func abortConnection(c *gin.Context) {
+	conn, _, err := http.NewResponseController(c.Writer).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	_ = conn.Close()
+}
+
+// async 提交异步导出任务并重定向到任务查询地址，未设置查询地址时返回任务信息
+// 导出在请求结束后继续执行，gin会复用请求的Context，factory使用其副本
+func (h *exportHandler) async(c *gin.Context) {
+	exporter, err := h.factory(c.Copy(), export.WithStreaming())
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	job, err := h.jobs.Submit(c.Request.Context(), h.filename, exporter)
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	if h.location == nil {
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+	c.Redirect(http.StatusSeeOther, h.location(c, job))
+}
+
+// suffix 导出文件后缀
+func (h *exportHandler) suffix(exporter excel.Exporter) string {
+	if s, ok := exporter.(interface{ StreamFilename() string }); ok {
+		return strings.TrimPrefix(filepath.Ext(s.StreamFilename()), ".")
+	}
+	return export.ZipSuffix
+}
+
+// ExportJobHandler 查询异步导出任务，param为路由中任务id参数名
+func ExportJobHandler(jobs *export.JobManager, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := jobs.Get(c.Request.Context(), c.Param(param))
+		if err != nil {
+			if errors.Is(err, export.ErrJobNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			exportError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+func exportError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// ContentDisposition 生成Content-Disposition，非ASCII文件名按RFC 5987编码，并带上ASCII兼容文件名
+func ContentDisposition(disposition, filename string) string {
+	fallback := make([]byte, 0, len(filename))
+	ascii := true
+	for i := 0; i < len(filename); i++ {
+		b := filename[i]
+		switch {
+		case b >= 0x80:
+			ascii = false
+			//多字节字符只替换一次
+			if b >= 0xC0 {
+				fallback = append(fallback, '_')
+			}
+		case b < 0x20 || b == 0x7F || b == '"' || b == '\\':
+			ascii = false
+			fallback = append(fallback, '_')
+		default:
+			fallback = append(fallback, b)
+		}
+	}
+	if ascii {
+		return fmt.Sprintf(`%s; filename="%s"`, disposition, filename)
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback, rfc5987Escape(filename))
+}
+
+// rfc5987Escape 按RFC 5987 attr-char 编码
+func rfc5987Escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0F])
+	}
+	return b.String()
+}
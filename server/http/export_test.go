@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opdss/common/contracts/excel"
+	"github.com/opdss/common/excel/export"
+	"github.com/opdss/common/iterator"
+	"github.com/opdss/common/storage"
+)
+
+func TestContentDisposition(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		expected string
+	}{
+		{"report.csv", `attachment; filename="report.csv"`},
+		{"订单 2024.xlsx", `attachment; filename="__ 2024.xlsx"; filename*=UTF-8''%E8%AE%A2%E5%8D%95%202024.xlsx`},
+		{`a"b.zip`, `attachment; filename="a_b.zip"; filename*=UTF-8''a%22b.zip`},
+	} {
+		if actual := ContentDisposition("attachment", test.filename); actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/export", ExportHandler(func(c *gin.Context, opts ...export.Option) (excel.Exporter, error) {
+		data := []any{map[string]any{"id": 1}, map[string]any{"id": 2}}
+		return export.NewCsv(export.Headers{{Field: "id", Title: "ID"}}, iterator.NewSliceIterator(data), append(opts, export.WithForceSingleFile())...), nil
+	}, WithExportFilename("用户")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ID\n1\n2\n" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="__.csv"; filename*=UTF-8''%E7%94%A8%E6%88%B7.csv` {
+		t.Errorf("unexpected content disposition %s", cd)
+	}
+}
+
+// failExporter 写入部分内容后返回错误
+type failExporter struct {
+	written string
+}
+
+func (e *failExporter) Export(ctx context.Context) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (e *failExporter) ExportTo(ctx context.Context, w io.Writer) (int64, error) {
+	if e.written == "" {
+		return 0, errors.New("export failed")
+	}
+	n, _ := io.WriteString(w, e.written)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return int64(n), errors.New("export failed")
+}
+
+func (e *failExporter) ExportToStorage(ctx context.Context, fileStorage excel.FileStorage) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (e *failExporter) StreamFilename() string {
+	return "export.csv"
+}
+
+func TestExportHandlerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/export", ExportHandler(func(c *gin.Context, opts ...export.Option) (excel.Exporter, error) {
+		return &failExporter{written: strings.Repeat(c.Query("written"), 100)}, nil
+	}))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	//已经输出部分内容时中断连接，客户端不能读到正常结束的响应
+	resp, err := http.Get(server.URL + "/export?written=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err == nil {
+		t.Fatalf("expected transport error, got %d %q", resp.StatusCode, b)
+	}
+
+	//还未输出时返回json错误
+	resp, err = http.Get(server.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") ||
+		resp.Header.Get("Content-Disposition") != "" {
+		t.Fatalf("unexpected error response %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+// memJobStore 内存任务存储
+type memJobStore struct {
+	jobs sync.Map
+}
+
+func (s *memJobStore) Save(_ context.Context, job *export.Job) error {
+	_job := *job
+	s.jobs.Store(job.Id, &_job)
+	return nil
+}
+
+func (s *memJobStore) Get(_ context.Context, id string) (*export.Job, error) {
+	job, ok := s.jobs.Load(id)
+	if !ok {
+		return nil, export.ErrJobNotFound
+	}
+	_job := *job.(*export.Job)
+	return &_job, nil
+}
+
+func TestExportHandlerAsync(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jobs := export.NewJobManager(&memJobStore{}, storage.NewMemFS("http://localhost/files"))
+	factory := func(c *gin.Context, opts ...export.Option) (excel.Exporter, error) {
+		data := []any{map[string]any{"id": 1}, map[string]any{"id": 2}}
+		return export.NewCsv(export.Headers{{Field: "id", Title: "ID"}}, iterator.NewSliceIterator(data), opts...), nil
+	}
+	count := func(c *gin.Context) (int, error) {
+		return 2, nil
+	}
+	r := gin.New()
+	r.GET("/redirect", ExportHandler(factory, WithExportAsync(jobs, 1, count, func(c *gin.Context, job *export.Job) string {
+		return "/jobs/" + job.Id
+	})))
+	r.GET("/accepted", ExportHandler(factory, WithExportAsync(jobs, 1, count, nil)))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/redirect", nil))
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/jobs/") {
+		t.Fatalf("unexpected redirect %d %v", w.Code, w.Header())
+	}
+	//未设置查询地址时返回任务信息
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accepted", nil))
+	var job export.Job
+	if w.Code != http.StatusAccepted || json.Unmarshal(w.Body.Bytes(), &job) != nil || job.Id == "" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	jobs.Wait()
+	if _, err := jobs.Get(context.Background(), job.Id); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	storagecontract "github.com/opdss/common/contracts/storage"
+	"github.com/opdss/common/storage"
+)
+
+// LocalStorageHandler 本地存储预签名地址的下载(GET/HEAD)及上传(PUT)，路由地址需与 LocalConfig.Endpoint 一致，
+// param为路由中文件路径的通配参数名，如 r.Match([]string{"GET", "HEAD", "PUT"}, "/storage/*file", LocalStorageHandler(local, "file"))
+func LocalStorageHandler(local *storage.Local, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file := c.Param(param)
+		if err := local.VerifyRequest(c.Request, file); err != nil {
+			storageError(c, err)
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			serveObject(c, local, file)
+		case http.MethodPut:
+			opts := storagecontract.PutOptions{ContentType: c.GetHeader("Content-Type")}
+			if err := local.PutWithOptions(c.Request.Context(), file, c.Request.Body, opts); err != nil {
+				storageError(c, err)
+				return
+			}
+			c.Status(http.StatusOK)
+		default:
+			c.AbortWithStatus(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// StorageHandler 下载(GET/HEAD)任意存储中的文件，支持 Range、If-Range 等请求头，只按需读取请求的范围
+// 不做权限校验，需要时在前面的中间件中处理，如 r.GET("/files/*file", StorageHandler(fs, "file"))
+func StorageHandler(fs storagecontract.FileSystem, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			serveObject(c, fs, c.Param(param))
+		default:
+			c.AbortWithStatus(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// serveObject 存储支持 storagecontract.Metadata 时返回上传时设置的元信息及ETag
+func serveObject(c *gin.Context, fs storagecontract.FileSystem, file string) {
+	ctx := c.Request.Context()
+	var modTime time.Time
+	if m, ok := fs.(storagecontract.Metadata); ok {
+		obj, err := m.Stat(ctx, file)
+		if err != nil {
+			storageError(c, err)
+			return
+		}
+		modTime = obj.LastModified
+		//未设置时由 http.ServeContent 按扩展名或内容推断
+		if obj.ContentType != "" {
+			c.Header("Content-Type", obj.ContentType)
+		}
+		if obj.ETag != "" {
+			c.Header("ETag", `"`+obj.ETag+`"`)
+		}
+		if obj.ContentDisposition != "" {
+			c.Header("Content-Disposition", obj.ContentDisposition)
+		}
+		if obj.CacheControl != "" {
+			c.Header("Cache-Control", obj.CacheControl)
+		}
+	} else {
+		var err error
+		if modTime, err = fs.LastModified(ctx, file); err != nil {
+			storageError(c, err)
+			return
+		}
+		if mimeType, err := fs.MimeType(ctx, file); err == nil && mimeType != "" {
+			c.Header("Content-Type", mimeType)
+		}
+	}
+	r, err := storage.NewRangeReader(ctx, fs, file)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	http.ServeContent(c.Writer, c.Request, path.Base(file), modTime, r)
+}
+
+func storageError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrSignatureInvalid), errors.Is(err, storage.ErrSignatureExpired), errors.Is(err, storage.ErrSecretNotSet):
+		status = http.StatusForbidden
+	case errors.Is(err, storage.ErrPathEscapesRoot), errors.Is(err, storage.ErrInvalidKey):
+		status = http.StatusBadRequest
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	}
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
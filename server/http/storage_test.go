@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	storagecontract "github.com/opdss/common/contracts/storage"
+	"github.com/opdss/common/storage"
+)
+
+func TestLocalStorageHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	local, _ := storage.NewLocal(storage.LocalConfig{Root: t.TempDir(), Endpoint: "http://localhost/storage", Secret: "secret", MetaDir: t.TempDir()})
+	r := gin.New()
+	r.Match([]string{http.MethodGet, http.MethodHead, http.MethodPut}, "/storage/*file", LocalStorageHandler(local, "file"))
+
+	do := func(method, url, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	putUrl, err := local.PresignPut(ctx, "用户/a b.txt", time.Minute, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := do(http.MethodPut, putUrl, "application/json", "hello"); w.Code != http.StatusForbidden {
+		t.Fatalf("content type mismatch: %d", w.Code)
+	}
+	if w := do(http.MethodPut, putUrl, "text/plain", "hello"); w.Code != http.StatusOK {
+		t.Fatalf("put: %d %s", w.Code, w.Body.String())
+	}
+
+	getUrl, _ := local.PresignGet(ctx, "用户/a b.txt", time.Minute)
+	w := do(http.MethodGet, getUrl, "", "")
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("get: %d %q", w.Code, w.Body.String())
+	}
+	//上传时的Content-Type
+	if w.Header().Get("Content-Type") != "text/plain" || w.Header().Get("ETag") == "" {
+		t.Fatalf("get headers: %v", w.Header())
+	}
+	if w := do(http.MethodGet, strings.Replace(getUrl, "a%20b", "c", 1), "", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("signature for other file: %d", w.Code)
+	}
+	expiredUrl, _ := local.PresignGet(ctx, "用户/a b.txt", -time.Minute)
+	if w := do(http.MethodGet, expiredUrl, "", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expired: %d", w.Code)
+	}
+	missingUrl, _ := local.PresignGet(ctx, "missing.txt", time.Minute)
+	if w := do(http.MethodGet, missingUrl, "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("missing: %d", w.Code)
+	}
+}
+
+func TestStorageHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fs := storage.NewMemFS("http://localhost/files")
+	err := fs.PutWithOptions(context.Background(), "v/a.mp4", strings.NewReader("0123456789"), storagecontract.PutOptions{ContentType: "video/mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/files/*file", StorageHandler(fs, "file"))
+
+	do := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/files/v/a.mp4", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" || w.Header().Get("Content-Type") != "video/mp4" || etag == "" {
+		t.Fatalf("get: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w := do(map[string]string{"Range": "bytes=2-4"}); w.Code != http.StatusPartialContent || w.Body.String() != "234" ||
+		w.Header().Get("Content-Range") != "bytes 2-4/10" {
+		t.Fatalf("range: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w := do(map[string]string{"Range": "bytes=-3", "If-Range": etag}); w.Code != http.StatusPartialContent || w.Body.String() != "789" {
+		t.Fatalf("if-range: %d %q", w.Code, w.Body.String())
+	}
+	//文件已改变时返回完整内容
+	if w := do(map[string]string{"Range": "bytes=-3", "If-Range": `"stale"`}); w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("stale if-range: %d %q", w.Code, w.Body.String())
+	}
+	if w := do(map[string]string{"Range": "bytes=20-"}); w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("unsatisfiable range: %d", w.Code)
+	}
+	if w := do(map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Fatalf("if-none-match: %d", w.Code)
+	}
+	//未设置Content-Type时按内容推断
+	if err = fs.Put(context.Background(), "v/page", []byte("<html><body>hi</body></html>")); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/files/v/page", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("sniffed content type: %d %v", w.Code, w.Header())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/files/v/missing.mp4", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing: %d", w.Code)
+	}
+}
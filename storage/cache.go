@@ -0,0 +1,635 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opdss/common/contracts/storage"
+	"golang.org/x/sync/singleflight"
+)
+
+const DefaultCacheMaxSize = 1 << 30
+const DefaultCacheMaxObjectSize = 64 << 20
+const DefaultCacheTTL = time.Minute
+
+type CacheConfig struct {
+	// Dir 缓存目录，多个缓存(包括多个进程)不能共用同一目录，
+	// 默认在系统临时目录下为每个缓存单独创建 storage_cache_* 目录，Close 时删除
+	Dir           string        `help:"缓存目录" default:"" json:"dir"`
+	MaxSize       int64         `help:"缓存文件总大小上限，单位字节" default:"1073741824" json:"max_size"`
+	MaxObjectSize int64         `help:"单个文件大小上限，超过时不缓存内容，单位字节" default:"67108864" json:"max_object_size"`
+	TTL           time.Duration `help:"缓存有效期，过期后按最后修改时间重新验证" default:"1m" json:"ttl"`
+}
+
+type CacheOption func(c *Cache)
+
+// WithCacheMetaStore 文件元信息存储，默认保存在内存中
+func WithCacheMetaStore(store CacheMetaStore) CacheOption {
+	return func(c *Cache) {
+		c.store = store
+	}
+}
+
+var _ storage.FileSystem = (*Cache)(nil)
+var _ storage.RangeGetter = (*Cache)(nil)
+var _ storage.Metadata = (*Cache)(nil)
+var _ storage.MultipartUploader = (*Cache)(nil)
+
+// ErrNotSupported 底层存储不支持该操作
+var ErrNotSupported = errors.New("operation not supported by storage")
+
+// Cache 读缓存，文件内容缓存在本地磁盘，元信息缓存在 CacheMetaStore
+// 缓存过期后使用最后修改时间及ETag验证，未变化时继续使用缓存的内容
+// 只有通过 Cache 写入、删除的文件会立即失效，直接写入底层存储时最多延迟 TTL 生效
+type Cache struct {
+	fs            storage.FileSystem
+	store         CacheMetaStore
+	disk          *diskCache
+	ttl           time.Duration
+	maxObjectSize int64
+	tempDir       string //自动创建的缓存目录，Close时删除
+	group         singleflight.Group
+}
+
+func NewCache(fs storage.FileSystem, conf CacheConfig, opts ...CacheOption) (*Cache, error) {
+	var tempDir string
+	if conf.Dir == "" {
+		dir, err := os.MkdirTemp("", "storage_cache_*")
+		if err != nil {
+			return nil, err
+		}
+		conf.Dir, tempDir = dir, dir
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = DefaultCacheMaxSize
+	}
+	if conf.MaxObjectSize <= 0 || conf.MaxObjectSize > conf.MaxSize {
+		conf.MaxObjectSize = min(DefaultCacheMaxObjectSize, conf.MaxSize)
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = DefaultCacheTTL
+	}
+	disk, err := newDiskCache(conf.Dir, conf.MaxSize)
+	if err != nil {
+		if tempDir != "" {
+			_ = os.RemoveAll(tempDir)
+		}
+		return nil, err
+	}
+	c := &Cache{
+		fs:            fs,
+		disk:          disk,
+		ttl:           conf.TTL,
+		maxObjectSize: conf.MaxObjectSize,
+		tempDir:       tempDir,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.store == nil {
+		c.store = NewMemCacheMetaStore(0)
+	}
+	return c, nil
+}
+
+// Close 删除自动创建的缓存目录，指定了 Dir 时保留缓存文件
+func (r *Cache) Close() error {
+	if r.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(r.tempDir)
+}
+
+// Unwrap 底层存储
+func (r *Cache) Unwrap() storage.FileSystem {
+	return r.fs
+}
+
+func (r *Cache) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*storage.ListObjectRes, error) {
+	return r.fs.ListObjects(ctx, opt)
+}
+
+func (r *Cache) Copy(ctx context.Context, originFile, targetFile string) error {
+	defer r.invalidate(ctx, targetFile)
+	return r.fs.Copy(ctx, originFile, targetFile)
+}
+
+func (r *Cache) Delete(ctx context.Context, files ...string) error {
+	defer r.invalidate(ctx, files...)
+	return r.fs.Delete(ctx, files...)
+}
+
+func (r *Cache) DeleteDirectory(ctx context.Context, directory string) error {
+	defer func() {
+		prefix, err := dirKey(directory)
+		if err != nil {
+			return
+		}
+		r.disk.removePrefix(prefix)
+		if err = r.store.Clear(ctx, prefix); err != nil {
+			log.Println("storage cache clear error", prefix, err.Error())
+		}
+	}()
+	return r.fs.DeleteDirectory(ctx, directory)
+}
+
+func (r *Cache) Directories(ctx context.Context, path string) ([]string, error) {
+	return r.fs.Directories(ctx, path)
+}
+
+func (r *Cache) Exists(ctx context.Context, file string) bool {
+	_, err := r.stat(ctx, file)
+	return err == nil
+}
+
+func (r *Cache) Files(ctx context.Context, path string) ([]string, error) {
+	return r.fs.Files(ctx, path)
+}
+
+func (r *Cache) Get(ctx context.Context, file string) ([]byte, error) {
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if content, ok := r.disk.get(meta.Key, meta.LastModified); ok {
+		return content, nil
+	}
+	v, err, shared := r.group.Do("get:"+meta.Key, func() (interface{}, error) {
+		content, err := r.fs.Get(ctx, meta.Key)
+		if err == nil && int64(len(content)) <= r.maxObjectSize {
+			r.disk.put(meta.Key, meta.LastModified, content)
+		}
+		return content, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		return bytes.Clone(v.([]byte)), nil
+	}
+	return v.([]byte), nil
+}
+
+// GetStream 未缓存时边读边写入缓存，读取完整后才加入缓存
+func (r *Cache) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := r.disk.open(meta.Key, meta.LastModified); ok {
+		return f, nil
+	}
+	rc, err := r.fs.GetStream(ctx, meta.Key)
+	if err != nil || meta.Size > r.maxObjectSize {
+		return rc, err
+	}
+	tmp, err := r.disk.temp()
+	if err != nil {
+		return rc, nil
+	}
+	return &cacheReader{ReadCloser: rc, tmp: tmp, disk: r.disk, key: meta.Key, lastModified: meta.LastModified}, nil
+}
+
+// GetRange 已缓存时从本地读取，否则直接读取底层存储，不写入缓存
+func (r *Cache) GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, ErrInvalidRange
+	}
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkRange(offset, meta.Size); err != nil {
+		return nil, err
+	}
+	if f, ok := r.disk.open(meta.Key, meta.LastModified); ok {
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return limitReadCloser(f, length), nil
+	}
+	return getRange(ctx, r.fs, meta.Key, offset, length)
+}
+
+func (r *Cache) LastModified(ctx context.Context, file string) (time.Time, error) {
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return meta.LastModified, nil
+}
+
+func (r *Cache) MakeDirectory(ctx context.Context, directory string) error {
+	return r.fs.MakeDirectory(ctx, directory)
+}
+
+func (r *Cache) MimeType(ctx context.Context, file string) (string, error) {
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	return meta.MimeType, nil
+}
+
+func (r *Cache) Missing(ctx context.Context, file string) bool {
+	return !r.Exists(ctx, file)
+}
+
+func (r *Cache) Move(ctx context.Context, oldFile, newFile string) error {
+	defer r.invalidate(ctx, oldFile, newFile)
+	return r.fs.Move(ctx, oldFile, newFile)
+}
+
+func (r *Cache) Path(file string) string {
+	return r.fs.Path(file)
+}
+
+func (r *Cache) Put(ctx context.Context, file string, content []byte) error {
+	defer r.invalidate(ctx, file)
+	return r.fs.Put(ctx, file, content)
+}
+
+func (r *Cache) PutStream(ctx context.Context, file string, rs io.Reader) error {
+	defer r.invalidate(ctx, file)
+	return r.fs.PutStream(ctx, file, rs)
+}
+
+// PutWithOptions 底层存储未实现 storage.Metadata 时返回 ErrNotSupported
+func (r *Cache) PutWithOptions(ctx context.Context, file string, rs io.Reader, opts storage.PutOptions) error {
+	m, ok := r.fs.(storage.Metadata)
+	if !ok {
+		return ErrNotSupported
+	}
+	defer r.invalidate(ctx, file)
+	return m.PutWithOptions(ctx, file, rs, opts)
+}
+
+// Stat 底层存储实现了 storage.Metadata 时直接查询，否则使用缓存的元信息
+func (r *Cache) Stat(ctx context.Context, file string) (*storage.ObjectInfo, error) {
+	if m, ok := r.fs.(storage.Metadata); ok {
+		return m.Stat(ctx, file)
+	}
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.ObjectInfo{
+		Key:          meta.Key,
+		Size:         meta.Size,
+		ContentType:  meta.MimeType,
+		ETag:         meta.ETag,
+		LastModified: meta.LastModified,
+	}, nil
+}
+
+// uploader 底层存储的分片上传
+func (r *Cache) uploader() (storage.MultipartUploader, error) {
+	u, ok := r.fs.(storage.MultipartUploader)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return u, nil
+}
+
+// InitUpload 底层存储未实现 storage.MultipartUploader 时返回 ErrNotSupported
+func (r *Cache) InitUpload(ctx context.Context, file string, contentType string) (string, error) {
+	u, err := r.uploader()
+	if err != nil {
+		return "", err
+	}
+	return u.InitUpload(ctx, file, contentType)
+}
+
+func (r *Cache) UploadPart(ctx context.Context, file, uploadId string, number int, rs io.Reader, size int64) (storage.UploadPart, error) {
+	u, err := r.uploader()
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	return u.UploadPart(ctx, file, uploadId, number, rs, size)
+}
+
+func (r *Cache) ListParts(ctx context.Context, file, uploadId string) ([]storage.UploadPart, error) {
+	u, err := r.uploader()
+	if err != nil {
+		return nil, err
+	}
+	return u.ListParts(ctx, file, uploadId)
+}
+
+func (r *Cache) CompleteUpload(ctx context.Context, file, uploadId string, parts []storage.UploadPart) error {
+	u, err := r.uploader()
+	if err != nil {
+		return err
+	}
+	defer r.invalidate(ctx, file)
+	return u.CompleteUpload(ctx, file, uploadId, parts)
+}
+
+func (r *Cache) AbortUpload(ctx context.Context, file, uploadId string) error {
+	u, err := r.uploader()
+	if err != nil {
+		return err
+	}
+	return u.AbortUpload(ctx, file, uploadId)
+}
+
+func (r *Cache) Size(ctx context.Context, file string) (int64, error) {
+	meta, err := r.stat(ctx, file)
+	if err != nil {
+		return 0, err
+	}
+	return meta.Size, nil
+}
+
+func (r *Cache) Url(file string) string {
+	return r.fs.Url(file)
+}
+
+// invalidate 写入失败时底层文件也可能已改变，所以无论成功与否都清除缓存
+func (r *Cache) invalidate(ctx context.Context, files ...string) {
+	keys, err := objectKeys(files)
+	if err != nil {
+		return
+	}
+	r.disk.remove(keys...)
+	if err = r.store.Delete(ctx, keys...); err != nil {
+		log.Println("storage cache delete error", strings.Join(keys, ","), err.Error())
+	}
+}
+
+// stat 文件元信息，文件不存在时返回 fs.ErrNotExist
+func (r *Cache) stat(ctx context.Context, file string) (*CacheMeta, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := r.store.Get(ctx, key)
+	if err != nil && err != ErrCacheMiss {
+		log.Println("storage cache get error", key, err.Error())
+	}
+	if meta == nil || time.Since(meta.Checked) >= r.ttl {
+		v, err, _ := r.group.Do("stat:"+key, func() (interface{}, error) {
+			return r.revalidate(ctx, key, meta)
+		})
+		if err != nil {
+			return nil, err
+		}
+		meta = v.(*CacheMeta)
+	}
+	if !meta.Exists {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+	return meta, nil
+}
+
+// revalidate 最后修改时间及ETag未变化时继续使用缓存的内容
+func (r *Cache) revalidate(ctx context.Context, key string, old *CacheMeta) (*CacheMeta, error) {
+	meta, err := r.fetch(ctx, key, old)
+	if err != nil {
+		return nil, err
+	}
+	if !meta.Exists || old == nil || !old.LastModified.Equal(meta.LastModified) || old.ETag != meta.ETag {
+		r.disk.remove(key)
+	}
+	if err = r.store.Set(ctx, meta); err != nil {
+		log.Println("storage cache set error", key, err.Error())
+	}
+	return meta, nil
+}
+
+// fetch 存储支持 Stat 时一次获取全部元信息，否则最后修改时间变化时才重新获取大小和类型
+func (r *Cache) fetch(ctx context.Context, key string, old *CacheMeta) (*CacheMeta, error) {
+	meta := &CacheMeta{Key: key, Checked: time.Now()}
+	if m, ok := r.fs.(storage.Metadata); ok {
+		info, err := m.Stat(ctx, key)
+		if err != nil {
+			if r.fs.Exists(ctx, key) {
+				return nil, err
+			}
+			return meta, nil
+		}
+		meta.Exists, meta.Size, meta.MimeType, meta.ETag, meta.LastModified = true, info.Size, info.ContentType, info.ETag, info.LastModified
+		return meta, nil
+	}
+	lastModified, err := r.fs.LastModified(ctx, key)
+	switch {
+	case err != nil:
+		if r.fs.Exists(ctx, key) {
+			return nil, err
+		}
+	case old != nil && old.Exists && old.LastModified.Equal(lastModified):
+		meta.Exists, meta.Size, meta.MimeType, meta.LastModified = true, old.Size, old.MimeType, old.LastModified
+	default:
+		if meta.Size, err = r.fs.Size(ctx, key); err != nil {
+			return nil, err
+		}
+		if meta.MimeType, err = r.fs.MimeType(ctx, key); err != nil {
+			return nil, err
+		}
+		meta.Exists, meta.LastModified = true, lastModified
+	}
+	return meta, nil
+}
+
+// cacheReader 读取底层文件的同时写入缓存临时文件，读到EOF时加入缓存
+type cacheReader struct {
+	io.ReadCloser
+	tmp          *os.File
+	disk         *diskCache
+	key          string
+	lastModified time.Time
+	size         int64
+	failed       bool
+}
+
+func (c *cacheReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.failed {
+		if _, werr := c.tmp.Write(p[:n]); werr != nil {
+			c.failed = true
+		}
+		c.size += int64(n)
+	}
+	if err == io.EOF && !c.failed {
+		c.failed = true //只提交一次
+		if cerr := c.tmp.Close(); cerr == nil {
+			c.disk.commit(c.key, c.lastModified, c.tmp.Name(), c.size)
+		}
+	} else if err != nil {
+		c.failed = true
+	}
+	return n, err
+}
+
+func (c *cacheReader) Close() error {
+	_ = c.tmp.Close()
+	_ = os.Remove(c.tmp.Name())
+	return c.ReadCloser.Close()
+}
+
+// diskCache 本地磁盘内容缓存，超过 maxSize 时淘汰最久未使用的文件
+type diskCache struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List //最近使用的在前
+	items   map[string]*list.Element
+}
+
+type diskEntry struct {
+	key          string
+	size         int64
+	lastModified time.Time
+}
+
+// newDiskCache 索引只保存在内存中，启动时清除目录中上次运行留下的缓存文件
+func newDiskCache(dir string, maxSize int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); isCacheName(name) || strings.HasPrefix(name, ".cache_") {
+			_ = os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return &diskCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+	}, nil
+}
+
+func (c *diskCache) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// open 缓存的文件，最后修改时间不一致时删除缓存
+func (c *diskCache) open(key string, lastModified time.Time) (*os.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if !elem.Value.(*diskEntry).lastModified.Equal(lastModified) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	f, err := os.Open(c.name(key))
+	if err != nil {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return f, true
+}
+
+func (c *diskCache) get(key string, lastModified time.Time) ([]byte, bool) {
+	f, ok := c.open(key, lastModified)
+	if !ok {
+		return nil, false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		c.remove(key)
+		return nil, false
+	}
+	return content, true
+}
+
+func (c *diskCache) put(key string, lastModified time.Time, content []byte) {
+	f, err := c.temp()
+	if err != nil {
+		return
+	}
+	_, err = f.Write(content)
+	if cerr := f.Close(); err != nil || cerr != nil {
+		_ = os.Remove(f.Name())
+		return
+	}
+	c.commit(key, lastModified, f.Name(), int64(len(content)))
+}
+
+func (c *diskCache) temp() (*os.File, error) {
+	return os.CreateTemp(c.dir, ".cache_*")
+}
+
+// commit 临时文件加入缓存
+func (c *diskCache) commit(key string, lastModified time.Time, tmp string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size > c.maxSize {
+		_ = os.Remove(tmp)
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	if err := os.Rename(tmp, c.name(key)); err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&diskEntry{key: key, size: size, lastModified: lastModified})
+	c.size += size
+	for c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *diskCache) remove(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *diskCache) removePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *diskCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*diskEntry)
+	delete(c.items, entry.key)
+	c.size -= entry.size
+	_ = os.Remove(c.name(entry.key))
+}
+
+func isCacheName(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
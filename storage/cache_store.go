@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultCacheKeyPrefix DefaultCacheMetaTTL redis元信息存储默认key前缀及过期时间
+const DefaultCacheKeyPrefix = "storage:cache:"
+const DefaultCacheMetaTTL = 24 * time.Hour
+
+// DefaultCacheMetaEntries 内存元信息存储默认最大条数
+const DefaultCacheMetaEntries = 100000
+
+var ErrCacheMiss = errors.New("storage cache miss")
+
+// CacheMeta 缓存的文件元信息，Exists为false时表示文件不存在
+type CacheMeta struct {
+	Key          string    `json:"key"`
+	Exists       bool      `json:"exists"`
+	Size         int64     `json:"size"`
+	MimeType     string    `json:"mime_type"`
+	ETag         string    `json:"etag"` //存储不支持 Stat 时为空
+	LastModified time.Time `json:"last_modified"`
+	Checked      time.Time `json:"checked"` //最后验证时间
+}
+
+// CacheMetaStore 文件元信息存储
+type CacheMetaStore interface {
+	// Get 未缓存时返回 ErrCacheMiss
+	Get(ctx context.Context, key string) (*CacheMeta, error)
+	Set(ctx context.Context, meta *CacheMeta) error
+	Delete(ctx context.Context, keys ...string) error
+	// Clear 删除目录前缀下的所有元信息
+	Clear(ctx context.Context, prefix string) error
+}
+
+var _ CacheMetaStore = (*MemCacheMetaStore)(nil)
+var _ CacheMetaStore = (*RedisCacheMetaStore)(nil)
+
+// MemCacheMetaStore 内存元信息存储，超过最大条数时随机淘汰
+type MemCacheMetaStore struct {
+	mu         sync.RWMutex
+	maxEntries int
+	items      map[string]CacheMeta
+}
+
+// NewMemCacheMetaStore maxEntries<=0时使用 DefaultCacheMetaEntries
+func NewMemCacheMetaStore(maxEntries int) *MemCacheMetaStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMetaEntries
+	}
+	return &MemCacheMetaStore{maxEntries: maxEntries, items: make(map[string]CacheMeta)}
+}
+
+func (s *MemCacheMetaStore) Get(ctx context.Context, key string) (*CacheMeta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	meta, ok := s.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return &meta, nil
+}
+
+func (s *MemCacheMetaStore) Set(ctx context.Context, meta *CacheMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[meta.Key]; !ok && len(s.items) >= s.maxEntries {
+		for key := range s.items {
+			delete(s.items, key)
+			break
+		}
+	}
+	s.items[meta.Key] = *meta
+	return nil
+}
+
+func (s *MemCacheMetaStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.items, key)
+	}
+	return nil
+}
+
+func (s *MemCacheMetaStore) Clear(ctx context.Context, prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.items, key)
+		}
+	}
+	return nil
+}
+
+// RedisCacheMetaStore 基于redis的元信息存储，多个实例共享缓存的元信息
+// 不同存储使用同一redis时需要设置不同的前缀
+type RedisCacheMetaStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisCacheMetaStore prefix为空时使用 DefaultCacheKeyPrefix，ttl<=0时使用 DefaultCacheMetaTTL
+func NewRedisCacheMetaStore(client *redis.Client, prefix string, ttl time.Duration) *RedisCacheMetaStore {
+	if prefix == "" {
+		prefix = DefaultCacheKeyPrefix
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheMetaTTL
+	}
+	return &RedisCacheMetaStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisCacheMetaStore) Get(ctx context.Context, key string) (*CacheMeta, error) {
+	b, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrCacheMiss
+		}
+		return nil, err
+	}
+	meta := &CacheMeta{}
+	if err = json.Unmarshal(b, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func (s *RedisCacheMetaStore) Set(ctx context.Context, meta *CacheMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+meta.Key, b, s.ttl).Err()
+}
+
+func (s *RedisCacheMetaStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = s.prefix + key
+	}
+	return s.client.Del(ctx, names...).Err()
+}
+
+func (s *RedisCacheMetaStore) Clear(ctx context.Context, prefix string) error {
+	iter := s.client.Scan(ctx, 0, globEscape(s.prefix+prefix)+"*", 1000).Iterator()
+	var names []string
+	for iter.Next(ctx) {
+		names = append(names, iter.Val())
+		if len(names) == 1000 {
+			if err := s.client.Del(ctx, names...).Err(); err != nil {
+				return err
+			}
+			names = names[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		return s.client.Del(ctx, names...).Err()
+	}
+	return nil
+}
+
+// globEscape 转义redis匹配模式中的特殊字符
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\^`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+// countFS 统计底层存储的读取次数
+type countFS struct {
+	*MemFS
+	gets atomic.Int32
+}
+
+func (c *countFS) Get(ctx context.Context, file string) ([]byte, error) {
+	c.gets.Add(1)
+	return c.MemFS.Get(ctx, file)
+}
+
+func (c *countFS) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
+	c.gets.Add(1)
+	return c.MemFS.GetStream(ctx, file)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	inner := &countFS{MemFS: NewMemFS("http://localhost")}
+	cache, err := NewCache(inner, CacheConfig{Dir: t.TempDir(), MaxSize: 10, TTL: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(file, want string, gets int32) {
+		t.Helper()
+		rc, err := cache.GetStream(ctx, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		if string(b) != want || inner.gets.Load() != gets {
+			t.Fatalf("%s = %q, gets %d, want %q, %d", file, b, inner.gets.Load(), want, gets)
+		}
+	}
+
+	if cache.Exists(ctx, "a.txt") {
+		t.Fatal("a.txt should be missing")
+	}
+	//通过缓存写入时立即失效
+	if err = cache.Put(ctx, "a.txt", []byte("aaa")); err != nil {
+		t.Fatal(err)
+	}
+	read("a.txt", "aaa", 1)
+	read("a.txt", "aaa", 1)
+	if b, err := cache.Get(ctx, "a.txt"); string(b) != "aaa" || err != nil || inner.gets.Load() != 1 {
+		t.Fatalf("Get = %q, %v", b, err)
+	}
+
+	//直接修改底层存储时，过期后重新验证
+	_ = inner.Put(ctx, "a.txt", []byte("bbbb"))
+	read("a.txt", "aaa", 1)
+	time.Sleep(60 * time.Millisecond)
+	if size, _ := cache.Size(ctx, "a.txt"); size != 4 {
+		t.Fatalf("size = %d", size)
+	}
+	read("a.txt", "bbbb", 2)
+
+	//超过缓存大小时淘汰最久未使用的文件
+	_ = cache.Put(ctx, "b.txt", []byte("cccccccc"))
+	read("b.txt", "cccccccc", 3)
+	read("a.txt", "bbbb", 4)
+
+	if err = cache.Move(ctx, "a.txt", "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Exists(ctx, "a.txt") || !cache.Exists(ctx, "c.txt") {
+		t.Fatal("move not visible")
+	}
+	if err = cache.DeleteDirectory(ctx, ""); err == nil {
+		t.Fatal("expected root error")
+	}
+}
+
+func TestCacheDefaultDir(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemFS("http://localhost")
+	_ = inner.Put(ctx, "a.txt", []byte("aaa"))
+	//未指定目录时每个缓存使用单独的临时目录
+	var dirs []string
+	for range 2 {
+		cache, err := NewCache(inner, CacheConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := cache.Get(ctx, "a.txt"); string(b) != "aaa" || err != nil {
+			t.Fatalf("Get = %q, %v", b, err)
+		}
+		dirs = append(dirs, cache.disk.dir)
+		if err = cache.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(cache.disk.dir); !os.IsNotExist(err) {
+			t.Fatalf("%s not removed: %v", cache.disk.dir, err)
+		}
+	}
+	if dirs[0] == dirs[1] {
+		t.Fatalf("caches share dir %s", dirs[0])
+	}
+	//指定的目录关闭后保留
+	dir := t.TempDir()
+	cache, err := NewCache(inner, CacheConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheMetadata(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewCache(NewMemFS("http://localhost"), CacheConfig{Dir: t.TempDir(), TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = cache.Put(ctx, "a.txt", []byte("aaa"))
+	if b, _ := cache.Get(ctx, "a.txt"); string(b) != "aaa" {
+		t.Fatalf("Get = %q", b)
+	}
+	//通过 PutWithOptions 写入时立即失效
+	if err = cache.PutWithOptions(ctx, "a.txt", strings.NewReader("bbbb"), storage.PutOptions{ContentType: "text/csv"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := cache.Get(ctx, "a.txt"); string(b) != "bbbb" {
+		t.Fatalf("Get after PutWithOptions = %q", b)
+	}
+	if info, err := cache.Stat(ctx, "a.txt"); err != nil || info.Size != 4 || info.ContentType != "text/csv" {
+		t.Fatalf("Stat = %+v, %v", info, err)
+	}
+	if _, err = cache.InitUpload(ctx, "b.txt", ""); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("InitUpload err = %v", err)
+	}
+}
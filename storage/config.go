@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+const DriverLocal = "local"
+
+const DriverOss = "oss"
+
+const DriverCos = "cos"
+
+const DriverS3 = "s3"
+
+// DriverMemory 内存存储，仅用于测试，访问地址使用 Local.Endpoint
+const DriverMemory = "memory"
+
+// Config 存储配置，根据 Driver 选择对应驱动的配置创建存储
+type Config struct {
+	Driver string      `help:"存储驱动,可选[local|oss|cos|s3|memory]" default:"local" json:"driver"`
+	Local  LocalConfig `json:"local"`
+	Oss    OssConfig   `json:"oss"`
+	Cos    CosConfig   `json:"cos"`
+	S3     S3Config    `json:"s3"`
+}
+
+// FileSystem 根据驱动创建存储
+func (conf *Config) FileSystem() (storage.FileSystem, error) {
+	switch conf.Driver {
+	case DriverLocal:
+		return NewLocal(conf.Local)
+	case DriverOss:
+		return NewOss(conf.Oss)
+	case DriverCos:
+		return NewCos(conf.Cos)
+	case DriverS3:
+		return NewS3(conf.S3)
+	case DriverMemory:
+		return NewMemFS(conf.Local.Endpoint), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", conf.Driver)
+	}
+}
+
+func NewFileSystem(cfg Config) (storage.FileSystem, error) {
+	return cfg.FileSystem()
+}
+
+// CredentialsProvider 根据驱动创建临时凭证签发，只支持 oss、cos、s3
+func (conf *Config) CredentialsProvider(opts ...STSOption) (storage.CredentialsProvider, error) {
+	switch conf.Driver {
+	case DriverOss:
+		return NewOssSTS(conf.Oss, opts...)
+	case DriverCos:
+		return NewCosSTS(conf.Cos, opts...)
+	case DriverS3:
+		return NewS3STS(conf.S3, opts...)
+	default:
+		return nil, fmt.Errorf("storage driver %q does not support sts", conf.Driver)
+	}
+}
@@ -0,0 +1,70 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/opdss/common/contracts/storage"
+	storage2 "github.com/opdss/common/storage"
+	"github.com/opdss/common/storage/storagetest"
+)
+
+func TestLocalConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileSystem {
+		fs, _ := storage2.NewLocal(storage2.LocalConfig{Root: t.TempDir(), Endpoint: "http://localhost", UploadDir: t.TempDir(), MetaDir: t.TempDir()})
+		return fs
+	})
+}
+
+func TestMemFSConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileSystem {
+		return storage2.NewMemFS("http://localhost")
+	})
+}
+
+func TestCacheConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileSystem {
+		//底层使用支持元信息及分片上传的存储，缓存需要透传这些操作
+		inner, _ := storage2.NewLocal(storage2.LocalConfig{Root: t.TempDir(), Endpoint: "http://localhost", UploadDir: t.TempDir(), MetaDir: t.TempDir()})
+		fs, _ := storage2.NewCache(inner, storage2.CacheConfig{Dir: t.TempDir()})
+		return fs
+	})
+}
+
+func TestS3Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileSystem {
+		return newTestS3(t)
+	})
+}
+
+func TestS3PutStream(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestS3(t)
+	//超过一个分片大小时分片上传
+	data := bytes.Repeat([]byte("0123456789"), int(storage2.MinPartSize/10)*2+1)
+	if err := fs.PutStream(ctx, "big.bin", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := fs.Get(ctx, "big.bin"); err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("content mismatch: %d, %v", len(b), err)
+	}
+}
+
+func newTestS3(t *testing.T) *storage2.S3 {
+	srv := storagetest.NewS3Server("test")
+	t.Cleanup(srv.Close)
+	fs, err := storage2.NewS3(storage2.S3Config{
+		AccessKeyId:     "test",
+		AccessKeySecret: "test",
+		Bucket:          "test",
+		Region:          "us-east-1",
+		Endpoint:        srv.URL,
+		PartSize:        storage2.MinPartSize,
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
@@ -20,14 +20,22 @@ import (
 * Document: https://cloud.tencent.com/document/product/436/31215
  */
 type CosConfig struct {
-	AccessKeyId     string `help:"accessKeyId" default:""  json:"access_key_id""`
+	AccessKeyId     string `help:"accessKeyId" default:""  json:"access_key_id"`
 	AccessKeySecret string `help:"accessKeySecret" default:""  json:"access_key_secret"`
+	RoleArn         string `help:"roleArn" default:"" json:"role_arn"`
+	Region          string `help:"地区，如 ap-guangzhou，为空时从api入口中获取" default:"" json:"region"`
 	Bucket          string `help:"存储桶" default:"" json:"bucket"`
 	Url             string `help:"访问地址" default:"" json:"url"`
 	Endpoint        string `help:"api入口" default:"" json:"endpoint"`
+	PartSize        int64  `help:"分片上传的分片大小，单位字节" default:"8388608" json:"part_size"`
+	Concurrency     int    `help:"分片上传并发数" default:"4" json:"concurrency"`
 }
 
 var _ storage.FileSystem = (*Cos)(nil)
+var _ storage.Signer = (*Cos)(nil)
+var _ storage.MultipartUploader = (*Cos)(nil)
+var _ storage.Metadata = (*Cos)(nil)
+var _ storage.RangeGetter = (*Cos)(nil)
 
 type Cos struct {
 	config   CosConfig
@@ -63,15 +71,18 @@ func NewCos(config CosConfig) (*Cos, error) {
 	}, nil
 }
 
-func (r *Cos) ListObjects(ctx context.Context, opt *ListObjectOpts) (*ListObjectRes, error) {
-	vPath := validPath(opt.Directory)
+func (r *Cos) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*storage.ListObjectRes, error) {
+	vPath, err := validPath(opt.Directory)
+	if err != nil {
+		return nil, err
+	}
 	v, _, err := r.instance.Bucket.Get(ctx, &cos.BucketGetOptions{
 		Prefix:  vPath,
 		Marker:  opt.NextToken,
 		MaxKeys: int(getPageSize(opt.MaxKeys)),
 	})
-	res := &ListObjectRes{
-		List: make([]ListObject, 0),
+	res := &storage.ListObjectRes{
+		List: make([]storage.ListObject, 0),
 	}
 	if err != nil {
 		return res, err
@@ -80,7 +91,7 @@ func (r *Cos) ListObjects(ctx context.Context, opt *ListObjectOpts) (*ListObject
 		res.HasMore = v.IsTruncated
 		res.NextToken = v.NextMarker
 		if strings.HasSuffix(item.Key, "/") {
-			res.List = append(res.List, ListObject{
+			res.List = append(res.List, storage.ListObject{
 				Name:  strings.Trim(strings.ReplaceAll(item.Key, vPath, ""), "/"),
 				IsDir: true,
 			})
@@ -93,7 +104,7 @@ func (r *Cos) ListObjects(ctx context.Context, opt *ListObjectOpts) (*ListObject
 			if _t, _err := time.Parse(time.RFC3339, item.LastModified); _err == nil {
 				t = _t
 			}
-			res.List = append(res.List, ListObject{
+			res.List = append(res.List, storage.ListObject{
 				Name:         file,
 				IsDir:        false,
 				Size:         item.Size,
@@ -107,7 +118,14 @@ func (r *Cos) ListObjects(ctx context.Context, opt *ListObjectOpts) (*ListObject
 }
 
 func (r *Cos) Copy(ctx context.Context, originFile, targetFile string) error {
-	originFile = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSuffix(r.config.Endpoint, "/")+"/"+strings.TrimPrefix(originFile, "/"), "https://", ""), "http://", "")
+	originFile, err := objectKey(originFile)
+	if err != nil {
+		return err
+	}
+	if targetFile, err = objectKey(targetFile); err != nil {
+		return err
+	}
+	originFile = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSuffix(r.config.Endpoint, "/")+"/"+originFile, "https://", ""), "http://", "")
 	if _, _, err := r.instance.Object.Copy(ctx, targetFile, originFile, nil); err != nil {
 		return err
 	}
@@ -116,8 +134,12 @@ func (r *Cos) Copy(ctx context.Context, originFile, targetFile string) error {
 }
 
 func (r *Cos) Delete(ctx context.Context, files ...string) error {
+	keys, err := objectKeys(files)
+	if err != nil {
+		return err
+	}
 	var obs []cos.Object
-	for _, v := range files {
+	for _, v := range keys {
 		obs = append(obs, cos.Object{Key: v})
 	}
 	opt := &cos.ObjectDeleteMultiOptions{
@@ -131,8 +153,9 @@ func (r *Cos) Delete(ctx context.Context, files ...string) error {
 }
 
 func (r *Cos) DeleteDirectory(ctx context.Context, directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
+	directory, err := dirKey(directory)
+	if err != nil {
+		return err
 	}
 	var marker string
 	opt := &cos.BucketGetOptions{
@@ -166,7 +189,10 @@ func (r *Cos) DeleteDirectory(ctx context.Context, directory string) error {
 func (r *Cos) Directories(ctx context.Context, path string) ([]string, error) {
 	var directories []string
 	var marker string
-	vPath := validPath(path)
+	vPath, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
 	opt := &cos.BucketGetOptions{
 		Prefix:    vPath,
 		Delimiter: "/",
@@ -190,7 +216,11 @@ func (r *Cos) Directories(ctx context.Context, path string) ([]string, error) {
 }
 
 func (r *Cos) Exists(ctx context.Context, file string) bool {
-	ok, err := r.instance.Object.IsExist(ctx, file)
+	key, err := objectKey(file)
+	if err != nil {
+		return false
+	}
+	ok, err := r.instance.Object.IsExist(ctx, key)
 	if err != nil {
 		return false
 	}
@@ -200,7 +230,10 @@ func (r *Cos) Exists(ctx context.Context, file string) bool {
 func (r *Cos) Files(ctx context.Context, path string) ([]string, error) {
 	var files []string
 	var marker string
-	vPath := validPath(path)
+	vPath, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
 	opt := &cos.BucketGetOptions{
 		Prefix:    vPath,
 		Delimiter: "/",
@@ -235,10 +268,34 @@ func (r *Cos) Get(ctx context.Context, file string) ([]byte, error) {
 }
 
 func (r *Cos) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
 	opt := &cos.ObjectGetOptions{
 		ResponseContentType: "text/html",
 	}
-	resp, err := r.instance.Object.Get(ctx, file, opt)
+	resp, err := r.instance.Object.Get(ctx, key, opt)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (r *Cos) GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := httpRange(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.instance.Object.Get(ctx, key, &cos.ObjectGetOptions{Range: rng})
+	var ce *cos.ErrorResponse
+	if errors.As(err, &ce) && ce.Response != nil && ce.Response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return rangeNotSatisfiable(offset)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +303,11 @@ func (r *Cos) GetStream(ctx context.Context, file string) (io.ReadCloser, error)
 }
 
 func (r *Cos) LastModified(ctx context.Context, file string) (time.Time, error) {
-	resp, err := r.instance.Object.Head(ctx, file, nil)
+	key, err := objectKey(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := r.instance.Object.Head(ctx, key, nil)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -254,8 +315,9 @@ func (r *Cos) LastModified(ctx context.Context, file string) (time.Time, error)
 }
 
 func (r *Cos) MakeDirectory(ctx context.Context, directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
+	directory, err := dirKey(directory)
+	if err != nil {
+		return err
 	}
 	if _, err := r.instance.Object.Put(ctx, directory, strings.NewReader(""), nil); err != nil {
 		return err
@@ -265,7 +327,11 @@ func (r *Cos) MakeDirectory(ctx context.Context, directory string) error {
 }
 
 func (r *Cos) MimeType(ctx context.Context, file string) (string, error) {
-	resp, err := r.instance.Object.Head(ctx, file, nil)
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.instance.Object.Head(ctx, key, nil)
 	if err != nil {
 		return "", err
 	}
@@ -283,19 +349,50 @@ func (r *Cos) Move(ctx context.Context, oldFile, newFile string) error {
 	return r.Delete(ctx, oldFile)
 }
 
+// Path 规范化后的文件路径，路径不合法时返回空字符串
 func (r *Cos) Path(file string) string {
-	return file
+	key, _ := objectKey(file)
+	return key
 }
 
 func (r *Cos) Put(ctx context.Context, file string, content []byte) error {
 	return r.PutStream(ctx, file, bytes.NewReader(content))
 }
 
+// PutStream 流式上传，超过一个分片大小时使用分片上传
 func (r *Cos) PutStream(ctx context.Context, file string, rs io.Reader) error {
-	_, err := r.instance.Object.Put(ctx, file, rs, nil)
+	return r.PutWithOptions(ctx, file, rs, storage.PutOptions{})
+}
+
+func (r *Cos) PutWithOptions(ctx context.Context, file string, rs io.Reader, opts storage.PutOptions) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	return multipartPut(ctx, r, key, rs, opts, r.config.PartSize, r.config.Concurrency)
+}
+
+func (r *Cos) putObject(ctx context.Context, key string, content []byte, opts storage.PutOptions) error {
+	acl, header := cosOptions(opts)
+	_, err := r.instance.Object.Put(ctx, key, bytes.NewReader(content), &cos.ObjectPutOptions{
+		ACLHeaderOptions:       acl,
+		ObjectPutHeaderOptions: header,
+	})
 	return err
 }
 
+func (r *Cos) Stat(ctx context.Context, file string) (*storage.ObjectInfo, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.instance.Object.Head(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return headerObjectInfo(key, resp.Header, "X-Cos-Meta-", "X-Cos-Storage-Class")
+}
+
 //
 //func (r *Cos) PutFileAs(filePath string, source filesystem.File, name string) (string, error) {
 //	fullPath, err := fullPathOfFile(filePath, source, name)
@@ -313,7 +410,11 @@ func (r *Cos) PutStream(ctx context.Context, file string, rs io.Reader) error {
 //}
 
 func (r *Cos) Size(ctx context.Context, file string) (int64, error) {
-	resp, err := r.instance.Object.Head(ctx, file, nil)
+	key, err := objectKey(file)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.instance.Object.Head(ctx, key, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -325,7 +426,137 @@ func (r *Cos) Size(ctx context.Context, file string) (int64, error) {
 	return contentLengthInt, nil
 }
 
+// Url 文件访问地址，路径不合法时返回空字符串
 func (r *Cos) Url(file string) string {
-	objectUrl := r.instance.Object.GetObjectURL(file)
+	key, err := objectKey(file)
+	if err != nil {
+		return ""
+	}
+	objectUrl := r.instance.Object.GetObjectURL(key)
 	return objectUrl.String()
 }
+
+func (r *Cos) PresignGet(ctx context.Context, file string, ttl time.Duration) (string, error) {
+	return r.presign(ctx, http.MethodGet, file, ttl, "")
+}
+
+func (r *Cos) PresignPut(ctx context.Context, file string, ttl time.Duration, contentType string) (string, error) {
+	return r.presign(ctx, http.MethodPut, file, ttl, contentType)
+}
+
+func (r *Cos) presign(ctx context.Context, method, file string, ttl time.Duration, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	var opt *cos.PresignedURLOptions
+	if contentType != "" {
+		header := http.Header{}
+		header.Set("Content-Type", contentType)
+		opt = &cos.PresignedURLOptions{Header: &header}
+	}
+	u, err := r.instance.Object.GetPresignedURL(ctx, method, key, r.config.AccessKeyId, r.config.AccessKeySecret, ttl, opt)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (r *Cos) InitUpload(ctx context.Context, file string, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	return r.initUpload(ctx, key, storage.PutOptions{ContentType: contentType})
+}
+
+func (r *Cos) initUpload(ctx context.Context, key string, opts storage.PutOptions) (string, error) {
+	acl, header := cosOptions(opts)
+	res, _, err := r.instance.Object.InitiateMultipartUpload(ctx, key, &cos.InitiateMultipartUploadOptions{
+		ACLHeaderOptions:       acl,
+		ObjectPutHeaderOptions: header,
+	})
+	if err != nil {
+		return "", err
+	}
+	return res.UploadID, nil
+}
+
+// cosOptions 上传时设置的请求头
+func cosOptions(opts storage.PutOptions) (*cos.ACLHeaderOptions, *cos.ObjectPutHeaderOptions) {
+	header := &cos.ObjectPutHeaderOptions{
+		ContentType:        opts.ContentType,
+		ContentDisposition: opts.ContentDisposition,
+		CacheControl:       opts.CacheControl,
+		XCosStorageClass:   opts.StorageClass,
+	}
+	if len(opts.Metadata) > 0 {
+		meta := make(http.Header, len(opts.Metadata))
+		for k, v := range opts.Metadata {
+			meta.Set("x-cos-meta-"+k, v)
+		}
+		header.XCosMetaXXX = &meta
+	}
+	var acl *cos.ACLHeaderOptions
+	if opts.ACL != "" {
+		acl = &cos.ACLHeaderOptions{XCosACL: opts.ACL}
+	}
+	return acl, header
+}
+
+func (r *Cos) UploadPart(ctx context.Context, file, uploadId string, number int, rs io.Reader, size int64) (storage.UploadPart, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	resp, err := r.instance.Object.UploadPart(ctx, key, uploadId, number, rs, &cos.ObjectUploadPartOptions{ContentLength: size})
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	return storage.UploadPart{Number: number, ETag: resp.Header.Get("ETag"), Size: size}, nil
+}
+
+func (r *Cos) ListParts(ctx context.Context, file, uploadId string) ([]storage.UploadPart, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]storage.UploadPart, 0)
+	opt := &cos.ObjectListPartsOptions{}
+	for {
+		res, _, err := r.instance.Object.ListParts(ctx, key, uploadId, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range res.Parts {
+			parts = append(parts, storage.UploadPart{Number: p.PartNumber, ETag: p.ETag, Size: p.Size})
+		}
+		if !res.IsTruncated {
+			break
+		}
+		opt.PartNumberMarker = res.NextPartNumberMarker
+	}
+	return parts, nil
+}
+
+func (r *Cos) CompleteUpload(ctx context.Context, file, uploadId string, parts []storage.UploadPart) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	opt := &cos.CompleteMultipartUploadOptions{}
+	for _, p := range parts {
+		opt.Parts = append(opt.Parts, cos.Object{PartNumber: p.Number, ETag: p.ETag})
+	}
+	_, _, err = r.instance.Object.CompleteMultipartUpload(ctx, key, uploadId, opt)
+	return err
+}
+
+func (r *Cos) AbortUpload(ctx context.Context, file, uploadId string) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	_, err = r.instance.Object.AbortMultipartUpload(ctx, key, uploadId)
+	return err
+}
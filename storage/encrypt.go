@@ -0,0 +1,494 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+// DefaultEncryptChunkSize 默认每段明文大小
+const DefaultEncryptChunkSize = 64 << 10
+
+// MaxEncryptChunkSize 每段明文大小上限，读取时超过该大小的文件头视为已损坏
+const MaxEncryptChunkSize = 64 << 20
+
+// 文件头: magic(4) version(1) chunkSize(4) noncePrefix(7) keyIdLen(1) keyId wrappedLen(2) wrapped
+// 前16字节作为每段加密的附加数据，数据密钥重新加密时段数据不需要改变
+const encryptMagic = "SENC"
+const encryptVersion = 1
+const encryptNoncePrefixSize = 7
+const encryptFixedHeaderSize = len(encryptMagic) + 1 + 4 + encryptNoncePrefixSize
+
+var ErrNotEncrypted = errors.New("file is not encrypted")
+var ErrDecrypt = errors.New("encrypted data corrupted")
+
+type EncryptOption func(e *Encrypted)
+
+// WithEncryptChunkSize 每段明文大小，只影响新写入的文件，超过 MaxEncryptChunkSize 时使用 MaxEncryptChunkSize
+func WithEncryptChunkSize(size int) EncryptOption {
+	return func(e *Encrypted) {
+		if size > 0 {
+			e.chunkSize = min(size, MaxEncryptChunkSize)
+		}
+	}
+}
+
+var _ storage.FileSystem = (*Encrypted)(nil)
+
+// Encrypted 客户端加密，每个文件使用随机数据密钥以AES-GCM分段加密，数据密钥由 KeyWrapper 加密后保存在文件头
+// Size、MimeType 返回明文的信息，ListObjects、Url 等直接使用底层存储，返回的是密文
+type Encrypted struct {
+	fs        storage.FileSystem
+	keys      KeyWrapper
+	chunkSize int
+}
+
+func NewEncrypted(fs storage.FileSystem, keys KeyWrapper, opts ...EncryptOption) *Encrypted {
+	e := &Encrypted{fs: fs, keys: keys, chunkSize: DefaultEncryptChunkSize}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Unwrap 底层存储
+func (r *Encrypted) Unwrap() storage.FileSystem {
+	return r.fs
+}
+
+func (r *Encrypted) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*storage.ListObjectRes, error) {
+	return r.fs.ListObjects(ctx, opt)
+}
+
+// Copy 密文直接复制，不需要解密
+func (r *Encrypted) Copy(ctx context.Context, originFile, targetFile string) error {
+	return r.fs.Copy(ctx, originFile, targetFile)
+}
+
+func (r *Encrypted) Delete(ctx context.Context, files ...string) error {
+	return r.fs.Delete(ctx, files...)
+}
+
+func (r *Encrypted) DeleteDirectory(ctx context.Context, directory string) error {
+	return r.fs.DeleteDirectory(ctx, directory)
+}
+
+func (r *Encrypted) Directories(ctx context.Context, path string) ([]string, error) {
+	return r.fs.Directories(ctx, path)
+}
+
+func (r *Encrypted) Exists(ctx context.Context, file string) bool {
+	return r.fs.Exists(ctx, file)
+}
+
+func (r *Encrypted) Files(ctx context.Context, path string) ([]string, error) {
+	return r.fs.Files(ctx, path)
+}
+
+func (r *Encrypted) Get(ctx context.Context, file string) ([]byte, error) {
+	rc, err := r.GetStream(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return io.ReadAll(rc)
+}
+
+// GetStream 读取时逐段解密，数据被篡改或截断时读取返回 ErrDecrypt
+func (r *Encrypted) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
+	rc, err := r.fs.GetStream(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := r.decrypter(ctx, rc)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{dr, rc}, nil
+}
+
+func (r *Encrypted) LastModified(ctx context.Context, file string) (time.Time, error) {
+	return r.fs.LastModified(ctx, file)
+}
+
+func (r *Encrypted) MakeDirectory(ctx context.Context, directory string) error {
+	return r.fs.MakeDirectory(ctx, directory)
+}
+
+// MimeType 根据扩展名或解密后的文件头判断
+func (r *Encrypted) MimeType(ctx context.Context, file string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	rc, err := r.GetStream(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	head, err := io.ReadAll(io.LimitReader(rc, 512))
+	if err != nil {
+		return "", err
+	}
+	return contentType(key, head), nil
+}
+
+func (r *Encrypted) Missing(ctx context.Context, file string) bool {
+	return r.fs.Missing(ctx, file)
+}
+
+func (r *Encrypted) Move(ctx context.Context, oldFile, newFile string) error {
+	return r.fs.Move(ctx, oldFile, newFile)
+}
+
+func (r *Encrypted) Path(file string) string {
+	return r.fs.Path(file)
+}
+
+func (r *Encrypted) Put(ctx context.Context, file string, content []byte) error {
+	return r.PutStream(ctx, file, bytes.NewReader(content))
+}
+
+func (r *Encrypted) PutStream(ctx context.Context, file string, rs io.Reader) error {
+	er, err := r.encrypter(ctx, rs)
+	if err != nil {
+		return err
+	}
+	return r.fs.PutStream(ctx, file, er)
+}
+
+// Size 明文大小，根据密文大小和分段大小计算
+func (r *Encrypted) Size(ctx context.Context, file string) (int64, error) {
+	size, err := r.fs.Size(ctx, file)
+	if err != nil {
+		return 0, err
+	}
+	h, err := r.header(ctx, file)
+	if err != nil {
+		return 0, err
+	}
+	body := size - int64(h.size())
+	chunk := int64(h.chunkSize + tagSize)
+	chunks := (body + chunk - 1) / chunk
+	if chunks == 0 {
+		return 0, ErrDecrypt
+	}
+	return body - chunks*tagSize, nil
+}
+
+func (r *Encrypted) Url(file string) string {
+	return r.fs.Url(file)
+}
+
+// KeyId 加密文件数据密钥使用的主密钥ID
+func (r *Encrypted) KeyId(ctx context.Context, file string) (string, error) {
+	h, err := r.header(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	return h.keyId, nil
+}
+
+// Rotate 使用当前主密钥重新加密文件的数据密钥，文件内容不需要重新加密
+// 已使用当前主密钥时不修改文件并返回false
+func (r *Encrypted) Rotate(ctx context.Context, file string) (bool, error) {
+	rc, err := r.fs.GetStream(ctx, file)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	h, err := readEncryptHeader(rc)
+	if err != nil {
+		return false, err
+	}
+	if h.keyId == r.keys.Primary() {
+		return false, nil
+	}
+	dek, err := r.keys.Unwrap(ctx, h.keyId, h.wrapped)
+	if err != nil {
+		return false, err
+	}
+	if h.keyId, h.wrapped, err = r.keys.Wrap(ctx, dek); err != nil {
+		return false, err
+	}
+	//先保存到临时文件，部分存储写入时会截断正在读取的原文件
+	tmp, err := os.CreateTemp("", "storage_rotate_*")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = io.Copy(tmp, rc); err != nil {
+		return false, err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return true, r.fs.PutStream(ctx, file, io.MultiReader(bytes.NewReader(h.marshal()), tmp))
+}
+
+func (r *Encrypted) header(ctx context.Context, file string) (*encryptHeader, error) {
+	rc, err := r.fs.GetStream(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return readEncryptHeader(rc)
+}
+
+func (r *Encrypted) encrypter(ctx context.Context, rs io.Reader) (io.Reader, error) {
+	dek := make([]byte, 32)
+	h := &encryptHeader{chunkSize: r.chunkSize, noncePrefix: make([]byte, encryptNoncePrefixSize)}
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(h.noncePrefix); err != nil {
+		return nil, err
+	}
+	var err error
+	if h.keyId, h.wrapped, err = r.keys.Wrap(ctx, dek); err != nil {
+		return nil, err
+	}
+	if len(h.keyId) > math.MaxUint8 || len(h.wrapped) > math.MaxUint16 {
+		return nil, errors.New("wrapped key too long")
+	}
+	aead, err := newChunkAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	er := &encryptReader{
+		chunkReader: newChunkReader(rs, aead, h),
+		plain:       make([]byte, h.chunkSize),
+	}
+	er.out = h.marshal()
+	return er, nil
+}
+
+func (r *Encrypted) decrypter(ctx context.Context, rs io.Reader) (io.Reader, error) {
+	h, err := readEncryptHeader(rs)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := r.keys.Unwrap(ctx, h.keyId, h.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newChunkAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		chunkReader: newChunkReader(rs, aead, h),
+		sealed:      make([]byte, h.chunkSize+tagSize),
+	}, nil
+}
+
+const tagSize = 16
+
+func newChunkAEAD(dek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type encryptHeader struct {
+	chunkSize   int
+	noncePrefix []byte
+	keyId       string
+	wrapped     []byte
+}
+
+func (h *encryptHeader) size() int {
+	return encryptFixedHeaderSize + 1 + len(h.keyId) + 2 + len(h.wrapped)
+}
+
+func (h *encryptHeader) marshal() []byte {
+	b := make([]byte, 0, h.size())
+	b = append(b, encryptMagic...)
+	b = append(b, encryptVersion)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.chunkSize))
+	b = append(b, h.noncePrefix...)
+	b = append(b, byte(len(h.keyId)))
+	b = append(b, h.keyId...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(h.wrapped)))
+	return append(b, h.wrapped...)
+}
+
+func readEncryptHeader(r io.Reader) (*encryptHeader, error) {
+	fixed := make([]byte, encryptFixedHeaderSize+1)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotEncrypted
+		}
+		return nil, err
+	}
+	if string(fixed[:len(encryptMagic)]) != encryptMagic {
+		return nil, ErrNotEncrypted
+	}
+	if v := fixed[len(encryptMagic)]; v != encryptVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", v)
+	}
+	h := &encryptHeader{
+		chunkSize:   int(binary.BigEndian.Uint32(fixed[len(encryptMagic)+1:])),
+		noncePrefix: fixed[len(encryptMagic)+5 : encryptFixedHeaderSize],
+	}
+	if h.chunkSize <= 0 || h.chunkSize > MaxEncryptChunkSize {
+		return nil, ErrDecrypt
+	}
+	keyId := make([]byte, int(fixed[encryptFixedHeaderSize])+2)
+	if _, err := io.ReadFull(r, keyId); err != nil {
+		return nil, ErrDecrypt
+	}
+	h.keyId = string(keyId[:len(keyId)-2])
+	h.wrapped = make([]byte, binary.BigEndian.Uint16(keyId[len(keyId)-2:]))
+	if _, err := io.ReadFull(r, h.wrapped); err != nil {
+		return nil, ErrDecrypt
+	}
+	return h, nil
+}
+
+// chunkReader 分段加解密，nonce为 noncePrefix(7) 序号(4) 是否最后一段(1)，防止分段被重排或截断
+type chunkReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	header  *encryptHeader
+	aad     []byte //文件头固定部分
+	counter uint32
+	out     []byte //待输出的数据
+	done    bool
+	err     error
+}
+
+func newChunkReader(rs io.Reader, aead cipher.AEAD, h *encryptHeader) chunkReader {
+	return chunkReader{src: bufio.NewReader(rs), aead: aead, header: h, aad: h.marshal()[:encryptFixedHeaderSize]}
+}
+
+func (c *chunkReader) Read(p []byte, next func() error) (int, error) {
+	for len(c.out) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = next()
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// read 读取一段，读到末尾时标记为最后一段
+func (c *chunkReader) read(buf []byte) (int, error) {
+	n, err := io.ReadFull(c.src, buf)
+	switch err {
+	case nil:
+		if _, err = c.src.Peek(1); err == io.EOF {
+			c.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		c.done = true
+	default:
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c *chunkReader) nonce() ([]byte, error) {
+	if c.counter == math.MaxUint32 {
+		return nil, errors.New("encrypted file too large")
+	}
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, c.header.noncePrefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, c.counter)
+	if c.done {
+		nonce = append(nonce, 1)
+	} else {
+		nonce = append(nonce, 0)
+	}
+	c.counter++
+	return nonce, nil
+}
+
+type encryptReader struct {
+	chunkReader
+	plain  []byte
+	sealed []byte
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	return e.chunkReader.Read(p, e.next)
+}
+
+func (e *encryptReader) next() error {
+	n, err := e.read(e.plain)
+	if err != nil {
+		return err
+	}
+	nonce, err := e.nonce()
+	if err != nil {
+		return err
+	}
+	e.sealed = e.aead.Seal(e.sealed[:0], nonce, e.plain[:n], e.aad)
+	e.out = e.sealed
+	return nil
+}
+
+type decryptReader struct {
+	chunkReader
+	sealed []byte
+	plain  []byte
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	return d.chunkReader.Read(p, d.next)
+}
+
+func (d *decryptReader) next() error {
+	n, err := d.read(d.sealed)
+	if err != nil {
+		return err
+	}
+	if n < tagSize {
+		return ErrDecrypt
+	}
+	nonce, err := d.nonce()
+	if err != nil {
+		return err
+	}
+	if d.plain, err = d.aead.Open(d.plain[:0], nonce, d.sealed[:n], d.aad); err != nil {
+		return ErrDecrypt
+	}
+	d.out = d.plain
+	return nil
+}
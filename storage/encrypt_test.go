@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEncrypted(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemFS("http://localhost")
+	old, _ := NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	enc := NewEncrypted(inner, old, WithEncryptChunkSize(16))
+
+	for _, n := range []int{0, 1, 16, 17, 100} {
+		data := bytes.Repeat([]byte("x"), n)
+		if err := enc.Put(ctx, "a.txt", data); err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ := inner.Get(ctx, "a.txt"); bytes.Contains(raw, []byte("xx")) {
+			t.Fatalf("%d: plaintext stored", n)
+		}
+		if b, err := enc.Get(ctx, "a.txt"); err != nil || !bytes.Equal(b, data) {
+			t.Fatalf("%d: Get = %q, %v", n, b, err)
+		}
+		if size, err := enc.Size(ctx, "a.txt"); err != nil || size != int64(n) {
+			t.Fatalf("%d: Size = %d, %v", n, size, err)
+		}
+	}
+	if mime, _ := enc.MimeType(ctx, "a.txt"); mime != "text/plain; charset=utf-8" {
+		t.Fatalf("mime = %s", mime)
+	}
+
+	//篡改、在分段边界截断都无法解密
+	raw, _ := inner.Get(ctx, "a.txt")
+	tampered := bytes.Clone(raw)
+	tampered[len(tampered)-1] ^= 1
+	_ = inner.Put(ctx, "b.txt", tampered)
+	if _, err := enc.Get(ctx, "b.txt"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("tampered: %v", err)
+	}
+	_ = inner.Put(ctx, "b.txt", raw[:len(raw)-(100%16+16)])
+	if _, err := enc.Get(ctx, "b.txt"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("truncated: %v", err)
+	}
+	_ = inner.Put(ctx, "b.txt", []byte("plain"))
+	if _, err := enc.Get(ctx, "b.txt"); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("plain: %v", err)
+	}
+
+	//轮换主密钥后重新加密数据密钥
+	rotated, _ := NewKeyring("k2", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 32)})
+	enc = NewEncrypted(inner, rotated)
+	if ok, err := enc.Rotate(ctx, "a.txt"); !ok || err != nil {
+		t.Fatalf("rotate: %v %v", ok, err)
+	}
+	if ok, err := enc.Rotate(ctx, "a.txt"); ok || err != nil {
+		t.Fatalf("rotate again: %v %v", ok, err)
+	}
+	current, _ := NewKeyring("k2", map[string][]byte{"k2": bytes.Repeat([]byte{2}, 32)})
+	rc, err := NewEncrypted(inner, current).GetStream(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if b, err := io.ReadAll(rc); err != nil || !bytes.Equal(b, bytes.Repeat([]byte("x"), 100)) {
+		t.Fatalf("after rotate: %q, %v", b, err)
+	}
+	if _, err = NewEncrypted(inner, old).Get(ctx, "a.txt"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("old keyring: %v", err)
+	}
+}
+
+func TestEncryptChunkSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	keys, _ := NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	//超过上限时使用上限，写入的文件仍可读取
+	enc := NewEncrypted(NewMemFS("http://localhost"), keys, WithEncryptChunkSize(MaxEncryptChunkSize+1))
+	if enc.chunkSize != MaxEncryptChunkSize {
+		t.Fatalf("chunk size = %d", enc.chunkSize)
+	}
+	if err := enc.Put(ctx, "a.txt", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := enc.Get(ctx, "a.txt"); err != nil || string(b) != "abc" {
+		t.Fatalf("Get = %q, %v", b, err)
+	}
+}
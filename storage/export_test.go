@@ -0,0 +1,5 @@
+package storage
+
+// 签名函数供 storage_test 包中的测试使用
+var AliyunSignature = aliyunSignature
+var TC3Signature = tc3Signature
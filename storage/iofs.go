@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+var _ fs.ReadDirFS = (*IOFS)(nil)
+var _ fs.ReadFileFS = (*IOFS)(nil)
+var _ fs.StatFS = (*IOFS)(nil)
+
+// IOFS 把 storage.FileSystem 转换为 io/fs.FS，可用于 template.ParseFS、http.FS 等
+// 路径规则与 io/fs 一致，根目录为"."，对象存储中没有文件的目录不存在
+type IOFS struct {
+	ctx context.Context
+	fs  storage.FileSystem
+}
+
+// NewIOFS ctx用于所有存储请求
+func NewIOFS(ctx context.Context, fs storage.FileSystem) *IOFS {
+	return &IOFS{ctx: ctx, fs: fs}
+}
+
+// NewHTTPFileSystem 转换为 http.FileSystem，可用于 gin 的 StaticFS
+func NewHTTPFileSystem(ctx context.Context, fs storage.FileSystem) http.FileSystem {
+	return http.FS(NewIOFS(ctx, fs))
+}
+
+func (r *IOFS) Open(name string) (fs.File, error) {
+	info, err := r.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &ioDir{fsys: r, name: name, info: info}, nil
+	}
+	return &ioFile{RangeReader: newRangeReader(r.ctx, r.fs, name, info.Size()), info: info}, nil
+}
+
+func (r *IOFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !validIOPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := r.readDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 && name != "." {
+		if _, err = r.stat("readdir", name); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func (r *IOFS) ReadFile(name string) ([]byte, error) {
+	if !validIOPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	return r.fs.Get(r.ctx, name)
+}
+
+func (r *IOFS) Stat(name string) (fs.FileInfo, error) {
+	return r.stat("stat", name)
+}
+
+// stat 在父目录中按名称前缀查找，部分存储的 LastModified、Size 对目录也返回成功，无法区分文件和目录
+func (r *IOFS) stat(op, name string) (fs.FileInfo, error) {
+	if !validIOPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &fileInfo{name: ".", dir: true}, nil
+	}
+	dir, base := path.Split(name)
+	opt := &storage.ListObjectOpts{Directory: dir, Prefix: base, MaxKeys: storage.MaxFileNum}
+	for {
+		res, err := r.fs.ListObjects(r.ctx, opt)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		for _, item := range res.List {
+			if item.Name == base {
+				return &fileInfo{name: base, size: item.Size, modTime: item.LastModified, dir: item.IsDir}, nil
+			}
+		}
+		if !res.HasMore || res.NextToken == "" {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		opt.NextToken = res.NextToken
+	}
+}
+
+// readDir 按名称排序的目录项
+func (r *IOFS) readDir(name string) ([]fs.DirEntry, error) {
+	dir := name
+	if dir == "." {
+		dir = ""
+	}
+	list, err := listAll(r.ctx, r.fs, dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, 0, len(list))
+	for _, item := range list {
+		entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{
+			name:    item.Name,
+			size:    item.Size,
+			modTime: item.LastModified,
+			dir:     item.IsDir,
+		}))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// validIOPath 存储会把\转换为/，io/fs 中\不是路径分隔符，不允许使用
+func validIOPath(name string) bool {
+	return fs.ValidPath(name) && !strings.Contains(name, `\`)
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (f *fileInfo) Name() string {
+	return f.name
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.size
+}
+
+func (f *fileInfo) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (f *fileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *fileInfo) IsDir() bool {
+	return f.dir
+}
+
+func (f *fileInfo) Sys() any {
+	return nil
+}
+
+// ioFile 只读文件，支持Seek
+type ioFile struct {
+	*RangeReader
+	info fs.FileInfo
+}
+
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// ioDir 目录，第一次 ReadDir 时列出全部目录项
+type ioDir struct {
+	fsys    *IOFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *ioDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *ioDir) Close() error {
+	return nil
+}
+
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
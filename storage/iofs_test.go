@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+func TestIOFS(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewLocal(LocalConfig{Root: t.TempDir()})
+	for name, fs := range map[string]storage.FileSystem{"local": local, "mem": NewMemFS("")} {
+		_ = fs.Put(ctx, "index.html", []byte("<html>index</html>"))
+		_ = fs.Put(ctx, "css/app.css", []byte("body{}"))
+		_ = fs.Put(ctx, "css/a/b.txt", []byte("0123456789"))
+		if err := fstest.TestFS(NewIOFS(ctx, fs), "index.html", "css/app.css", "css/a/b.txt"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestHTTPFileSystem(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemFS("")
+	_ = mem.Put(ctx, "a/b.txt", []byte("0123456789"))
+	srv := httptest.NewServer(http.FileServer(NewHTTPFileSystem(ctx, mem)))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/a/b.txt", nil)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusPartialContent || string(b) != "345" {
+		t.Fatalf("range = %d %q", resp.StatusCode, b)
+	}
+	resp, err = http.Get(srv.URL + "/missing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// KeyWrapper 信封加密的主密钥，用于加密每个文件随机生成的数据密钥，可使用KMS实现
+type KeyWrapper interface {
+	// Wrap 使用当前主密钥加密数据密钥，返回主密钥ID
+	Wrap(ctx context.Context, dek []byte) (keyId string, wrapped []byte, err error)
+	// Unwrap 使用指定主密钥解密数据密钥，密钥不存在时返回 ErrKeyNotFound
+	Unwrap(ctx context.Context, keyId string, wrapped []byte) ([]byte, error)
+	// Primary 当前主密钥ID
+	Primary() string
+}
+
+var _ KeyWrapper = (*Keyring)(nil)
+
+// Keyring 本地主密钥，轮换时添加新密钥并设为主密钥，旧密钥保留用于解密
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring keys为 主密钥ID=>AES密钥，密钥长度为16、24或32字节
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	r := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		if r.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := r.keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, primary)
+	}
+	return r, nil
+}
+
+func (r *Keyring) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	aead := r.keys[r.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(dek)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return r.primary, aead.Seal(nonce, nonce, dek, []byte(r.primary)), nil
+}
+
+func (r *Keyring) Unwrap(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	aead, ok := r.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyId)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	dek, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyId))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dek, nil
+}
+
+func (r *Keyring) Primary() string {
+	return r.primary
+}
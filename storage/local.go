@@ -3,11 +3,18 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"io"
 	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,80 +24,100 @@ import (
 type LocalConfig struct {
 	Endpoint string `help:"访问地址" default:"http://localhost" json:"endpoint"`
 	Root     string `help:"根目录" default:"$ROOT" json:"root"`
+	Secret   string `help:"预签名地址密钥" default:"" json:"secret"`
+	// UploadDir 分片上传临时目录，默认为系统临时目录下的 storage_uploads
+	UploadDir string `help:"分片上传临时目录" default:"" json:"upload_dir"`
+	// MetaDir PutWithOptions 设置的元信息保存目录，为空时不保存
+	MetaDir string `help:"文件元信息保存目录" default:"" json:"meta_dir"`
 }
 
 var _ storage.FileSystem = (*Local)(nil)
+var _ storage.Signer = (*Local)(nil)
+var _ storage.RangeGetter = (*Local)(nil)
+
+// localTempDir 根目录下写入文件使用的临时目录，与存储的文件在同一文件系统，保证重命名不需要复制
+const localTempDir = ".storage_tmp"
+
+var ErrSecretNotSet = errors.New("local storage secret not set")
+var ErrSignatureInvalid = errors.New("invalid signature")
+var ErrSignatureExpired = errors.New("signature expired")
 
 type Local struct {
-	root     string
-	endpoint string
+	root      string
+	endpoint  string
+	secret    []byte
+	uploadDir string
+	metaDir   string
 }
 
 func NewLocal(config LocalConfig) (*Local, error) {
+	if config.UploadDir == "" {
+		config.UploadDir = filepath.Join(os.TempDir(), "storage_uploads")
+	}
 	return &Local{
-		root:     config.Root,
-		endpoint: strings.TrimSuffix(config.Endpoint, "/"),
+		root:      config.Root,
+		endpoint:  strings.TrimSuffix(config.Endpoint, "/"),
+		secret:    []byte(config.Secret),
+		uploadDir: config.UploadDir,
+		metaDir:   config.MetaDir,
 	}, nil
 }
 
+// ListObjects 列出目录下的文件及子目录，按名称排序分页
 func (r *Local) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*storage.ListObjectRes, error) {
-	res := &storage.ListObjectRes{
-		List: make([]storage.ListObject, 0),
-	}
-	start := false
-	if opt.NextToken == "" {
-		start = true
-	}
-	pre := r.fullPath(opt.Directory)
-	num := getPageSize(opt.MaxKeys)
-	err := filepath.Walk(pre, func(path string, info fs.FileInfo, err error) error {
-		fileKey := strings.TrimPrefix(path, pre)
-		if fileKey == "" {
-			return nil
-		}
-		if !start {
-			if opt.NextToken == fileKey {
-				start = true
-			}
-			return nil
-		}
-		num -= 1
-		if num == -1 {
-			res.HasMore = true
-			return io.EOF
+	dir, err := r.fullPath(opt.Directory)
+	if err != nil {
+		return nil, err
+	}
+	key, _ := normalizeKey(opt.Directory)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	list := make([]storage.ListObject, 0, len(entries))
+	for _, entry := range entries {
+		if r.isTempDir(filepath.Join(dir, entry.Name())) {
+			continue
 		}
-		if info.IsDir() {
-			res.List = append(res.List, storage.ListObject{
-				Name:  filepath.Base(path),
+		if entry.IsDir() {
+			list = append(list, storage.ListObject{
+				Name:  entry.Name(),
 				IsDir: true,
 			})
-		} else {
-			res.List = append(res.List, storage.ListObject{
-				Name:         filepath.Base(path),
-				IsDir:        false,
-				Size:         info.Size(),
-				Path:         fileKey,
-				Url:          r.Url(fileKey),
-				LastModified: info.ModTime(),
-			})
+			continue
 		}
-		res.NextToken = fileKey
-		return err
-	})
-	if err != nil && err != io.EOF {
-		return nil, err
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		fileKey := path.Join(key, entry.Name())
+		list = append(list, storage.ListObject{
+			Name:         entry.Name(),
+			IsDir:        false,
+			Size:         info.Size(),
+			Path:         fileKey,
+			Url:          r.Url(fileKey),
+			LastModified: info.ModTime(),
+		})
 	}
-	return res, nil
+	return pageObjects(list, opt), nil
 }
 
 func (r *Local) AllDirectories(path string) ([]string, error) {
 	var directories []string
-	err := filepath.Walk(r.fullPath(path), func(fullPath string, info os.FileInfo, err error) error {
+	root, err := r.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(root, func(fullPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if info.IsDir() && r.isTempDir(fullPath) {
+			return filepath.SkipDir
+		}
 		if info.IsDir() {
-			realPath := strings.ReplaceAll(fullPath, r.fullPath(path), "")
+			realPath := strings.ReplaceAll(fullPath, root, "")
 			realPath = strings.TrimPrefix(realPath, string(filepath.Separator))
 			if realPath != "" {
 				directories = append(directories, realPath+string(filepath.Separator))
@@ -105,12 +132,19 @@ func (r *Local) AllDirectories(path string) ([]string, error) {
 
 func (r *Local) AllFiles(path string) ([]string, error) {
 	var files []string
-	err := filepath.Walk(r.fullPath(path), func(fullPath string, info os.FileInfo, err error) error {
+	root, err := r.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(root, func(fullPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if info.IsDir() && r.isTempDir(fullPath) {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() {
-			files = append(files, strings.ReplaceAll(fullPath, r.fullPath(path)+string(filepath.Separator), ""))
+			files = append(files, strings.ReplaceAll(fullPath, root+string(filepath.Separator), ""))
 		}
 
 		return nil
@@ -127,23 +161,43 @@ func (r *Local) Copy(ctx context.Context, originFile, targetFile string) error {
 	defer func() {
 		_ = rs.Close()
 	}()
-	return r.PutStream(ctx, targetFile, rs)
+	if err = r.PutStream(ctx, targetFile, rs); err != nil {
+		return err
+	}
+	return r.copyMeta(originFile, targetFile)
 }
 
 func (r *Local) Delete(ctx context.Context, files ...string) error {
-	for _, file := range files {
-		fileInfo, err := os.Stat(r.fullPath(file))
+	paths := make([]string, len(files))
+	for i, file := range files {
+		path, err := r.fullPath(file)
 		if err != nil {
 			return err
 		}
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			//和云存储一致，删除不存在的文件不报错
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		paths[i] = path
 
 		if fileInfo.IsDir() {
 			return errors.New("can't delete directory, please use DeleteDirectory")
 		}
 	}
 
-	for _, file := range files {
-		if err := os.Remove(r.fullPath(file)); err != nil {
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		key, _ := objectKey(files[i])
+		if err := r.removeMeta(key); err != nil {
 			return err
 		}
 	}
@@ -152,15 +206,33 @@ func (r *Local) Delete(ctx context.Context, files ...string) error {
 }
 
 func (r *Local) DeleteDirectory(ctx context.Context, directory string) error {
-	return os.RemoveAll(r.fullPath(directory))
+	key, err := objectKey(directory)
+	if err != nil {
+		return err
+	}
+	path, err := r.fullPath(key)
+	if err != nil {
+		return err
+	}
+	if err = os.RemoveAll(path); err != nil {
+		return err
+	}
+	return r.removeMeta(key)
 }
 
 func (r *Local) Directories(ctx context.Context, path string) ([]string, error) {
 	var directories []string
-	fileInfo, _ := os.ReadDir(r.fullPath(path))
+	dir, err := r.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
 	for _, f := range fileInfo {
-		if f.IsDir() {
-			directories = append(directories, f.Name()+string(filepath.Separator))
+		if f.IsDir() && !r.isTempDir(filepath.Join(dir, f.Name())) {
+			directories = append(directories, f.Name()+"/")
 		}
 	}
 
@@ -168,8 +240,11 @@ func (r *Local) Directories(ctx context.Context, path string) ([]string, error)
 }
 
 func (r *Local) Exists(ctx context.Context, file string) bool {
-	_, err := os.Stat(r.fullPath(file))
+	path, err := r.fullPath(file)
 	if err != nil {
+		return false
+	}
+	if _, err = os.Stat(path); err != nil {
 		return os.IsExist(err)
 	}
 	return true
@@ -177,10 +252,14 @@ func (r *Local) Exists(ctx context.Context, file string) bool {
 
 func (r *Local) Files(ctx context.Context, path string) ([]string, error) {
 	var files []string
-	fileInfo, err := os.ReadDir(r.fullPath(path))
+	dir, err := r.fullPath(path)
 	if err != nil {
 		return nil, err
 	}
+	fileInfo, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
 	for _, f := range fileInfo {
 		if !f.IsDir() {
 			files = append(files, f.Name())
@@ -202,19 +281,61 @@ func (r *Local) Get(ctx context.Context, file string) ([]byte, error) {
 }
 
 func (r *Local) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
-	return os.Open(r.fullPath(file))
+	path, err := r.fullPath(file)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (r *Local) GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, ErrInvalidRange
+	}
+	path, err := r.fullPath(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		err = checkRange(offset, info.Size())
+	}
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return limitReadCloser(f, length), nil
 }
 
 func (r *Local) LastModified(ctx context.Context, file string) (time.Time, error) {
-	return LastModified(r.fullPath(file))
+	path, err := r.fullPath(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return LastModified(path)
 }
 
 func (r *Local) MakeDirectory(ctx context.Context, directory string) error {
-	return os.MkdirAll(filepath.Dir(r.fullPath(directory)+string(filepath.Separator)), os.ModePerm)
+	path, err := r.fullPath(directory)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, os.ModePerm)
 }
 
 func (r *Local) MimeType(ctx context.Context, file string) (string, error) {
-	return MimeType(r.fullPath(file))
+	path, err := r.fullPath(file)
+	if err != nil {
+		return "", err
+	}
+	return MimeType(path)
 }
 
 func (r *Local) Missing(ctx context.Context, file string) bool {
@@ -222,18 +343,34 @@ func (r *Local) Missing(ctx context.Context, file string) bool {
 }
 
 func (r *Local) Move(ctx context.Context, oldFile, newFile string) error {
-	newFile = r.fullPath(newFile)
-	if err := os.MkdirAll(filepath.Dir(newFile), os.ModePerm); err != nil {
+	oldPath, err := r.fullPath(oldFile)
+	if err != nil {
 		return err
 	}
-	if err := os.Rename(r.fullPath(oldFile), newFile); err != nil {
+	newPath, err := r.fullPath(newFile)
+	if err != nil {
 		return err
 	}
-	return nil
+	if err = os.MkdirAll(filepath.Dir(newPath), os.ModePerm); err != nil {
+		return err
+	}
+	if err = os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err = r.copyMeta(oldFile, newFile); err != nil {
+		return err
+	}
+	key, _ := objectKey(oldFile)
+	return r.removeMeta(key)
 }
 
+// Path 本地文件路径，路径超出根目录时返回空字符串
 func (r *Local) Path(file string) string {
-	return r.fullPath(file)
+	path, err := r.fullPath(file)
+	if err != nil {
+		return ""
+	}
+	return path
 }
 
 func (r *Local) Put(ctx context.Context, file string, content []byte) error {
@@ -241,35 +378,107 @@ func (r *Local) Put(ctx context.Context, file string, content []byte) error {
 }
 
 func (r *Local) PutStream(ctx context.Context, file string, rs io.Reader) error {
-	file = r.fullPath(file)
-	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
-		return err
+	return r.PutWithOptions(ctx, file, rs, storage.PutOptions{})
+}
+
+func (r *Local) Size(ctx context.Context, file string) (int64, error) {
+	path, err := r.fullPath(file)
+	if err != nil {
+		return 0, err
 	}
-	f, err := os.Create(file)
+	return Size(path)
+}
+
+// Url 文件访问地址，路径不合法时返回空字符串
+func (r *Local) Url(file string) string {
+	key, err := normalizeKey(file)
 	if err != nil {
-		return err
+		return ""
 	}
-	defer func() {
-		_ = f.Close()
-	}()
-	if _, err = io.Copy(f, rs); err != nil {
-		return err
+	return r.endpoint + "/" + key
+}
+
+// fullPath 本地文件路径，路径(包括解析符号链接后)超出根目录时返回 ErrPathEscapesRoot，临时目录返回 ErrInvalidKey
+func (r *Local) fullPath(path string) (string, error) {
+	key, err := normalizeKey(path)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	if key == localTempDir || strings.HasPrefix(key, localTempDir+"/") {
+		return "", &fs.PathError{Op: "path", Path: path, Err: ErrInvalidKey}
+	}
+	return confine(r.root, key)
 }
 
-func (r *Local) Size(ctx context.Context, file string) (int64, error) {
-	return Size(r.fullPath(file))
+// tempDir 写入文件使用的临时目录
+func (r *Local) tempDir() string {
+	return filepath.Join(r.root, localTempDir)
 }
 
-func (r *Local) Url(file string) string {
-	return r.endpoint + "/" + strings.TrimPrefix(filepath.ToSlash(file), "/")
+func (r *Local) isTempDir(path string) bool {
+	return filepath.Clean(path) == r.tempDir()
+}
+
+// PresignGet 下载地址，使用 VerifyRequest 校验
+func (r *Local) PresignGet(ctx context.Context, file string, ttl time.Duration) (string, error) {
+	return r.presign(http.MethodGet, file, ttl, "")
+}
+
+// PresignPut 上传地址，使用 VerifyRequest 校验
+func (r *Local) PresignPut(ctx context.Context, file string, ttl time.Duration, contentType string) (string, error) {
+	return r.presign(http.MethodPut, file, ttl, contentType)
+}
+
+func (r *Local) presign(method, file string, ttl time.Duration, contentType string) (string, error) {
+	if len(r.secret) == 0 {
+		return "", ErrSecretNotSet
+	}
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", r.sign(method, key, expires, contentType))
+	return r.endpoint + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// VerifyRequest 校验预签名地址的请求，file为请求的文件路径，PUT请求的Content-Type需与签名时一致
+func (r *Local) VerifyRequest(req *http.Request, file string) error {
+	if len(r.secret) == 0 {
+		return ErrSecretNotSet
+	}
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	query := req.URL.Query()
+	expires := query.Get("expires")
+	contentType := ""
+	if req.Method == http.MethodPut {
+		contentType = req.Header.Get("Content-Type")
+	}
+	signature := r.sign(req.Method, key, expires, contentType)
+	if !hmac.Equal([]byte(signature), []byte(query.Get("signature"))) {
+		return ErrSignatureInvalid
+	}
+	t, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > t {
+		return ErrSignatureExpired
+	}
+	return nil
 }
 
-func (r *Local) fullPath(path string) string {
-	realPath := filepath.Clean(path)
-	if realPath == "." {
-		return r.root
+// sign HMAC-SHA256签名，HEAD请求使用GET的签名
+func (r *Local) sign(method, key, expires, contentType string) string {
+	if method == http.MethodHead {
+		method = http.MethodGet
 	}
-	return filepath.Join(r.root, realPath)
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires + "\n" + contentType))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+var _ storage.Metadata = (*Local)(nil)
+
+// uploadOptionsFile 分片上传目录中记录上传选项的文件
+const uploadOptionsFile = "options"
+
+// PutWithOptions 元信息以json保存在 MetaDir 下与文件相同的路径，未设置 MetaDir 时只保存文件内容
+func (r *Local) PutWithOptions(ctx context.Context, file string, rs io.Reader, opts storage.PutOptions) error {
+	path, err := r.fullPath(file)
+	if err != nil {
+		return err
+	}
+	if err = r.writeFile(ctx, path, rs); err != nil {
+		return err
+	}
+	return r.writeMeta(file, opts)
+}
+
+// writeFile 先写入临时目录再重命名，写入失败或ctx取消时保留原文件，写入过程中的文件不会出现在列表中
+func (r *Local) writeFile(ctx context.Context, path string, rs io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.tempDir(), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(r.tempDir(), "put_*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if _, err = io.Copy(f, &ctxReader{ctx: ctx, r: rs}); err != nil {
+		return err
+	}
+	//CreateTemp创建的文件权限为0600
+	if err = f.Chmod(0644); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// ctxReader 每次读取前检查ctx是否已取消
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Stat ETag由修改时间和文件大小生成，未保存ContentType时按后缀或内容判断
+func (r *Local) Stat(ctx context.Context, file string) (*storage.ObjectInfo, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	path, err := r.fullPath(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+	opts, err := r.readMeta(key)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ContentType == "" {
+		if opts.ContentType, err = r.MimeType(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return &storage.ObjectInfo{
+		Key:                key,
+		Size:               info.Size(),
+		ContentType:        opts.ContentType,
+		ContentDisposition: opts.ContentDisposition,
+		CacheControl:       opts.CacheControl,
+		ETag:               fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+		LastModified:       info.ModTime(),
+		StorageClass:       opts.StorageClass,
+		Metadata:           lowerKeys(opts.Metadata),
+	}, nil
+}
+
+func (r *Local) putObject(ctx context.Context, key string, content []byte, opts storage.PutOptions) error {
+	return r.PutWithOptions(ctx, key, bytes.NewReader(content), opts)
+}
+
+// metaPath 元信息文件路径，未设置 MetaDir 时返回空字符串
+func (r *Local) metaPath(key string) string {
+	if r.metaDir == "" {
+		return ""
+	}
+	return filepath.Join(r.metaDir, filepath.FromSlash(key)) + ".meta"
+}
+
+func (r *Local) readMeta(key string) (storage.PutOptions, error) {
+	var opts storage.PutOptions
+	path := r.metaPath(key)
+	if path == "" {
+		return opts, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return opts, nil
+		}
+		return opts, err
+	}
+	return opts, json.Unmarshal(b, &opts)
+}
+
+// writeMeta 选项为空时删除元信息
+func (r *Local) writeMeta(file string, opts storage.PutOptions) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	path := r.metaPath(key)
+	if path == "" {
+		return nil
+	}
+	if opts.ContentType == "" && opts.ContentDisposition == "" && opts.CacheControl == "" &&
+		len(opts.Metadata) == 0 && opts.StorageClass == "" && opts.ACL == "" {
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+func (r *Local) copyMeta(originFile, targetFile string) error {
+	key, err := objectKey(originFile)
+	if err != nil {
+		return err
+	}
+	opts, err := r.readMeta(key)
+	if err != nil {
+		return err
+	}
+	return r.writeMeta(targetFile, opts)
+}
+
+// removeMeta 删除文件或目录的元信息
+func (r *Local) removeMeta(key string) error {
+	path := r.metaPath(key)
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(r.metaDir, filepath.FromSlash(key)))
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/opdss/common/contracts/storage"
+)
+
+var _ storage.MultipartUploader = (*Local)(nil)
+
+var ErrUploadNotFound = errors.New("multipart upload not found")
+var ErrUploadNoParts = errors.New("multipart upload has no parts")
+
+// uploadKeyFile 分片上传目录中记录文件路径的文件
+const uploadKeyFile = "key"
+
+// InitUpload 分片保存在上传临时目录，CompleteUpload 时按序号依次追加到目标文件
+func (r *Local) InitUpload(ctx context.Context, file string, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	return r.initUpload(ctx, key, storage.PutOptions{ContentType: contentType})
+}
+
+// initUpload 上传选项保存在上传目录，CompleteUpload 时写入元信息
+func (r *Local) initUpload(ctx context.Context, key string, opts storage.PutOptions) (string, error) {
+	if _, err := r.fullPath(key); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+	uploadId := uuid.New().String()
+	dir := filepath.Join(r.uploadDir, uploadId)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(filepath.Join(dir, uploadKeyFile), []byte(key), 0644); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(filepath.Join(dir, uploadOptionsFile), b, 0644); err != nil {
+		return "", err
+	}
+	return uploadId, nil
+}
+
+// UploadPart 分片文件名为 序号.ETag，重复上传同一序号时覆盖
+func (r *Local) UploadPart(ctx context.Context, file, uploadId string, number int, rs io.Reader, size int64) (storage.UploadPart, error) {
+	dir, err := r.upload(file, uploadId)
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	if number < 1 {
+		return storage.UploadPart{}, fmt.Errorf("invalid part number %d", number)
+	}
+	f, err := os.CreateTemp(dir, ".part_*")
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(f, h), rs)
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	if size >= 0 && n != size {
+		return storage.UploadPart{}, fmt.Errorf("part %d size %d, expected %d", number, n, size)
+	}
+	if err = f.Close(); err != nil {
+		return storage.UploadPart{}, err
+	}
+	part := storage.UploadPart{Number: number, ETag: hex.EncodeToString(h.Sum(nil)), Size: n}
+	if old, _ := filepath.Glob(filepath.Join(dir, strconv.Itoa(number)+".*")); len(old) > 0 {
+		for _, name := range old {
+			_ = os.Remove(name)
+		}
+	}
+	if err = os.Rename(f.Name(), filepath.Join(dir, strconv.Itoa(number)+"."+part.ETag)); err != nil {
+		return storage.UploadPart{}, err
+	}
+	return part, nil
+}
+
+func (r *Local) ListParts(ctx context.Context, file, uploadId string) ([]storage.UploadPart, error) {
+	dir, err := r.upload(file, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]storage.UploadPart, 0, len(entries))
+	for _, entry := range entries {
+		number, etag, ok := strings.Cut(entry.Name(), ".")
+		if !ok || number == "" {
+			continue
+		}
+		num, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, storage.UploadPart{Number: num, ETag: etag, Size: info.Size()})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+	return parts, nil
+}
+
+// CompleteUpload 分片依次追加到上传目录中的临时文件，完成后替换目标文件，parts为空时返回 ErrUploadNoParts
+func (r *Local) CompleteUpload(ctx context.Context, file, uploadId string, parts []storage.UploadPart) error {
+	if len(parts) == 0 {
+		return ErrUploadNoParts
+	}
+	dir, err := r.upload(file, uploadId)
+	if err != nil {
+		return err
+	}
+	path, err := r.fullPath(file)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".complete_*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	for _, part := range parts {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = appendPart(f, dir, part); err != nil {
+			return err
+		}
+	}
+	//CreateTemp创建的文件权限为0600
+	if err = f.Chmod(0644); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = r.moveFile(ctx, f.Name(), path); err != nil {
+		return err
+	}
+	var opts storage.PutOptions
+	if b, err := os.ReadFile(filepath.Join(dir, uploadOptionsFile)); err == nil {
+		_ = json.Unmarshal(b, &opts)
+	}
+	if err = r.writeMeta(file, opts); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (r *Local) AbortUpload(ctx context.Context, file, uploadId string) error {
+	dir, err := r.upload(file, uploadId)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// upload 分片上传目录，uploadId不存在或与文件路径不一致时返回 ErrUploadNotFound
+func (r *Local) upload(file, uploadId string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	if id, err := uuid.Parse(uploadId); err != nil || id.String() != uploadId {
+		return "", ErrUploadNotFound
+	}
+	dir := filepath.Join(r.uploadDir, uploadId)
+	b, err := os.ReadFile(filepath.Join(dir, uploadKeyFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrUploadNotFound
+		}
+		return "", err
+	}
+	if string(b) != key {
+		return "", ErrUploadNotFound
+	}
+	return dir, nil
+}
+
+// moveFile 重命名文件，上传目录与根目录不在同一文件系统时复制到目标路径
+func (r *Local) moveFile(ctx context.Context, src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return r.writeFile(ctx, dst, f)
+}
+
+// appendPart 追加分片，未指定ETag时使用该序号最后上传的分片
+func appendPart(w io.Writer, dir string, part storage.UploadPart) error {
+	name := filepath.Join(dir, strconv.Itoa(part.Number)+"."+part.ETag)
+	if part.ETag == "" {
+		if names, _ := filepath.Glob(name + "*"); len(names) > 0 {
+			name = names[0]
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("part %d not found: %w", part.Number, err)
+		}
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, err = io.Copy(w, f)
+	return err
+}
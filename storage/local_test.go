@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	cases := map[string]string{
+		"a/b.txt":       "a/b.txt",
+		"/a//b.txt":     "a/b.txt",
+		"./a/./b.txt":   "a/b.txt",
+		"a\\b.txt":      "a/b.txt",
+		"a/../b.txt":    "b.txt",
+		"a/b/":          "a/b",
+		"":              "",
+		"/":             "",
+		"../etc/passwd": "",
+		"a/../../b":     "",
+	}
+	for key, want := range cases {
+		got, err := normalizeKey(key)
+		if want == "" && key != "" && key != "/" {
+			if !errors.Is(err, ErrPathEscapesRoot) {
+				t.Errorf("normalizeKey(%q) err = %v, want ErrPathEscapesRoot", key, err)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("normalizeKey(%q) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	if _, err := normalizeKey("a\x00b"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("control char err = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestLocalConfine(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	if err := os.MkdirAll(outside, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := NewLocal(LocalConfig{Root: root, Endpoint: "http://localhost/"})
+
+	if err := l.Put(ctx, "/a/b.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := l.Get(ctx, "a\\b.txt"); err != nil || string(b) != "hello" {
+		t.Fatalf("get = %q, %v", b, err)
+	}
+	if u := l.Url("./a//b.txt"); u != "http://localhost/a/b.txt" {
+		t.Fatalf("url = %s", u)
+	}
+
+	if _, err := l.Get(ctx, "../outside/secret.txt"); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("traversal err = %v", err)
+	}
+	if err := l.Put(ctx, "../../x.txt", []byte("x")); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("traversal put err = %v", err)
+	}
+	if l.Exists(ctx, "../outside/secret.txt") || l.Path("../outside") != "" {
+		t.Fatal("traversal path should not exist")
+	}
+
+	//指向根目录外的符号链接
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skip(err)
+	}
+	if _, err := l.Get(ctx, "link/secret.txt"); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("symlink get err = %v", err)
+	}
+	if err := l.Put(ctx, "link/new/x.txt", []byte("x")); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("symlink put err = %v", err)
+	}
+	if _, err := l.Files(ctx, "link"); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("symlink files err = %v", err)
+	}
+	if err := l.Move(ctx, "a/b.txt", "link/b.txt"); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("symlink move err = %v", err)
+	}
+	//指向不存在路径的符号链接
+	if err := os.Symlink(filepath.Join(outside, "missing.txt"), filepath.Join(root, "dangling.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put(ctx, "dangling.txt", []byte("x")); !errors.Is(err, ErrPathEscapesRoot) {
+		t.Fatalf("dangling symlink put err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "missing.txt")); !os.IsNotExist(err) {
+		t.Fatal("file created outside root")
+	}
+	//根目录内的符号链接可以正常访问
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := l.Get(ctx, "alias/b.txt"); err != nil || string(b) != "hello" {
+		t.Fatalf("inner symlink get = %q, %v", b, err)
+	}
+	if err := l.DeleteDirectory(ctx, "/"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("delete root err = %v", err)
+	}
+}
+
+func TestLocalPutAtomic(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, _ := NewLocal(LocalConfig{Root: root})
+	if err := l.Put(ctx, "a/b.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	//写入失败或ctx已取消时保留原文件，不留下临时文件
+	failed := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("broken")))
+	if err := l.PutStream(ctx, "a/b.txt", failed); err == nil {
+		t.Fatal("expected read error")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.PutStream(canceled, "a/b.txt", strings.NewReader("world")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled put err = %v", err)
+	}
+	if b, err := l.Get(ctx, "a/b.txt"); err != nil || string(b) != "hello" {
+		t.Fatalf("get = %q, %v", b, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "a"))
+	if len(entries) != 1 {
+		t.Fatalf("unexpected files %v", entries)
+	}
+	if info, err := os.Stat(filepath.Join(root, "a", "b.txt")); err != nil || info.Mode().Perm() != 0644 {
+		t.Fatalf("stat = %v, %v", info, err)
+	}
+
+	//写入过程中的临时文件不出现在列表中
+	pr, pw := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- l.PutStream(ctx, "c.txt", pr)
+	}()
+	if _, err := pw.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := l.ListObjects(ctx, &storage.ListObjectOpts{})
+	if err != nil || len(res.List) != 1 || res.List[0].Name != "a" {
+		t.Fatalf("ListObjects = %+v, %v", res, err)
+	}
+	if files, err := l.AllFiles(""); err != nil || len(files) != 1 {
+		t.Fatalf("AllFiles = %v, %v", files, err)
+	}
+	if dirs, err := l.Directories(ctx, ""); err != nil || len(dirs) != 1 {
+		t.Fatalf("Directories = %v, %v", dirs, err)
+	}
+	_ = pw.Close()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Put(ctx, localTempDir+"/x.txt", []byte("x")); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("temp dir put err = %v", err)
+	}
+}
+
+func TestLocalCompleteUpload(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, _ := NewLocal(LocalConfig{Root: root, UploadDir: t.TempDir()})
+	uploadId, err := l.InitUpload(ctx, "a.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.CompleteUpload(ctx, "a.txt", uploadId, nil); !errors.Is(err, ErrUploadNoParts) {
+		t.Fatalf("empty parts err = %v", err)
+	}
+	part, err := l.UploadPart(ctx, "a.txt", uploadId, 1, strings.NewReader("hello"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.CompleteUpload(ctx, "a.txt", uploadId, []storage.UploadPart{part}); err != nil {
+		t.Fatal(err)
+	}
+	//合并使用的临时文件不在根目录中
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Fatalf("unexpected files %v", entries)
+	}
+	if b, err := l.Get(ctx, "a.txt"); err != nil || string(b) != "hello" {
+		t.Fatalf("get = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+var ErrDiskNotFound = errors.New("storage disk not found")
+
+// ManagerConfig 多磁盘配置，Disks 只能通过配置文件设置，如:
+//
+//	storage:
+//	  default: avatars
+//	  disks:
+//	    avatars:
+//	      driver: oss
+//	      oss:
+//	        bucket: avatars
+//	    exports:
+//	      driver: local
+type ManagerConfig struct {
+	Default string            `help:"默认磁盘名称" default:"default" json:"default"`
+	Disks   map[string]Config `noflag:"true" json:"disks"`
+}
+
+// Manager 按名称管理多个存储磁盘
+type Manager struct {
+	def   string
+	mu    sync.RWMutex
+	disks map[string]storage.FileSystem
+}
+
+// NewManager 创建配置中的所有磁盘，默认磁盘必须存在
+func NewManager(conf ManagerConfig) (*Manager, error) {
+	m := &Manager{
+		def:   conf.Default,
+		disks: make(map[string]storage.FileSystem, len(conf.Disks)),
+	}
+	for name, cfg := range conf.Disks {
+		fs, err := cfg.FileSystem()
+		if err != nil {
+			return nil, fmt.Errorf("storage disk %s: %w", name, err)
+		}
+		m.disks[name] = fs
+	}
+	if _, ok := m.disks[m.def]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDiskNotFound, m.def)
+	}
+	return m, nil
+}
+
+// Disk 获取指定名称的磁盘，磁盘不存在时 panic
+func (m *Manager) Disk(name string) storage.FileSystem {
+	fs, err := m.Lookup(name)
+	if err != nil {
+		panic(err)
+	}
+	return fs
+}
+
+// Lookup 获取指定名称的磁盘，磁盘不存在时返回 ErrDiskNotFound
+func (m *Manager) Lookup(name string) (storage.FileSystem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fs, ok := m.disks[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDiskNotFound, name)
+	}
+	return fs, nil
+}
+
+// Default 默认磁盘
+func (m *Manager) Default() storage.FileSystem {
+	return m.Disk(m.def)
+}
+
+// Set 添加或替换磁盘，用于注册自定义驱动或在测试中替换为内存存储
+func (m *Manager) Set(name string, fs storage.FileSystem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.disks[name] = fs
+}
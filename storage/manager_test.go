@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opdss/common/cfgstruct"
+	"github.com/spf13/pflag"
+)
+
+func TestManager(t *testing.T) {
+	var conf ManagerConfig
+	cfgstruct.Bind(pflag.NewFlagSet("test", pflag.PanicOnError), &conf, cfgstruct.UseReleaseDefaults(), cfgstruct.ConfigVar("ROOT", t.TempDir()))
+	conf.Disks = map[string]Config{
+		"default": {Driver: DriverLocal, Local: LocalConfig{Root: t.TempDir()}},
+		"avatars": {Driver: DriverMemory},
+	}
+	m, err := NewManager(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Default().(*Local); !ok {
+		t.Fatalf("default disk is %T", m.Default())
+	}
+	ctx := context.Background()
+	if err = m.Disk("avatars").Put(ctx, "a.txt", []byte("a")); err != nil || !m.Disk("avatars").Exists(ctx, "a.txt") {
+		t.Fatalf("avatars disk: %v", err)
+	}
+	if _, err = m.Lookup("missing"); !errors.Is(err, ErrDiskNotFound) {
+		t.Fatalf("missing disk: %v", err)
+	}
+
+	conf.Disks["broken"] = Config{Driver: "ftp"}
+	if _, err = NewManager(conf); err == nil {
+		t.Fatal("expected unknown driver error")
+	}
+	delete(conf.Disks, "broken")
+	conf.Default = "exports"
+	if _, err = NewManager(conf); !errors.Is(err, ErrDiskNotFound) {
+		t.Fatalf("missing default disk: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+var _ storage.FileSystem = (*MemFS)(nil)
+var _ storage.Metadata = (*MemFS)(nil)
+var _ storage.RangeGetter = (*MemFS)(nil)
+
+// MemFS 内存文件存储，用于单元测试，文件路径规则与其他存储一致
+type MemFS struct {
+	url   string
+	mu    sync.RWMutex
+	files map[string]*memFile
+	dirs  map[string]struct{} //MakeDirectory 创建的空目录
+}
+
+type memFile struct {
+	content  []byte
+	opts     storage.PutOptions
+	etag     string
+	modified time.Time
+}
+
+// NewMemFS url为文件访问地址前缀
+func NewMemFS(url string) *MemFS {
+	return &MemFS{
+		url:   strings.TrimSuffix(url, "/"),
+		files: make(map[string]*memFile),
+		dirs:  make(map[string]struct{}),
+	}
+}
+
+// ListObjects 列出目录下的文件及子目录，按名称排序分页
+func (r *MemFS) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*storage.ListObjectRes, error) {
+	prefix, err := validPath(opt.Directory)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]storage.ListObject, 0)
+	for _, name := range r.children(prefix, true) {
+		list = append(list, storage.ListObject{Name: name, IsDir: true})
+	}
+	for _, name := range r.children(prefix, false) {
+		key := prefix + name
+		f := r.files[key]
+		list = append(list, storage.ListObject{
+			Name:         name,
+			IsDir:        false,
+			Size:         int64(len(f.content)),
+			Path:         key,
+			Url:          r.url + "/" + key,
+			LastModified: f.modified,
+		})
+	}
+	return pageObjects(list, opt), nil
+}
+
+// Copy 同时复制元信息
+func (r *MemFS) Copy(ctx context.Context, originFile, targetFile string) error {
+	f, err := r.file(originFile)
+	if err != nil {
+		return err
+	}
+	return r.PutWithOptions(ctx, targetFile, bytes.NewReader(f.content), f.opts)
+}
+
+// Delete 删除不存在的文件不报错
+func (r *MemFS) Delete(ctx context.Context, files ...string) error {
+	keys, err := objectKeys(files)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if r.isDir(key + "/") {
+			return errors.New("can't delete directory, please use DeleteDirectory")
+		}
+	}
+	for _, key := range keys {
+		delete(r.files, key)
+	}
+	return nil
+}
+
+func (r *MemFS) DeleteDirectory(ctx context.Context, directory string) error {
+	prefix, err := dirKey(directory)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.files {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.files, key)
+		}
+	}
+	for dir := range r.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			delete(r.dirs, dir)
+		}
+	}
+	return nil
+}
+
+func (r *MemFS) Directories(ctx context.Context, path string) ([]string, error) {
+	prefix, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var directories []string
+	for _, name := range r.children(prefix, true) {
+		directories = append(directories, name+"/")
+	}
+	return directories, nil
+}
+
+func (r *MemFS) Exists(ctx context.Context, file string) bool {
+	_, err := r.file(file)
+	return err == nil
+}
+
+func (r *MemFS) Files(ctx context.Context, path string) ([]string, error) {
+	prefix, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.children(prefix, false), nil
+}
+
+func (r *MemFS) Get(ctx context.Context, file string) ([]byte, error) {
+	f, err := r.file(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(f.content), nil
+}
+
+func (r *MemFS) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
+	f, err := r.file(file)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func (r *MemFS) GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, ErrInvalidRange
+	}
+	f, err := r.file(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkRange(offset, int64(len(f.content))); err != nil {
+		return nil, err
+	}
+	content := f.content[offset:]
+	if length > 0 && length < int64(len(content)) {
+		content = content[:length]
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (r *MemFS) LastModified(ctx context.Context, file string) (time.Time, error) {
+	f, err := r.file(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return f.modified, nil
+}
+
+func (r *MemFS) MakeDirectory(ctx context.Context, directory string) error {
+	prefix, err := dirKey(directory)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dirs[prefix] = struct{}{}
+	return nil
+}
+
+func (r *MemFS) MimeType(ctx context.Context, file string) (string, error) {
+	f, err := r.file(file)
+	if err != nil {
+		return "", err
+	}
+	return f.opts.ContentType, nil
+}
+
+func (r *MemFS) Missing(ctx context.Context, file string) bool {
+	return !r.Exists(ctx, file)
+}
+
+func (r *MemFS) Move(ctx context.Context, oldFile, newFile string) error {
+	if err := r.Copy(ctx, oldFile, newFile); err != nil {
+		return err
+	}
+	return r.Delete(ctx, oldFile)
+}
+
+// Path 规范化后的文件路径，路径不合法时返回空字符串
+func (r *MemFS) Path(file string) string {
+	key, _ := objectKey(file)
+	return key
+}
+
+func (r *MemFS) Put(ctx context.Context, file string, content []byte) error {
+	return r.PutWithOptions(ctx, file, bytes.NewReader(content), storage.PutOptions{})
+}
+
+func (r *MemFS) PutStream(ctx context.Context, file string, rs io.Reader) error {
+	return r.PutWithOptions(ctx, file, rs, storage.PutOptions{})
+}
+
+// PutWithOptions ETag为内容的md5
+func (r *MemFS) PutWithOptions(ctx context.Context, file string, rs io.Reader, opts storage.PutOptions) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(rs)
+	if err != nil {
+		return err
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = contentType(key, content)
+	}
+	opts.Metadata = lowerKeys(opts.Metadata)
+	sum := md5.Sum(content)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[key] = &memFile{
+		content:  content,
+		opts:     opts,
+		etag:     hex.EncodeToString(sum[:]),
+		modified: time.Now(),
+	}
+	return nil
+}
+
+func (r *MemFS) Size(ctx context.Context, file string) (int64, error) {
+	f, err := r.file(file)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(f.content)), nil
+}
+
+func (r *MemFS) Stat(ctx context.Context, file string) (*storage.ObjectInfo, error) {
+	f, err := r.file(file)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.ObjectInfo{
+		Key:                r.Path(file),
+		Size:               int64(len(f.content)),
+		ContentType:        f.opts.ContentType,
+		ContentDisposition: f.opts.ContentDisposition,
+		CacheControl:       f.opts.CacheControl,
+		ETag:               f.etag,
+		LastModified:       f.modified,
+		StorageClass:       f.opts.StorageClass,
+		Metadata:           lowerKeys(f.opts.Metadata),
+	}, nil
+}
+
+// Url 文件访问地址，路径不合法时返回空字符串
+func (r *MemFS) Url(file string) string {
+	key, err := objectKey(file)
+	if err != nil {
+		return ""
+	}
+	return r.url + "/" + key
+}
+
+func (r *MemFS) file(file string) (*memFile, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.files[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: key, Err: fs.ErrNotExist}
+	}
+	return f, nil
+}
+
+// isDir prefix下是否有文件或目录
+func (r *MemFS) isDir(prefix string) bool {
+	for key := range r.files {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	for dir := range r.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// children prefix目录下的直接子目录或文件名称，已排序
+func (r *MemFS) children(prefix string, dir bool) []string {
+	names := make(map[string]struct{})
+	add := func(key string) {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == "" {
+			return
+		}
+		name, _, isDir := strings.Cut(rest, "/")
+		if isDir == dir {
+			names[name] = struct{}{}
+		}
+	}
+	for key := range r.files {
+		add(key)
+	}
+	for d := range r.dirs {
+		add(d)
+	}
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/opdss/common/contracts/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultPartSize 分片上传默认分片大小
+const DefaultPartSize int64 = 8 << 20
+
+// MinPartSize 云存储要求的最小分片大小(最后一个分片除外)
+const MinPartSize int64 = 5 << 20
+
+// DefaultUploadConcurrency 分片上传默认并发数
+const DefaultUploadConcurrency = 4
+
+// uploader 上传时可设置对象元信息的分片上传
+type uploader interface {
+	storage.MultipartUploader
+	// putObject 数据不超过一个分片时直接上传
+	putObject(ctx context.Context, key string, content []byte, opts storage.PutOptions) error
+	initUpload(ctx context.Context, key string, opts storage.PutOptions) (string, error)
+}
+
+// multipartPut 流式上传，数据不超过一个分片时直接上传，否则分片并发上传，
+// 内存占用约为 partSize*(concurrency+1)，上传失败时取消分片上传，未指定ContentType时按第一个分片判断
+func multipartPut(ctx context.Context, u uploader, key string, rs io.Reader, opts storage.PutOptions, partSize int64, concurrency int) error {
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	partSize = max(partSize, MinPartSize)
+	if concurrency <= 0 {
+		concurrency = DefaultUploadConcurrency
+	}
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(rs, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = contentType(key, buf[:n])
+	}
+	if err != nil {
+		return u.putObject(ctx, key, buf[:n], opts)
+	}
+	uploadId, err := u.initUpload(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+	parts, err := uploadParts(ctx, u, key, uploadId, buf, rs, concurrency)
+	if err == nil {
+		err = u.CompleteUpload(ctx, key, uploadId, parts)
+	}
+	if err != nil {
+		//上传的context可能已取消
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _err := u.AbortUpload(abortCtx, key, uploadId); _err != nil {
+			log.Println("abort multipart upload error", key, _err.Error())
+		}
+	}
+	return err
+}
+
+// uploadParts 依次读取分片并发上传，first为已读取的第一个分片
+func uploadParts(ctx context.Context, u storage.MultipartUploader, key, uploadId string, first []byte, rs io.Reader, concurrency int) ([]storage.UploadPart, error) {
+	var mu sync.Mutex
+	parts := make([]storage.UploadPart, 0)
+	//正在上传的分片最多concurrency个，加上正在读取的一个
+	free := make(chan []byte, concurrency+1)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	data := first
+	for number := 1; ; number++ {
+		part, num := data, number
+		g.Go(func() error {
+			defer func() {
+				free <- part[:cap(part)]
+			}()
+			p, err := u.UploadPart(gctx, key, uploadId, num, bytes.NewReader(part), int64(len(part)))
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			parts = append(parts, p)
+			mu.Unlock()
+			return nil
+		})
+		//最后一个分片
+		if len(part) < cap(part) || gctx.Err() != nil {
+			break
+		}
+		var buf []byte
+		select {
+		case buf = <-free:
+		default:
+			buf = make([]byte, cap(part))
+		}
+		n, err := io.ReadFull(rs, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			_ = g.Wait()
+			return nil, err
+		}
+		data = buf[:n]
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+	return parts, nil
+}
+
+// contentType 优先按后缀判断文件类型，未知后缀按内容判断
+func contentType(key string, head []byte) string {
+	ext := strings.ToLower(path.Ext(key))
+	if ext == ".apk" {
+		return "application/vnd.android.package-archive"
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return mimetype.Detect(head).String()
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+func TestMultipartPut(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewLocal(LocalConfig{Root: t.TempDir(), UploadDir: t.TempDir()})
+	data := bytes.Repeat([]byte("0123456789abcdef"), int(2*MinPartSize/16)+100)
+	if err := multipartPut(ctx, local, "big.bin", bytes.NewReader(data), storage.PutOptions{}, MinPartSize, 2); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := local.Get(ctx, "big.bin"); err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("content mismatch: %d %v", len(b), err)
+	}
+
+	//读取出错时取消上传
+	failed := io.MultiReader(bytes.NewReader(data[:MinPartSize+10]), errReader{})
+	if err := multipartPut(ctx, local, "failed.bin", failed, storage.PutOptions{}, MinPartSize, 2); err == nil {
+		t.Fatal("expected error")
+	}
+	if entries, _ := os.ReadDir(local.uploadDir); len(entries) != 0 || local.Exists(ctx, "failed.bin") {
+		t.Fatalf("upload not aborted: %d", len(entries))
+	}
+}
+
+func TestLocalResumableUpload(t *testing.T) {
+	ctx := context.Background()
+	local, _ := NewLocal(LocalConfig{Root: t.TempDir(), UploadDir: t.TempDir()})
+	uploadId, err := local.InitUpload(ctx, "a/b.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = local.UploadPart(ctx, "a/b.txt", uploadId, 2, bytes.NewReader([]byte("world")), 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = local.UploadPart(ctx, "other.txt", uploadId, 1, bytes.NewReader([]byte("x")), 1); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("upload id for other file: %v", err)
+	}
+	//中断后查询已上传分片继续上传
+	parts, err := local.ListParts(ctx, "a/b.txt", uploadId)
+	if err != nil || len(parts) != 1 || parts[0].Number != 2 {
+		t.Fatalf("list parts: %v %v", parts, err)
+	}
+	part, err := local.UploadPart(ctx, "a/b.txt", uploadId, 1, bytes.NewReader([]byte("hello ")), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = local.CompleteUpload(ctx, "a/b.txt", uploadId, append([]storage.UploadPart{part}, parts...)); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := local.Get(ctx, "a/b.txt"); string(b) != "hello world" {
+		t.Fatalf("content = %q", b)
+	}
+	if _, err = local.ListParts(ctx, "a/b.txt", uploadId); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("upload not removed: %v", err)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
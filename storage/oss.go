@@ -15,7 +15,7 @@ import (
 )
 
 type OssConfig struct {
-	AccessKeyId     string `help:"accessKeyId" default:""  json:"access_key_id""`
+	AccessKeyId     string `help:"accessKeyId" default:""  json:"access_key_id"`
 	AccessKeySecret string `help:"accessKeySecret" default:""  json:"access_key_secret"`
 
 	RoleArn  string `help:"roleArn" default:"" json:"role_arn"`
@@ -24,9 +24,16 @@ type OssConfig struct {
 	Bucket   string `help:"存储桶" default:"" json:"bucket"`
 	Url      string `help:"加速访问地址" default:"" json:"url"`
 	Endpoint string `help:"api入口" default:"" json:"endpoint"`
+
+	PartSize    int64 `help:"分片上传的分片大小，单位字节" default:"8388608" json:"part_size"`
+	Concurrency int   `help:"分片上传并发数" default:"4" json:"concurrency"`
 }
 
 var _ storage.FileSystem = (*Oss)(nil)
+var _ storage.Signer = (*Oss)(nil)
+var _ storage.MultipartUploader = (*Oss)(nil)
+var _ storage.Metadata = (*Oss)(nil)
+var _ storage.RangeGetter = (*Oss)(nil)
 
 /*
  * Oss OSS
@@ -66,8 +73,11 @@ func (r *Oss) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*st
 	res := storage.ListObjectRes{
 		List: make([]storage.ListObject, 0),
 	}
-	vPath := validPath(opt.Directory)
-	listObjsResponse, err := r.bucketInstance.ListObjectsV2(oss.Prefix(vPath), oss.MaxKeys(int(getPageSize(opt.MaxKeys))), oss.ContinuationToken(opt.NextToken), oss.Delimiter("/"))
+	vPath, err := validPath(opt.Directory)
+	if err != nil {
+		return nil, err
+	}
+	listObjsResponse, err := r.bucketInstance.ListObjectsV2(oss.Prefix(vPath), oss.MaxKeys(int(getPageSize(opt.MaxKeys))), oss.ContinuationToken(opt.NextToken), oss.Delimiter("/"), oss.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -100,28 +110,39 @@ func (r *Oss) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*st
 }
 
 func (r *Oss) Copy(ctx context.Context, originFile, targetFile string) error {
-	if _, err := r.bucketInstance.CopyObject(originFile, targetFile); err != nil {
+	originFile, err := objectKey(originFile)
+	if err != nil {
+		return err
+	}
+	if targetFile, err = objectKey(targetFile); err != nil {
+		return err
+	}
+	if _, err := r.bucketInstance.CopyObject(originFile, targetFile, oss.WithContext(ctx)); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (r *Oss) Delete(ctx context.Context, files ...string) error {
-	_, err := r.bucketInstance.DeleteObjects(files)
+	keys, err := objectKeys(files)
 	if err != nil {
 		return err
 	}
+	if _, err = r.bucketInstance.DeleteObjects(keys, oss.WithContext(ctx)); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (r *Oss) DeleteDirectory(ctx context.Context, directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
+	directory, err := dirKey(directory)
+	if err != nil {
+		return err
 	}
 	marker := oss.Marker("")
 	prefix := oss.Prefix(directory)
 	for {
-		lor, err := r.bucketInstance.ListObjects(marker, prefix)
+		lor, err := r.bucketInstance.ListObjects(marker, prefix, oss.WithContext(ctx))
 		if err != nil {
 			return err
 		}
@@ -134,7 +155,7 @@ func (r *Oss) DeleteDirectory(ctx context.Context, directory string) error {
 			objects = append(objects, object.Key)
 		}
 
-		if _, err := r.bucketInstance.DeleteObjects(objects, oss.DeleteObjectsQuiet(true)); err != nil {
+		if _, err := r.bucketInstance.DeleteObjects(objects, oss.DeleteObjectsQuiet(true), oss.WithContext(ctx)); err != nil {
 			return err
 		}
 
@@ -150,8 +171,11 @@ func (r *Oss) DeleteDirectory(ctx context.Context, directory string) error {
 
 func (r *Oss) Directories(ctx context.Context, path string) ([]string, error) {
 	var directories []string
-	vPath := validPath(path)
-	lsRes, err := r.bucketInstance.ListObjectsV2(oss.MaxKeys(storage.MaxFileNum), oss.Prefix(vPath), oss.Delimiter("/"))
+	vPath, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
+	lsRes, err := r.bucketInstance.ListObjectsV2(oss.MaxKeys(storage.MaxFileNum), oss.Prefix(vPath), oss.Delimiter("/"), oss.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +186,11 @@ func (r *Oss) Directories(ctx context.Context, path string) ([]string, error) {
 }
 
 func (r *Oss) Exists(ctx context.Context, file string) bool {
-	exist, err := r.bucketInstance.IsObjectExist(file)
+	key, err := objectKey(file)
+	if err != nil {
+		return false
+	}
+	exist, err := r.bucketInstance.IsObjectExist(key, oss.WithContext(ctx))
 	if err != nil {
 		return false
 	}
@@ -171,8 +199,11 @@ func (r *Oss) Exists(ctx context.Context, file string) bool {
 
 func (r *Oss) Files(ctx context.Context, path string) ([]string, error) {
 	var files []string
-	vPath := validPath(path)
-	lsRes, err := r.bucketInstance.ListObjectsV2(oss.MaxKeys(storage.MaxFileNum), oss.Prefix(vPath), oss.Delimiter("/"))
+	vPath, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
+	lsRes, err := r.bucketInstance.ListObjectsV2(oss.MaxKeys(storage.MaxFileNum), oss.Prefix(vPath), oss.Delimiter("/"), oss.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -194,11 +225,36 @@ func (r *Oss) Get(ctx context.Context, file string) ([]byte, error) {
 }
 
 func (r *Oss) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
-	return r.bucketInstance.GetObject(file)
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	return r.bucketInstance.GetObject(key, oss.WithContext(ctx))
+}
+
+func (r *Oss) GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := httpRange(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	body, err := r.bucketInstance.GetObject(key, oss.NormalizedRange(strings.TrimPrefix(rng, "bytes=")), oss.WithContext(ctx))
+	var se oss.ServiceError
+	if errors.As(err, &se) && se.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return rangeNotSatisfiable(offset)
+	}
+	return body, err
 }
 
 func (r *Oss) LastModified(ctx context.Context, file string) (time.Time, error) {
-	headers, err := r.bucketInstance.GetObjectDetailedMeta(file)
+	key, err := objectKey(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	headers, err := r.bucketInstance.GetObjectDetailedMeta(key, oss.WithContext(ctx))
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -206,15 +262,20 @@ func (r *Oss) LastModified(ctx context.Context, file string) (time.Time, error)
 }
 
 func (r *Oss) MakeDirectory(ctx context.Context, directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
+	directory, err := dirKey(directory)
+	if err != nil {
+		return err
 	}
 
-	return r.bucketInstance.PutObject(directory, bytes.NewReader([]byte("")))
+	return r.bucketInstance.PutObject(directory, bytes.NewReader([]byte("")), oss.WithContext(ctx))
 }
 
 func (r *Oss) MimeType(ctx context.Context, file string) (string, error) {
-	headers, err := r.bucketInstance.GetObjectDetailedMeta(file)
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	headers, err := r.bucketInstance.GetObjectDetailedMeta(key, oss.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -232,20 +293,51 @@ func (r *Oss) Move(ctx context.Context, oldFile, newFile string) error {
 	return r.Delete(ctx, oldFile)
 }
 
+// Path 规范化后的文件路径，路径不合法时返回空字符串
 func (r *Oss) Path(file string) string {
-	return file
+	key, _ := objectKey(file)
+	return key
 }
 
 func (r *Oss) Put(ctx context.Context, file string, content []byte) error {
-	return r.bucketInstance.PutObject(file, bytes.NewReader(content))
+	return r.PutStream(ctx, file, bytes.NewReader(content))
 }
 
+// PutStream 流式上传，超过一个分片大小时使用分片上传
 func (r *Oss) PutStream(ctx context.Context, file string, rs io.Reader) error {
-	return r.bucketInstance.PutObject(file, rs)
+	return r.PutWithOptions(ctx, file, rs, storage.PutOptions{})
+}
+
+func (r *Oss) PutWithOptions(ctx context.Context, file string, rs io.Reader, opts storage.PutOptions) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	return multipartPut(ctx, r, key, rs, opts, r.config.PartSize, r.config.Concurrency)
+}
+
+func (r *Oss) putObject(ctx context.Context, key string, content []byte, opts storage.PutOptions) error {
+	return r.bucketInstance.PutObject(key, bytes.NewReader(content), append(ossOptions(opts), oss.WithContext(ctx))...)
+}
+
+func (r *Oss) Stat(ctx context.Context, file string) (*storage.ObjectInfo, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := r.bucketInstance.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return headerObjectInfo(key, headers, oss.HTTPHeaderOssMetaPrefix, oss.HTTPHeaderOssStorageClass)
 }
 
 func (r *Oss) Size(ctx context.Context, file string) (int64, error) {
-	props, err := r.bucketInstance.GetObjectDetailedMeta(file)
+	key, err := objectKey(file)
+	if err != nil {
+		return 0, err
+	}
+	props, err := r.bucketInstance.GetObjectDetailedMeta(key, oss.WithContext(ctx))
 	if err != nil {
 		return 0, err
 	}
@@ -260,16 +352,137 @@ func (r *Oss) Size(ctx context.Context, file string) (int64, error) {
 	return contentLengthInt, nil
 }
 
+// Url 文件访问地址，路径不合法时返回空字符串
 func (r *Oss) Url(file string) string {
-	return r.config.Url + "/" + file
+	key, err := objectKey(file)
+	if err != nil {
+		return ""
+	}
+	return r.config.Url + "/" + key
+}
+
+func (r *Oss) PresignGet(ctx context.Context, file string, ttl time.Duration) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	return r.bucketInstance.SignURL(key, oss.HTTPGet, int64(ttl.Seconds()))
+}
+
+func (r *Oss) PresignPut(ctx context.Context, file string, ttl time.Duration, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	var opts []oss.Option
+	if contentType != "" {
+		opts = append(opts, oss.ContentType(contentType))
+	}
+	return r.bucketInstance.SignURL(key, oss.HTTPPut, int64(ttl.Seconds()), opts...)
 }
 
-func validPath(path string) string {
-	realPath := strings.TrimPrefix(path, "./")
-	realPath = strings.TrimPrefix(realPath, "/")
-	realPath = strings.TrimPrefix(realPath, ".")
-	if realPath != "" && !strings.HasSuffix(realPath, "/") {
-		realPath += "/"
+func (r *Oss) InitUpload(ctx context.Context, file string, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	return r.initUpload(ctx, key, storage.PutOptions{ContentType: contentType})
+}
+
+func (r *Oss) initUpload(ctx context.Context, key string, opts storage.PutOptions) (string, error) {
+	imur, err := r.bucketInstance.InitiateMultipartUpload(key, append(ossOptions(opts), oss.WithContext(ctx))...)
+	if err != nil {
+		return "", err
+	}
+	return imur.UploadID, nil
+}
+
+// ossOptions 上传时设置的请求头
+func ossOptions(opts storage.PutOptions) []oss.Option {
+	var options []oss.Option
+	if opts.ContentType != "" {
+		options = append(options, oss.ContentType(opts.ContentType))
+	}
+	if opts.ContentDisposition != "" {
+		options = append(options, oss.ContentDisposition(opts.ContentDisposition))
+	}
+	if opts.CacheControl != "" {
+		options = append(options, oss.CacheControl(opts.CacheControl))
+	}
+	for k, v := range opts.Metadata {
+		options = append(options, oss.Meta(k, v))
+	}
+	if opts.StorageClass != "" {
+		options = append(options, oss.ObjectStorageClass(oss.StorageClassType(opts.StorageClass)))
+	}
+	if opts.ACL != "" {
+		options = append(options, oss.ObjectACL(oss.ACLType(opts.ACL)))
+	}
+	return options
+}
+
+func (r *Oss) UploadPart(ctx context.Context, file, uploadId string, number int, rs io.Reader, size int64) (storage.UploadPart, error) {
+	imur, err := r.multipart(file, uploadId)
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	part, err := r.bucketInstance.UploadPart(imur, rs, size, number, oss.WithContext(ctx))
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	return storage.UploadPart{Number: part.PartNumber, ETag: part.ETag, Size: size}, nil
+}
+
+func (r *Oss) ListParts(ctx context.Context, file, uploadId string) ([]storage.UploadPart, error) {
+	imur, err := r.multipart(file, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]storage.UploadPart, 0)
+	marker := 0
+	for {
+		res, err := r.bucketInstance.ListUploadedParts(imur, oss.PartNumberMarker(marker), oss.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range res.UploadedParts {
+			parts = append(parts, storage.UploadPart{Number: p.PartNumber, ETag: p.ETag, Size: int64(p.Size)})
+		}
+		if !res.IsTruncated {
+			break
+		}
+		if marker, err = strconv.Atoi(res.NextPartNumberMarker); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+func (r *Oss) CompleteUpload(ctx context.Context, file, uploadId string, parts []storage.UploadPart) error {
+	imur, err := r.multipart(file, uploadId)
+	if err != nil {
+		return err
+	}
+	uploaded := make([]oss.UploadPart, 0, len(parts))
+	for _, p := range parts {
+		uploaded = append(uploaded, oss.UploadPart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	_, err = r.bucketInstance.CompleteMultipartUpload(imur, uploaded, oss.WithContext(ctx))
+	return err
+}
+
+func (r *Oss) AbortUpload(ctx context.Context, file, uploadId string) error {
+	imur, err := r.multipart(file, uploadId)
+	if err != nil {
+		return err
+	}
+	return r.bucketInstance.AbortMultipartUpload(imur, oss.WithContext(ctx))
+}
+
+func (r *Oss) multipart(file, uploadId string) (oss.InitiateMultipartUploadResult, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return oss.InitiateMultipartUploadResult{}, err
 	}
-	return realPath
+	return oss.InitiateMultipartUploadResult{Bucket: r.config.Bucket, Key: key, UploadID: uploadId}, nil
 }
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOssContext(t *testing.T) {
+	//请求等待到客户端断开，最多2秒
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+	o, err := NewOss(OssConfig{AccessKeyId: "id", AccessKeySecret: "secret", Bucket: "bucket", Endpoint: server.URL, Url: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := map[string]func(ctx context.Context) error{
+		"GetRange": func(ctx context.Context) error {
+			_, err := o.GetRange(ctx, "a.txt", 1, 2)
+			return err
+		},
+		"PutWithOptions": func(ctx context.Context) error {
+			return o.Put(ctx, "a.txt", []byte("a"))
+		},
+		"InitUpload": func(ctx context.Context) error {
+			_, err := o.InitUpload(ctx, "a.txt", "")
+			return err
+		},
+		"UploadPart": func(ctx context.Context) error {
+			_, err := o.UploadPart(ctx, "a.txt", "id", 1, bytes.NewReader([]byte("a")), 1)
+			return err
+		},
+		"CompleteUpload": func(ctx context.Context) error {
+			return o.CompleteUpload(ctx, "a.txt", "id", nil)
+		},
+	}
+	for name, call := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		err := call(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s error = %v, want context deadline exceeded", name, err)
+		}
+	}
+}
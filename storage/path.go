@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrPathEscapesRoot = errors.New("path escapes storage root")
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// normalizeKey 统一各存储的文件路径：\ 转为 /，去掉开头的 /、多余的 / 及 . ，
+// 解析 .. 且不允许超出根目录，返回空字符串表示根目录。所有存储使用相同规则，同一个key在各存储中对应同一个文件
+func normalizeKey(key string) (string, error) {
+	parts := make([]string, 0, strings.Count(key, "/")+1)
+	for _, part := range strings.Split(strings.ReplaceAll(key, "\\", "/"), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(parts) == 0 {
+				return "", &fs.PathError{Op: "normalize", Path: key, Err: ErrPathEscapesRoot}
+			}
+			parts = parts[:len(parts)-1]
+		default:
+			for _, c := range part {
+				if c < 0x20 || c == 0x7F {
+					return "", &fs.PathError{Op: "normalize", Path: key, Err: ErrInvalidKey}
+				}
+			}
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// objectKey 文件路径，不能为根目录
+func objectKey(file string) (string, error) {
+	key, err := normalizeKey(file)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", &fs.PathError{Op: "normalize", Path: file, Err: ErrInvalidKey}
+	}
+	return key, nil
+}
+
+// objectKeys 批量转换文件路径
+func objectKeys(files []string) ([]string, error) {
+	keys := make([]string, len(files))
+	for i := range files {
+		key, err := objectKey(files[i])
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// validPath 目录前缀，以 / 结尾，根目录返回空字符串
+func validPath(path string) (string, error) {
+	key, err := normalizeKey(path)
+	if err != nil || key == "" {
+		return "", err
+	}
+	return key + "/", nil
+}
+
+// dirKey 删除、创建目录使用的目录前缀，不能为根目录
+func dirKey(directory string) (string, error) {
+	key, err := objectKey(directory)
+	if err != nil {
+		return "", err
+	}
+	return key + "/", nil
+}
+
+// confine 本地路径限制在root内，会解析符号链接，路径不存在时检查最近的已存在的上级目录
+func confine(root, key string) (string, error) {
+	full := filepath.Join(root, filepath.FromSlash(key))
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		//根目录还未创建，其中不会有符号链接
+		return full, nil
+	}
+	existing, rest := full, ""
+	for {
+		realPath, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			rel, err := filepath.Rel(realRoot, filepath.Join(realPath, rest))
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return "", &fs.PathError{Op: "confine", Path: key, Err: ErrPathEscapesRoot}
+			}
+			return full, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		//文件存在但无法解析，是指向不存在路径的符号链接，写入时会在链接目标处创建文件
+		if _, err = os.Lstat(existing); err == nil {
+			return "", &fs.PathError{Op: "confine", Path: key, Err: ErrPathEscapesRoot}
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return full, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
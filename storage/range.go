@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/opdss/common/contracts/storage"
+)
+
+var ErrInvalidRange = errors.New("invalid range")
+
+var _ io.ReadSeekCloser = (*RangeReader)(nil)
+
+// RangeReader 可Seek的只读文件，Seek后下次Read时从新位置发起范围请求，存储不支持 storage.RangeGetter 时读取并丢弃前面的内容
+type RangeReader struct {
+	ctx    context.Context
+	fs     storage.FileSystem
+	key    string
+	size   int64
+	offset int64
+	rc     io.ReadCloser
+	closed bool
+}
+
+// NewRangeReader 打开时只获取文件大小，不读取内容
+func NewRangeReader(ctx context.Context, fs storage.FileSystem, file string) (*RangeReader, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	size, err := fs.Size(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return newRangeReader(ctx, fs, key, size), nil
+}
+
+func newRangeReader(ctx context.Context, fs storage.FileSystem, key string, size int64) *RangeReader {
+	return &RangeReader{ctx: ctx, fs: fs, key: key, size: size}
+}
+
+// Size 打开时的文件大小
+func (r *RangeReader) Size() int64 {
+	return r.size
+}
+
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, fs.ErrClosed
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.rc == nil {
+		rc, err := getRange(r.ctx, r.fs, r.key, r.offset, -1)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: r.key, Err: err}
+		}
+		r.rc = rc
+	}
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, fs.ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: r.key, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: r.key, Err: fs.ErrInvalid}
+	}
+	if offset != r.offset && r.rc != nil {
+		_ = r.rc.Close()
+		r.rc = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *RangeReader) Close() error {
+	if r.closed {
+		return fs.ErrClosed
+	}
+	r.closed = true
+	if r.rc != nil {
+		return r.rc.Close()
+	}
+	return nil
+}
+
+// getRange 存储不支持范围读取时读取并丢弃offset之前的内容
+func getRange(ctx context.Context, fs storage.FileSystem, key string, offset, length int64) (io.ReadCloser, error) {
+	if g, ok := fs.(storage.RangeGetter); ok {
+		return g.GetRange(ctx, key, offset, length)
+	}
+	if offset < 0 {
+		return nil, ErrInvalidRange
+	}
+	rc, err := fs.GetStream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		return limitReadCloser(rc, length), nil
+	}
+	if _, err = io.CopyN(io.Discard, rc, offset); err != nil {
+		_ = rc.Close()
+		if err == io.EOF {
+			return nil, ErrInvalidRange
+		}
+		return nil, err
+	}
+	//offset等于文件大小时也超出范围
+	br := bufio.NewReader(rc)
+	if _, err = br.Peek(1); err != nil {
+		_ = rc.Close()
+		if err == io.EOF {
+			return nil, ErrInvalidRange
+		}
+		return nil, err
+	}
+	return limitReadCloser(struct {
+		io.Reader
+		io.Closer
+	}{br, rc}, length), nil
+}
+
+// checkRange offset小于0或不小于文件大小时返回 ErrInvalidRange，空文件offset为0时可读取空内容
+func checkRange(offset, size int64) error {
+	if offset < 0 || (offset > 0 && offset >= size) {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// rangeNotSatisfiable 云存储返回416时，offset为0说明是空文件，返回空内容，否则返回 ErrInvalidRange
+func rangeNotSatisfiable(offset int64) (io.ReadCloser, error) {
+	if offset == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return nil, ErrInvalidRange
+}
+
+// limitReadCloser length<=0时不限制
+func limitReadCloser(rc io.ReadCloser, length int64) io.ReadCloser {
+	if length <= 0 {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, length), rc}
+}
+
+// httpRange Range请求头，length<=0时读取到文件末尾
+func httpRange(offset, length int64) (string, error) {
+	if offset < 0 {
+		return "", ErrInvalidRange
+	}
+	if length <= 0 {
+		return fmt.Sprintf("bytes=%d-", offset), nil
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1), nil
+}
@@ -6,7 +6,8 @@ import (
 	"errors"
 	"github.com/opdss/common/contracts/storage"
 	"io"
-	"path/filepath"
+	"net/http"
+	"path"
 	"strings"
 	"time"
 
@@ -15,7 +16,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/gabriel-vasile/mimetype"
 )
 
 /*
@@ -32,9 +32,16 @@ type S3Config struct {
 	Region          string `help:"地区" default:""`
 	Url             string `help:"访问地址" default:""`
 	Endpoint        string `help:"api入口" default:""`
+	PartSize        int64  `help:"分片上传的分片大小，单位字节" default:"8388608" json:"part_size"`
+	Concurrency     int    `help:"分片上传并发数" default:"4" json:"concurrency"`
+	PathStyle       bool   `help:"使用路径方式访问存储桶，MinIO等兼容S3的存储需要开启" default:"false" json:"path_style"`
 }
 
 var _ storage.FileSystem = (*S3)(nil)
+var _ storage.Signer = (*S3)(nil)
+var _ storage.MultipartUploader = (*S3)(nil)
+var _ storage.Metadata = (*S3)(nil)
+var _ storage.RangeGetter = (*S3)(nil)
 
 type S3 struct {
 	config   S3Config
@@ -63,7 +70,9 @@ func NewS3(config S3Config) (*S3, error) {
 	}
 	config.Url = strings.TrimSuffix(config.Url, "/")
 
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = config.PathStyle
+	})
 	return &S3{
 		config: config,
 		//instance: client,
@@ -75,7 +84,10 @@ func (r *S3) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*sto
 	res := storage.ListObjectRes{
 		List: make([]storage.ListObject, 0),
 	}
-	vPath := validPath(opt.Directory)
+	vPath, err := validPath(opt.Directory)
+	if err != nil {
+		return nil, err
+	}
 	var continuationToken *string
 	if opt.NextToken != "" {
 		continuationToken = aws.String(opt.NextToken)
@@ -93,7 +105,7 @@ func (r *S3) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*sto
 	if listObjsResponse.NextContinuationToken != nil {
 		res.NextToken = *listObjsResponse.NextContinuationToken
 	}
-	res.HasMore = *listObjsResponse.IsTruncated
+	res.HasMore = aws.ToBool(listObjsResponse.IsTruncated)
 	for _, object := range listObjsResponse.CommonPrefixes {
 		name := strings.Trim(strings.ReplaceAll(*object.Prefix, vPath, ""), "/")
 		if name == "" {
@@ -122,7 +134,14 @@ func (r *S3) ListObjects(ctx context.Context, opt *storage.ListObjectOpts) (*sto
 }
 
 func (r *S3) Copy(ctx context.Context, originFile, targetFile string) error {
-	_, err := r.instance.CopyObject(ctx, &s3.CopyObjectInput{
+	originFile, err := objectKey(originFile)
+	if err != nil {
+		return err
+	}
+	if targetFile, err = objectKey(targetFile); err != nil {
+		return err
+	}
+	_, err = r.instance.CopyObject(ctx, &s3.CopyObjectInput{
 		Bucket:     aws.String(r.config.Bucket),
 		CopySource: aws.String(r.config.Bucket + "/" + originFile),
 		Key:        aws.String(targetFile),
@@ -131,14 +150,18 @@ func (r *S3) Copy(ctx context.Context, originFile, targetFile string) error {
 }
 
 func (r *S3) Delete(ctx context.Context, files ...string) error {
+	keys, err := objectKeys(files)
+	if err != nil {
+		return err
+	}
 	var objectIdentifiers []types.ObjectIdentifier
-	for _, file := range files {
+	for _, key := range keys {
 		objectIdentifiers = append(objectIdentifiers, types.ObjectIdentifier{
-			Key: aws.String(file),
+			Key: aws.String(key),
 		})
 	}
 
-	_, err := r.instance.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+	_, err = r.instance.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 		Bucket: aws.String(r.config.Bucket),
 		Delete: &types.Delete{
 			Objects: objectIdentifiers,
@@ -149,8 +172,9 @@ func (r *S3) Delete(ctx context.Context, files ...string) error {
 }
 
 func (r *S3) DeleteDirectory(ctx context.Context, directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
+	directory, err := dirKey(directory)
+	if err != nil {
+		return err
 	}
 
 	listObjectsV2Response, err := r.instance.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
@@ -175,10 +199,11 @@ func (r *S3) DeleteDirectory(ctx context.Context, directory string) error {
 			}
 		}
 
-		if *listObjectsV2Response.IsTruncated {
+		if aws.ToBool(listObjectsV2Response.IsTruncated) {
 			listObjectsV2Response, err = r.instance.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 				Bucket:            aws.String(r.config.Bucket),
-				ContinuationToken: listObjectsV2Response.ContinuationToken,
+				Prefix:            aws.String(directory),
+				ContinuationToken: listObjectsV2Response.NextContinuationToken,
 			})
 			if err != nil {
 				return err
@@ -193,7 +218,10 @@ func (r *S3) DeleteDirectory(ctx context.Context, directory string) error {
 
 func (r *S3) Directories(ctx context.Context, path string) ([]string, error) {
 	var directories []string
-	validPath := validPath(path)
+	validPath, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
 	listObjsResponse, err := r.instance.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket:    aws.String(r.config.Bucket),
 		Delimiter: aws.String("/"),
@@ -210,9 +238,13 @@ func (r *S3) Directories(ctx context.Context, path string) ([]string, error) {
 }
 
 func (r *S3) Exists(ctx context.Context, file string) bool {
-	_, err := r.instance.HeadObject(ctx, &s3.HeadObjectInput{
+	key, err := objectKey(file)
+	if err != nil {
+		return false
+	}
+	_, err = r.instance.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(r.config.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(key),
 	})
 
 	return err == nil
@@ -220,7 +252,10 @@ func (r *S3) Exists(ctx context.Context, file string) bool {
 
 func (r *S3) Files(ctx context.Context, path string) ([]string, error) {
 	var files []string
-	validPath := validPath(path)
+	validPath, err := validPath(path)
+	if err != nil {
+		return nil, err
+	}
 	listObjsResponse, err := r.instance.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket:    aws.String(r.config.Bucket),
 		Delimiter: aws.String("/"),
@@ -253,10 +288,38 @@ func (r *S3) Get(ctx context.Context, file string) ([]byte, error) {
 }
 
 func (r *S3) GetStream(ctx context.Context, file string) (io.ReadCloser, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.instance.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (r *S3) GetRange(ctx context.Context, file string, offset, length int64) (io.ReadCloser, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := httpRange(offset, length)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := r.instance.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(r.config.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(key),
+		Range:  aws.String(rng),
 	})
+	var re interface{ HTTPStatusCode() int }
+	if errors.As(err, &re) && re.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable {
+		return rangeNotSatisfiable(offset)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -264,9 +327,13 @@ func (r *S3) GetStream(ctx context.Context, file string) (io.ReadCloser, error)
 }
 
 func (r *S3) LastModified(ctx context.Context, file string) (time.Time, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return time.Time{}, err
+	}
 	resp, err := r.instance.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(r.config.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return time.Time{}, err
@@ -275,19 +342,27 @@ func (r *S3) LastModified(ctx context.Context, file string) (time.Time, error) {
 }
 
 func (r *S3) MakeDirectory(ctx context.Context, directory string) error {
-	if !strings.HasSuffix(directory, "/") {
-		directory += "/"
-	}
-	if directory == "/" || directory == "./" {
-		return nil
+	directory, err := validPath(directory)
+	if err != nil || directory == "" {
+		return err
 	}
-	return r.Put(ctx, directory, []byte{})
+	_, err = r.instance.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(r.config.Bucket),
+		Key:           aws.String(directory),
+		Body:          bytes.NewReader([]byte{}),
+		ContentLength: aws.Int64(0),
+	})
+	return err
 }
 
 func (r *S3) MimeType(ctx context.Context, file string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
 	resp, err := r.instance.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(r.config.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return "", err
@@ -307,58 +382,88 @@ func (r *S3) Move(ctx context.Context, oldFile, newFile string) error {
 	return r.Delete(ctx, oldFile)
 }
 
+// Path 规范化后的文件路径，路径不合法时返回空字符串
 func (r *S3) Path(file string) string {
-	return strings.TrimPrefix(file, "/")
+	key, _ := objectKey(file)
+	return key
 }
 
 func (r *S3) Put(ctx context.Context, file string, content []byte) error {
-	file = r.Path(file)
-	if ext := filepath.Ext(file); ext != "" {
-		if err := r.MakeDirectory(ctx, filepath.Dir(file)); err != nil {
+	return r.PutStream(ctx, file, bytes.NewReader(content))
+}
+
+// PutStream 流式上传，超过一个分片大小时使用分片上传
+func (r *S3) PutStream(ctx context.Context, file string, rs io.Reader) error {
+	return r.PutWithOptions(ctx, file, rs, storage.PutOptions{})
+}
+
+func (r *S3) PutWithOptions(ctx context.Context, file string, rs io.Reader, opts storage.PutOptions) error {
+	file, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	if ext := path.Ext(file); ext != "" {
+		if err := r.MakeDirectory(ctx, path.Dir(file)); err != nil {
 			return err
 		}
 	}
-	mtype := mimetype.Detect(content)
-	_, err := r.instance.PutObject(ctx, &s3.PutObjectInput{
+	return multipartPut(ctx, r, file, rs, opts, r.config.PartSize, r.config.Concurrency)
+}
+
+func (r *S3) putObject(ctx context.Context, key string, content []byte, opts storage.PutOptions) error {
+	input := &s3.PutObjectInput{
 		Bucket:        aws.String(r.config.Bucket),
-		Key:           aws.String(file),
+		Key:           aws.String(key),
 		Body:          bytes.NewReader(content),
 		ContentLength: aws.Int64(int64(len(content))),
-		ContentType:   aws.String(mtype.String()),
-	})
+		ContentType:   aws.String(opts.ContentType),
+		Metadata:      opts.Metadata,
+		StorageClass:  types.StorageClass(opts.StorageClass),
+		ACL:           types.ObjectCannedACL(opts.ACL),
+	}
+	if opts.ContentDisposition != "" {
+		input.ContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+	_, err := r.instance.PutObject(ctx, input)
 	return err
 }
 
-func (r *S3) PutStream(ctx context.Context, file string, rs io.Reader) error {
-	file = r.Path(file)
-	ext := filepath.Ext(file)
-	if ext != "" {
-		if err := r.MakeDirectory(ctx, filepath.Dir(file)); err != nil {
-			return err
-		}
-	}
-	// var content []byte
-	content, _ := io.ReadAll(rs)
-	mtype := mimetype.Detect(content)
-	contentType := aws.String(mtype.String())
-	if strings.ToLower(strings.Trim(ext, ".")) == "apk" {
-		contentType = aws.String("application/vnd.android.package-archive")
+func (r *S3) Stat(ctx context.Context, file string) (*storage.ObjectInfo, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
 	}
-	_, err := r.instance.PutObject(ctx, &s3.PutObjectInput{
+	resp, err := r.instance.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(r.config.Bucket),
-		Key:    aws.String(file),
-		Body:   bytes.NewReader(content),
-
-		ContentLength: aws.Int64(int64(len(content))),
-		ContentType:   contentType,
+		Key:    aws.String(key),
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return &storage.ObjectInfo{
+		Key:                key,
+		Size:               aws.ToInt64(resp.ContentLength),
+		ContentType:        aws.ToString(resp.ContentType),
+		ContentDisposition: aws.ToString(resp.ContentDisposition),
+		CacheControl:       aws.ToString(resp.CacheControl),
+		ETag:               strings.Trim(aws.ToString(resp.ETag), `"`),
+		LastModified:       aws.ToTime(resp.LastModified),
+		StorageClass:       string(resp.StorageClass),
+		Metadata:           lowerKeys(resp.Metadata),
+	}, nil
 }
 
 func (r *S3) Size(ctx context.Context, file string) (int64, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return 0, err
+	}
 	resp, err := r.instance.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(r.config.Bucket),
-		Key:    aws.String(file),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return 0, err
@@ -366,6 +471,166 @@ func (r *S3) Size(ctx context.Context, file string) (int64, error) {
 	return *resp.ContentLength, nil
 }
 
+// Url 文件访问地址，路径不合法时返回空字符串
 func (r *S3) Url(file string) string {
-	return r.config.Url + "/" + strings.TrimPrefix(file, "/")
+	key, err := objectKey(file)
+	if err != nil {
+		return ""
+	}
+	return r.config.Url + "/" + key
+}
+
+func (r *S3) PresignGet(ctx context.Context, file string, ttl time.Duration) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	req, err := s3.NewPresignClient(r.instance).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.config.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (r *S3) PresignPut(ctx context.Context, file string, ttl time.Duration, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(r.config.Bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	req, err := s3.NewPresignClient(r.instance).PresignPutObject(ctx, input, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (r *S3) InitUpload(ctx context.Context, file string, contentType string) (string, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return "", err
+	}
+	return r.initUpload(ctx, key, storage.PutOptions{ContentType: contentType})
+}
+
+func (r *S3) initUpload(ctx context.Context, key string, opts storage.PutOptions) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(r.config.Bucket),
+		Key:          aws.String(key),
+		Metadata:     opts.Metadata,
+		StorageClass: types.StorageClass(opts.StorageClass),
+		ACL:          types.ObjectCannedACL(opts.ACL),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentDisposition != "" {
+		input.ContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+	resp, err := r.instance.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(resp.UploadId), nil
+}
+
+func (r *S3) UploadPart(ctx context.Context, file, uploadId string, number int, rs io.Reader, size int64) (storage.UploadPart, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	//签名需要计算分片内容的摘要，不能seek时先读取到内存
+	body, ok := rs.(io.ReadSeeker)
+	if !ok {
+		content, err := io.ReadAll(io.LimitReader(rs, size))
+		if err != nil {
+			return storage.UploadPart{}, err
+		}
+		body = bytes.NewReader(content)
+	}
+	resp, err := r.instance.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(r.config.Bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadId),
+		PartNumber:    aws.Int32(int32(number)),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return storage.UploadPart{}, err
+	}
+	return storage.UploadPart{Number: number, ETag: aws.ToString(resp.ETag), Size: size}, nil
+}
+
+func (r *S3) ListParts(ctx context.Context, file, uploadId string) ([]storage.UploadPart, error) {
+	key, err := objectKey(file)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]storage.UploadPart, 0)
+	paginator := s3.NewListPartsPaginator(r.instance, &s3.ListPartsInput{
+		Bucket:   aws.String(r.config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Parts {
+			parts = append(parts, storage.UploadPart{
+				Number: int(aws.ToInt32(p.PartNumber)),
+				ETag:   aws.ToString(p.ETag),
+				Size:   aws.ToInt64(p.Size),
+			})
+		}
+	}
+	return parts, nil
+}
+
+func (r *S3) CompleteUpload(ctx context.Context, file, uploadId string, parts []storage.UploadPart) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(p.ETag),
+			PartNumber: aws.Int32(int32(p.Number)),
+		})
+	}
+	_, err = r.instance.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(r.config.Bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
+
+func (r *S3) AbortUpload(ctx context.Context, file, uploadId string) error {
+	key, err := objectKey(file)
+	if err != nil {
+		return err
+	}
+	_, err = r.instance.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(r.config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	return err
 }
@@ -0,0 +1,426 @@
+package storagetest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3Server 兼容S3接口的内存对象存储，用于在测试中代替S3服务，
+// 只支持路径方式访问单个存储桶(需开启 S3Config.PathStyle)，不校验签名，用完需要Close
+type S3Server struct {
+	*httptest.Server
+	bucket  string
+	mu      sync.Mutex
+	objects map[string]*s3Object
+	uploads map[string]*s3Upload
+	nextId  int
+}
+
+type s3Object struct {
+	data     []byte
+	header   http.Header //Content-Type等上传时设置的请求头
+	etag     string
+	crc32    string
+	modified time.Time
+}
+
+type s3Upload struct {
+	key    string
+	header http.Header
+	parts  map[int]*s3Object
+}
+
+func NewS3Server(bucket string) *S3Server {
+	s := &S3Server{
+		bucket:  bucket,
+		objects: make(map[string]*s3Object),
+		uploads: make(map[string]*s3Upload),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *S3Server) handle(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, query)
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		s.deleteObjects(w, r)
+	case key == "":
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.initUpload(w, r, key)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		s.uploadPart(w, r, key, query)
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		s.listParts(w, r, key, query.Get("uploadId"))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		s.completeUpload(w, r, key, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		if _, ok := s.upload(w, r, key, query.Get("uploadId")); ok {
+			delete(s.uploads, query.Get("uploadId"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copy(w, r, key)
+	case r.Method == http.MethodPut:
+		data, err := readBody(r)
+		if err != nil {
+			s3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		obj := newS3Object(data, objectHeader(r.Header))
+		s.objects[key] = obj
+		w.Header().Set("ETag", obj.etag)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			s3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", obj.etag)
+		//范围读取时S3不返回整个对象的校验值
+		if r.Header.Get("Range") == "" {
+			w.Header().Set("X-Amz-Checksum-Crc32", obj.crc32)
+		}
+		http.ServeContent(w, r, "", obj.modified, bytes.NewReader(obj.data))
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// list ListObjectsV2，continuation-token为上一页最后一个key或公共前缀
+func (s *S3Server) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	token := query.Get("continuation-token")
+	if token == "" {
+		token = query.Get("start-after")
+	}
+	maxKeys := 1000
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n > 0 {
+		maxKeys = n
+	}
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	res := s3ListResult{Name: s.bucket, Prefix: prefix, MaxKeys: maxKeys, ContinuationToken: query.Get("continuation-token")}
+	last := ""
+	for _, key := range keys {
+		if token != "" && (key <= token || delimiter != "" && strings.HasSuffix(token, delimiter) && strings.HasPrefix(key, token)) {
+			continue
+		}
+		entry, common := key, false
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				entry, common = key[:len(prefix)+i+len(delimiter)], true
+			}
+		}
+		if entry == last {
+			continue
+		}
+		if res.KeyCount == maxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = last
+			break
+		}
+		res.KeyCount++
+		last = entry
+		if common {
+			res.CommonPrefixes = append(res.CommonPrefixes, s3CommonPrefix{Prefix: entry})
+			continue
+		}
+		obj := s.objects[key]
+		res.Contents = append(res.Contents, s3Content{
+			Key:          key,
+			LastModified: obj.modified.UTC().Format(time.RFC3339),
+			ETag:         obj.etag,
+			Size:         int64(len(obj.data)),
+			StorageClass: "STANDARD",
+		})
+	}
+	writeXML(w, res)
+}
+
+func (s *S3Server) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		s3Error(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	for _, obj := range req.Objects {
+		delete(s.objects, obj.Key)
+	}
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	}{})
+}
+
+func (s *S3Server) copy(w http.ResponseWriter, r *http.Request, key string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		s3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	source = strings.TrimPrefix(strings.TrimPrefix(source, "/"), s.bucket+"/")
+	obj, ok := s.objects[source]
+	if !ok {
+		s3Error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	header := obj.header
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		header = objectHeader(r.Header)
+	}
+	copied := newS3Object(obj.data, header)
+	s.objects[key] = copied
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: copied.etag, LastModified: copied.modified.UTC().Format(time.RFC3339)})
+}
+
+func (s *S3Server) initUpload(w http.ResponseWriter, r *http.Request, key string) {
+	s.nextId++
+	uploadId := strconv.Itoa(s.nextId)
+	s.uploads[uploadId] = &s3Upload{key: key, header: objectHeader(r.Header), parts: make(map[int]*s3Object)}
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Bucket: s.bucket, Key: key, UploadId: uploadId})
+}
+
+func (s *S3Server) uploadPart(w http.ResponseWriter, r *http.Request, key string, query url.Values) {
+	upload, ok := s.upload(w, r, key, query.Get("uploadId"))
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || number < 1 {
+		s3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	data, err := readBody(r)
+	if err != nil {
+		s3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	part := newS3Object(data, nil)
+	upload.parts[number] = part
+	w.Header().Set("ETag", part.etag)
+}
+
+func (s *S3Server) listParts(w http.ResponseWriter, r *http.Request, key, uploadId string) {
+	upload, ok := s.upload(w, r, key, uploadId)
+	if !ok {
+		return
+	}
+	type part struct {
+		PartNumber   int
+		ETag         string
+		Size         int64
+		LastModified string
+	}
+	res := struct {
+		XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+		Bucket      string
+		Key         string
+		UploadId    string
+		IsTruncated bool
+		Parts       []part `xml:"Part"`
+	}{Bucket: s.bucket, Key: key, UploadId: uploadId}
+	for number, p := range upload.parts {
+		res.Parts = append(res.Parts, part{PartNumber: number, ETag: p.etag, Size: int64(len(p.data)), LastModified: p.modified.UTC().Format(time.RFC3339)})
+	}
+	sort.Slice(res.Parts, func(i, j int) bool {
+		return res.Parts[i].PartNumber < res.Parts[j].PartNumber
+	})
+	writeXML(w, res)
+}
+
+func (s *S3Server) completeUpload(w http.ResponseWriter, r *http.Request, key, uploadId string) {
+	upload, ok := s.upload(w, r, key, uploadId)
+	if !ok {
+		return
+	}
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		s3Error(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var data bytes.Buffer
+	for _, p := range req.Parts {
+		part, ok := upload.parts[p.PartNumber]
+		if !ok || part.etag != p.ETag {
+			s3Error(w, r, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		data.Write(part.data)
+	}
+	obj := newS3Object(data.Bytes(), upload.header)
+	s.objects[key] = obj
+	delete(s.uploads, uploadId)
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: s.bucket, Key: key, ETag: obj.etag})
+}
+
+func (s *S3Server) upload(w http.ResponseWriter, r *http.Request, key, uploadId string) (*s3Upload, bool) {
+	upload, ok := s.uploads[uploadId]
+	if !ok || upload.key != key {
+		s3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+		return nil, false
+	}
+	return upload, true
+}
+
+type s3ListResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	KeyCount              int
+	MaxKeys               int
+	IsTruncated           bool
+	ContinuationToken     string           `xml:",omitempty"`
+	NextContinuationToken string           `xml:",omitempty"`
+	Contents              []s3Content      `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3Content struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type s3CommonPrefix struct {
+	Prefix string
+}
+
+// objectHeader 上传请求中需要保存的请求头
+func objectHeader(req http.Header) http.Header {
+	header := make(http.Header)
+	for k, v := range req {
+		switch {
+		case k == "Content-Type", k == "Content-Disposition", k == "Cache-Control", k == "X-Amz-Storage-Class",
+			strings.HasPrefix(k, "X-Amz-Meta-"):
+			header[k] = v
+		}
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "binary/octet-stream")
+	}
+	return header
+}
+
+func newS3Object(data []byte, header http.Header) *s3Object {
+	sum := md5.Sum(data)
+	crc := binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data))
+	return &s3Object{
+		data:     bytes.Clone(data),
+		header:   header,
+		etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+		crc32:    base64.StdEncoding.EncodeToString(crc),
+		modified: time.Now(),
+	}
+}
+
+// readBody 读取请求内容，支持aws-chunked编码
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") && !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var data bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size %q", size)
+		}
+		if n == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err = io.CopyN(&data, br, n); err != nil {
+			return nil, err
+		}
+		if _, err = br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func s3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
@@ -0,0 +1,482 @@
+// Package storagetest 存储一致性测试，同一组用例在各个 storage.FileSystem 实现上运行，保证各存储行为一致
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opdss/common/contracts/storage"
+	storage2 "github.com/opdss/common/storage"
+)
+
+// Run 执行一致性测试，每个子测试调用newFS获取一个空的存储
+func Run(t *testing.T, newFS func(t *testing.T) storage.FileSystem) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, fs storage.FileSystem)
+	}{
+		{"PutGet", testPutGet},
+		{"Missing", testMissing},
+		{"Listing", testListing},
+		{"Pagination", testPagination},
+		{"CopyMove", testCopyMove},
+		{"Delete", testDelete},
+		{"DeleteDirectory", testDeleteDirectory},
+		{"MakeDirectory", testMakeDirectory},
+		{"MimeType", testMimeType},
+		{"Keys", testKeys},
+		{"Multipart", testMultipart},
+		{"Metadata", testMetadata},
+		{"Range", testRange},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newFS(t))
+		})
+	}
+}
+
+func testPutGet(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	//部分存储的修改时间精确到秒
+	start := time.Now().Add(-time.Second)
+	put(t, fs, "a/b.txt", "hello")
+	if b, err := fs.Get(ctx, "a/b.txt"); err != nil || string(b) != "hello" {
+		t.Fatalf("Get = %q, %v", b, err)
+	}
+	rs, err := fs.GetStream(ctx, "a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rs)
+	_ = rs.Close()
+	if err != nil || string(b) != "hello" {
+		t.Fatalf("GetStream = %q, %v", b, err)
+	}
+	if !fs.Exists(ctx, "a/b.txt") || fs.Missing(ctx, "a/b.txt") {
+		t.Fatal("file should exist")
+	}
+	if size, err := fs.Size(ctx, "a/b.txt"); err != nil || size != 5 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+	if mt, err := fs.LastModified(ctx, "a/b.txt"); err != nil || mt.Before(start) || mt.After(time.Now().Add(time.Second)) {
+		t.Fatalf("LastModified = %s, %v", mt, err)
+	}
+	if u := fs.Url("a/b.txt"); !strings.HasSuffix(u, "/a/b.txt") {
+		t.Fatalf("Url = %s", u)
+	}
+	if p := fs.Path("a/b.txt"); p == "" {
+		t.Fatal("Path is empty")
+	}
+	if err = fs.PutStream(ctx, "a/c.txt", strings.NewReader("stream")); err != nil {
+		t.Fatal(err)
+	}
+	get(t, fs, "a/c.txt", "stream")
+	//覆盖
+	put(t, fs, "a/b.txt", "world")
+	get(t, fs, "a/b.txt", "world")
+}
+
+func testMissing(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	if _, err := fs.Get(ctx, "missing.txt"); err == nil {
+		t.Error("Get missing file should fail")
+	}
+	if _, err := fs.GetStream(ctx, "missing.txt"); err == nil {
+		t.Error("GetStream missing file should fail")
+	}
+	if fs.Exists(ctx, "missing.txt") || !fs.Missing(ctx, "missing.txt") {
+		t.Error("file should be missing")
+	}
+	if _, err := fs.Size(ctx, "missing.txt"); err == nil {
+		t.Error("Size of missing file should fail")
+	}
+	if _, err := fs.LastModified(ctx, "missing.txt"); err == nil {
+		t.Error("LastModified of missing file should fail")
+	}
+	if _, err := fs.MimeType(ctx, "missing.txt"); err == nil {
+		t.Error("MimeType of missing file should fail")
+	}
+	//不存在的目录返回空列表
+	if files, err := fs.Files(ctx, "missing"); err != nil || len(files) != 0 {
+		t.Errorf("Files = %v, %v", files, err)
+	}
+	if dirs, err := fs.Directories(ctx, "missing"); err != nil || len(dirs) != 0 {
+		t.Errorf("Directories = %v, %v", dirs, err)
+	}
+	if res, err := fs.ListObjects(ctx, &storage.ListObjectOpts{Directory: "missing"}); err != nil || len(res.List) != 0 || res.HasMore {
+		t.Errorf("ListObjects = %v, %v", res, err)
+	}
+}
+
+func testListing(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	for _, file := range []string{"dir/a.txt", "dir/b.txt", "dir/sub/c.txt", "top.txt"} {
+		put(t, fs, file, file)
+	}
+	files, err := fs.Files(ctx, "dir")
+	equal(t, "Files(dir)", files, err, []string{"a.txt", "b.txt"})
+	files, err = fs.Files(ctx, "")
+	equal(t, "Files()", files, err, []string{"top.txt"})
+	dirs, err := fs.Directories(ctx, "dir")
+	equal(t, "Directories(dir)", dirs, err, []string{"sub/"})
+	dirs, err = fs.Directories(ctx, "/")
+	equal(t, "Directories()", dirs, err, []string{"dir/"})
+
+	res, err := fs.ListObjects(ctx, &storage.ListObjectOpts{Directory: "dir", MaxKeys: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.HasMore {
+		t.Error("ListObjects HasMore should be false")
+	}
+	got := make([]string, 0, len(res.List))
+	for _, item := range res.List {
+		if item.IsDir {
+			got = append(got, item.Name+"/")
+			continue
+		}
+		got = append(got, item.Name)
+		if item.Path != "dir/"+item.Name || item.Size != int64(len(item.Path)) || item.Url == "" || item.LastModified.IsZero() {
+			t.Errorf("ListObjects item = %+v", item)
+		}
+	}
+	equal(t, "ListObjects(dir)", got, nil, []string{"a.txt", "b.txt", "sub/"})
+
+	res, err = fs.ListObjects(ctx, &storage.ListObjectOpts{Directory: "dir", Prefix: "a"})
+	if err != nil || len(res.List) != 1 || res.List[0].Name != "a.txt" {
+		t.Fatalf("ListObjects prefix = %+v, %v", res, err)
+	}
+}
+
+func testPagination(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	want := []string{"f0.txt", "f1.txt", "f2.txt", "f3.txt", "f4.txt"}
+	for _, name := range want {
+		put(t, fs, "page/"+name, name)
+	}
+	var got []string
+	opt := &storage.ListObjectOpts{Directory: "page", MaxKeys: 2}
+	for i := 0; ; i++ {
+		if i > 10 {
+			t.Fatal("too many pages")
+		}
+		res, err := fs.ListObjects(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.List) > 2 {
+			t.Fatalf("page size %d > 2", len(res.List))
+		}
+		for _, item := range res.List {
+			got = append(got, item.Name)
+		}
+		if !res.HasMore {
+			break
+		}
+		if res.NextToken == "" {
+			t.Fatal("NextToken is empty")
+		}
+		opt.NextToken = res.NextToken
+	}
+	equal(t, "pages", got, nil, want)
+}
+
+func testCopyMove(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	put(t, fs, "origin.txt", "content")
+	if err := fs.Copy(ctx, "origin.txt", "copy/target.txt"); err != nil {
+		t.Fatal(err)
+	}
+	get(t, fs, "origin.txt", "content")
+	get(t, fs, "copy/target.txt", "content")
+	if err := fs.Move(ctx, "copy/target.txt", "move/x/y.txt"); err != nil {
+		t.Fatal(err)
+	}
+	get(t, fs, "move/x/y.txt", "content")
+	if fs.Exists(ctx, "copy/target.txt") {
+		t.Error("moved file should not exist")
+	}
+	if err := fs.Copy(ctx, "missing.txt", "target.txt"); err == nil {
+		t.Error("Copy missing file should fail")
+	}
+	if err := fs.Move(ctx, "missing.txt", "target.txt"); err == nil {
+		t.Error("Move missing file should fail")
+	}
+}
+
+func testDelete(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	put(t, fs, "d/1.txt", "1")
+	put(t, fs, "d/2.txt", "2")
+	put(t, fs, "d/3.txt", "3")
+	if err := fs.Delete(ctx, "d/1.txt", "/d/2.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Exists(ctx, "d/1.txt") || fs.Exists(ctx, "d/2.txt") || !fs.Exists(ctx, "d/3.txt") {
+		t.Error("unexpected files after Delete")
+	}
+	//删除不存在的文件不报错
+	if err := fs.Delete(ctx, "d/missing.txt"); err != nil {
+		t.Errorf("Delete missing file: %v", err)
+	}
+}
+
+func testDeleteDirectory(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	for _, file := range []string{"del/a.txt", "del/sub/b.txt", "keep/c.txt", "delx.txt"} {
+		put(t, fs, file, file)
+	}
+	if err := fs.DeleteDirectory(ctx, "del"); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Exists(ctx, "del/a.txt") || fs.Exists(ctx, "del/sub/b.txt") {
+		t.Error("files in deleted directory should not exist")
+	}
+	if !fs.Exists(ctx, "keep/c.txt") || !fs.Exists(ctx, "delx.txt") {
+		t.Error("files outside deleted directory should exist")
+	}
+	dirs, err := fs.Directories(ctx, "")
+	equal(t, "Directories()", dirs, err, []string{"keep/"})
+	//不允许删除根目录
+	if err = fs.DeleteDirectory(ctx, "/"); err == nil {
+		t.Error("DeleteDirectory root should fail")
+	}
+}
+
+func testMakeDirectory(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	if err := fs.MakeDirectory(ctx, "empty/sub"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := fs.Directories(ctx, "empty")
+	equal(t, "Directories(empty)", dirs, err, []string{"sub/"})
+	files, err := fs.Files(ctx, "empty/sub")
+	equal(t, "Files(empty/sub)", files, err, nil)
+}
+
+func testMimeType(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	put(t, fs, "m/a.txt", "hello world")
+	if mt, err := fs.MimeType(ctx, "m/a.txt"); err != nil || !strings.HasPrefix(mt, "text/plain") {
+		t.Errorf("MimeType(txt) = %s, %v", mt, err)
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	if err := fs.Put(ctx, "m/a.png", png); err != nil {
+		t.Fatal(err)
+	}
+	if mt, err := fs.MimeType(ctx, "m/a.png"); err != nil || mt != "image/png" {
+		t.Errorf("MimeType(png) = %s, %v", mt, err)
+	}
+}
+
+func testKeys(t *testing.T, fs storage.FileSystem) {
+	ctx := context.Background()
+	//同一个key在各存储中对应同一个文件
+	put(t, fs, "/k//./a.txt", "normalized")
+	get(t, fs, "k/a.txt", "normalized")
+	get(t, fs, "k/x/../a.txt", "normalized")
+	if err := fs.Put(ctx, "../escape.txt", []byte("x")); !errors.Is(err, storage2.ErrPathEscapesRoot) {
+		t.Errorf("Put escape = %v", err)
+	}
+	if _, err := fs.Get(ctx, "k/../../escape.txt"); !errors.Is(err, storage2.ErrPathEscapesRoot) {
+		t.Errorf("Get escape = %v", err)
+	}
+	if fs.Exists(ctx, "../escape.txt") {
+		t.Error("escaped file should not exist")
+	}
+}
+
+func testMultipart(t *testing.T, fs storage.FileSystem) {
+	u, ok := fs.(storage.MultipartUploader)
+	if !ok {
+		t.Skip("not a MultipartUploader")
+	}
+	ctx := context.Background()
+	uploadId, err := u.InitUpload(ctx, "mp/a.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = u.UploadPart(ctx, "mp/a.txt", uploadId, 2, bytes.NewReader([]byte("world")), 5); err != nil {
+		t.Fatal(err)
+	}
+	parts, err := u.ListParts(ctx, "mp/a.txt", uploadId)
+	if err != nil || len(parts) != 1 || parts[0].Number != 2 || parts[0].Size != 5 {
+		t.Fatalf("ListParts = %+v, %v", parts, err)
+	}
+	part, err := u.UploadPart(ctx, "mp/a.txt", uploadId, 1, bytes.NewReader([]byte("hello ")), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = u.CompleteUpload(ctx, "mp/a.txt", uploadId, append([]storage.UploadPart{part}, parts...)); err != nil {
+		t.Fatal(err)
+	}
+	get(t, fs, "mp/a.txt", "hello world")
+
+	uploadId, err = u.InitUpload(ctx, "mp/b.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = u.UploadPart(ctx, "mp/b.txt", uploadId, 1, bytes.NewReader([]byte("x")), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = u.AbortUpload(ctx, "mp/b.txt", uploadId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = u.ListParts(ctx, "mp/b.txt", uploadId); err == nil {
+		t.Error("ListParts of aborted upload should fail")
+	}
+	if fs.Exists(ctx, "mp/b.txt") {
+		t.Error("aborted file should not exist")
+	}
+}
+
+func testMetadata(t *testing.T, fs storage.FileSystem) {
+	m, ok := fs.(storage.Metadata)
+	if !ok {
+		t.Skip("not a Metadata")
+	}
+	ctx := context.Background()
+	err := m.PutWithOptions(ctx, "meta/a.bin", strings.NewReader("hello"), storage.PutOptions{
+		ContentType:        "text/csv",
+		ContentDisposition: `attachment; filename="a.csv"`,
+		CacheControl:       "max-age=60",
+		Metadata:           map[string]string{"Owner": "alice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(file string) {
+		t.Helper()
+		info, err := m.Stat(ctx, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Key != file || info.Size != 5 || info.ContentType != "text/csv" || info.ContentDisposition != `attachment; filename="a.csv"` ||
+			info.CacheControl != "max-age=60" || info.Metadata["owner"] != "alice" || info.ETag == "" || info.LastModified.IsZero() {
+			t.Errorf("Stat(%s) = %+v", file, info)
+		}
+	}
+	check("meta/a.bin")
+	//复制、移动时保留元信息
+	if err = fs.Copy(ctx, "meta/a.bin", "meta/b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	check("meta/b.bin")
+	if err = fs.Move(ctx, "meta/b.bin", "meta/c.bin"); err != nil {
+		t.Fatal(err)
+	}
+	check("meta/c.bin")
+
+	//普通上传清除之前的元信息
+	put(t, fs, "meta/a.bin", "plain")
+	if info, err := m.Stat(ctx, "meta/a.bin"); err != nil || info.ContentDisposition != "" || len(info.Metadata) != 0 {
+		t.Errorf("Stat after Put = %+v, %v", info, err)
+	}
+	if _, err = m.Stat(ctx, "meta/missing.bin"); err == nil {
+		t.Error("Stat missing file should fail")
+	}
+}
+
+func testRange(t *testing.T, fs storage.FileSystem) {
+	g, ok := fs.(storage.RangeGetter)
+	if !ok {
+		t.Skip("not a RangeGetter")
+	}
+	ctx := context.Background()
+	put(t, fs, "range/a.txt", "0123456789")
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 3, "012"},
+		{4, 2, "45"},
+		{7, -1, "789"},
+		{7, 100, "789"},
+		{0, 0, "0123456789"},
+	}
+	for _, test := range tests {
+		rc, err := g.GetRange(ctx, "range/a.txt", test.offset, test.length)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d): %v", test.offset, test.length, err)
+		}
+		b, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || string(b) != test.want {
+			t.Errorf("GetRange(%d, %d) = %q, %v, want %q", test.offset, test.length, b, err, test.want)
+		}
+	}
+	if _, err := g.GetRange(ctx, "range/a.txt", -1, 1); err == nil {
+		t.Error("GetRange negative offset should fail")
+	}
+	if _, err := g.GetRange(ctx, "range/missing.txt", 0, 1); err == nil {
+		t.Error("GetRange missing file should fail")
+	}
+	for _, offset := range []int64{10, 11} {
+		if _, err := g.GetRange(ctx, "range/a.txt", offset, 1); !errors.Is(err, storage2.ErrInvalidRange) {
+			t.Errorf("GetRange(%d, 1) past EOF error = %v, want ErrInvalidRange", offset, err)
+		}
+	}
+	put(t, fs, "range/empty.txt", "")
+	rc, err := g.GetRange(ctx, "range/empty.txt", 0, -1)
+	if err != nil {
+		t.Fatalf("GetRange empty file: %v", err)
+	}
+	if b, err := io.ReadAll(rc); err != nil || len(b) != 0 {
+		t.Errorf("GetRange empty file = %q, %v, want empty", b, err)
+	}
+	_ = rc.Close()
+
+	r, err := storage2.NewRangeReader(ctx, fs, "range/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	if _, err = r.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2)
+	if _, err = io.ReadFull(r, buf); err != nil || string(buf) != "67" {
+		t.Fatalf("read after seek = %q, %v", buf, err)
+	}
+	if _, err = r.Seek(1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(r); err != nil || string(b) != "123456789" {
+		t.Fatalf("read all after seek = %q, %v", b, err)
+	}
+}
+
+func put(t *testing.T, fs storage.FileSystem, file, content string) {
+	t.Helper()
+	if err := fs.Put(context.Background(), file, []byte(content)); err != nil {
+		t.Fatalf("Put(%s): %v", file, err)
+	}
+}
+
+func get(t *testing.T, fs storage.FileSystem, file, content string) {
+	t.Helper()
+	if b, err := fs.Get(context.Background(), file); err != nil || string(b) != content {
+		t.Fatalf("Get(%s) = %q, %v, want %q", file, b, err, content)
+	}
+}
+
+// equal 比较列表，忽略顺序
+func equal(t *testing.T, name string, got []string, err error, want []string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
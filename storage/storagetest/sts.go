@@ -0,0 +1,208 @@
+package storagetest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STSVendorAWS     = "aws"
+	STSVendorAliyun  = "aliyun"
+	STSVendorTencent = "tencent"
+)
+
+// STSRequest 收到的AssumeRole请求，Policy为解码后的json
+type STSRequest struct {
+	Vendor      string
+	RoleArn     string
+	SessionName string
+	Duration    int
+	Policy      string
+}
+
+// STSServer 同时兼容AWS、阿里云、腾讯云AssumeRole接口的STS服务，用于在测试中代替STS，
+// 只检查签名参数是否存在，不校验签名，用完需要Close
+type STSServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []STSRequest
+	err      string
+}
+
+func NewSTSServer() *STSServer {
+	s := &STSServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Requests 已收到的请求
+func (s *STSServer) Requests() []STSRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]STSRequest(nil), s.requests...)
+}
+
+// SetError 之后的请求都返回该错误码，为空时恢复正常
+func (s *STSServer) SetError(code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = code
+}
+
+func (s *STSServer) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Header.Get("X-TC-Action") == "AssumeRole":
+		s.tencent(w, r)
+	case r.Method == http.MethodGet && r.URL.Query().Get("Action") == "AssumeRole":
+		s.aliyun(w, r)
+	case r.Method == http.MethodPost:
+		s.aws(w, r)
+	default:
+		http.Error(w, "unsupported request", http.StatusBadRequest)
+	}
+}
+
+// record 记录请求，返回临时凭证序号及需要返回的错误码
+func (s *STSServer) record(req STSRequest) (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	return len(s.requests), s.err
+}
+
+func (s *STSServer) aws(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("Action") != "AssumeRole" {
+		http.Error(w, "unsupported request", http.StatusBadRequest)
+		return
+	}
+	duration, _ := strconv.Atoi(r.PostForm.Get("DurationSeconds"))
+	n, code := s.record(STSRequest{
+		Vendor:      STSVendorAWS,
+		RoleArn:     r.PostForm.Get("RoleArn"),
+		SessionName: r.PostForm.Get("RoleSessionName"),
+		Duration:    duration,
+		Policy:      r.PostForm.Get("Policy"),
+	})
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		code = "InvalidClientTokenId"
+	}
+	if code != "" {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(xml.Header))
+		_ = xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"ErrorResponse"`
+			Type    string   `xml:"Error>Type"`
+			Code    string   `xml:"Error>Code"`
+			Message string   `xml:"Error>Message"`
+		}{Type: "Sender", Code: code, Message: code})
+		return
+	}
+	writeXML(w, struct {
+		XMLName         xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse"`
+		AccessKeyId     string   `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+		SecretAccessKey string   `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+		SessionToken    string   `xml:"AssumeRoleResult>Credentials>SessionToken"`
+		Expiration      string   `xml:"AssumeRoleResult>Credentials>Expiration"`
+		RequestId       string   `xml:"ResponseMetadata>RequestId"`
+	}{
+		AccessKeyId:     fmt.Sprintf("ASIA%d", n),
+		SecretAccessKey: fmt.Sprintf("secret%d", n),
+		SessionToken:    fmt.Sprintf("token%d", n),
+		Expiration:      expiration(duration).Format(time.RFC3339),
+		RequestId:       strconv.Itoa(n),
+	})
+}
+
+func (s *STSServer) aliyun(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	duration, _ := strconv.Atoi(query.Get("DurationSeconds"))
+	n, code := s.record(STSRequest{
+		Vendor:      STSVendorAliyun,
+		RoleArn:     query.Get("RoleArn"),
+		SessionName: query.Get("RoleSessionName"),
+		Duration:    duration,
+		Policy:      query.Get("Policy"),
+	})
+	if query.Get("Signature") == "" || query.Get("AccessKeyId") == "" {
+		code = "IncompleteSignature"
+	}
+	if code != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"Code": code, "Message": code, "RequestId": strconv.Itoa(n)})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"RequestId": strconv.Itoa(n),
+		"Credentials": map[string]string{
+			"AccessKeyId":     fmt.Sprintf("STS.%d", n),
+			"AccessKeySecret": fmt.Sprintf("secret%d", n),
+			"SecurityToken":   fmt.Sprintf("token%d", n),
+			"Expiration":      expiration(duration).Format(time.RFC3339),
+		},
+	})
+}
+
+func (s *STSServer) tencent(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RoleArn         string
+		RoleSessionName string
+		DurationSeconds int
+		Policy          string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy, _ := url.QueryUnescape(body.Policy)
+	n, code := s.record(STSRequest{
+		Vendor:      STSVendorTencent,
+		RoleArn:     body.RoleArn,
+		SessionName: body.RoleSessionName,
+		Duration:    body.DurationSeconds,
+		Policy:      policy,
+	})
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 ") || r.Header.Get("X-TC-Region") == "" {
+		code = "AuthFailure.SignatureFailure"
+	}
+	//腾讯云出错时也返回200
+	if code != "" {
+		writeJSON(w, http.StatusOK, map[string]any{"Response": map[string]any{
+			"Error":     map[string]string{"Code": code, "Message": code},
+			"RequestId": strconv.Itoa(n),
+		}})
+		return
+	}
+	expires := expiration(body.DurationSeconds)
+	writeJSON(w, http.StatusOK, map[string]any{"Response": map[string]any{
+		"Credentials": map[string]string{
+			"TmpSecretId":  fmt.Sprintf("AKID%d", n),
+			"TmpSecretKey": fmt.Sprintf("secret%d", n),
+			"Token":        fmt.Sprintf("token%d", n),
+		},
+		"ExpiredTime": expires.Unix(),
+		"Expiration":  expires.Format(time.RFC3339),
+		"RequestId":   strconv.Itoa(n),
+	}})
+}
+
+// expiration 未指定有效期时默认1小时
+func expiration(seconds int) time.Time {
+	if seconds <= 0 {
+		seconds = 3600
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second).UTC().Truncate(time.Second)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/google/uuid"
+	"github.com/opdss/common/contracts/storage"
+	"golang.org/x/sync/singleflight"
+)
+
+const DefaultSTSDuration = time.Hour
+const DefaultSTSSessionName = "storage"
+
+var _ storage.CredentialsProvider = (*STS)(nil)
+
+type STSOption func(s *STS)
+
+// WithSTSEndpoint sts接口地址，默认为各云的官方地址，测试时可使用 storagetest.STSServer
+func WithSTSEndpoint(endpoint string) STSOption {
+	return func(s *STS) {
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithSTSDuration 临时凭证有效期，各云最短15分钟，最长不能超过角色设置的最大会话时间
+func WithSTSDuration(d time.Duration) STSOption {
+	return func(s *STS) {
+		if d > 0 {
+			s.duration = d
+		}
+	}
+}
+
+// WithSTSSessionName 角色会话名称，可在云的操作日志中区分调用方
+func WithSTSSessionName(name string) STSOption {
+	return func(s *STS) {
+		if name != "" {
+			s.sessionName = name
+		}
+	}
+}
+
+// assumeRole 使用只允许上传到prefix目录的策略扮演角色，返回临时凭证及过期时间
+type assumeRole func(ctx context.Context, prefix string) (*storage.Credentials, time.Time, error)
+
+// STS 通过扮演角色(AssumeRole)签发只能上传到指定目录的临时凭证，
+// 按目录缓存，剩余有效期不足1/5时重新签发
+type STS struct {
+	assume      assumeRole
+	endpoint    string
+	duration    time.Duration
+	sessionName string
+	client      *http.Client
+	mu          sync.Mutex
+	cache       map[string]*stsEntry
+	group       singleflight.Group
+}
+
+type stsEntry struct {
+	creds   storage.Credentials
+	refresh time.Time
+}
+
+func newSTS(endpoint string, opts []STSOption) *STS {
+	s := &STS{
+		endpoint:    endpoint,
+		duration:    DefaultSTSDuration,
+		sessionName: DefaultSTSSessionName,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		cache:       make(map[string]*stsEntry),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewS3STS 使用 S3Config 的 RoleArn 调用 AWS STS，只适用于AWS S3
+func NewS3STS(config S3Config, opts ...STSOption) (*STS, error) {
+	if config.AccessKeyId == "" || config.AccessKeySecret == "" || config.RoleArn == "" || config.Bucket == "" {
+		return nil, errors.New("please set configuration")
+	}
+	s := newSTS("", opts)
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(),
+		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(config.AccessKeyId, config.AccessKeySecret, "")),
+		awsConfig.WithRegion(region),
+	)
+	if err != nil {
+		return nil, err
+	}
+	client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if s.endpoint != "" {
+			o.BaseEndpoint = aws.String(s.endpoint)
+		}
+	})
+	//arn:aws:iam::123456789012:role/name，中国区为 arn:aws-cn
+	partition := "aws"
+	if parts := strings.Split(config.RoleArn, ":"); len(parts) > 1 && parts[1] != "" {
+		partition = parts[1]
+	}
+	s.assume = func(ctx context.Context, prefix string) (*storage.Credentials, time.Time, error) {
+		policy, err := json.Marshal(map[string]any{
+			"Version": "2012-10-17",
+			"Statement": []map[string]any{{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:PutObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+				"Resource": []string{"arn:" + partition + ":s3:::" + config.Bucket + "/" + prefix + "*"},
+			}},
+		})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		resp, err := client.AssumeRole(ctx, &sts.AssumeRoleInput{
+			RoleArn:         aws.String(config.RoleArn),
+			RoleSessionName: aws.String(s.sessionName),
+			DurationSeconds: aws.Int32(int32(s.duration.Seconds())),
+			Policy:          aws.String(string(policy)),
+		})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if resp.Credentials == nil {
+			return nil, time.Time{}, errors.New("aws sts: empty credentials")
+		}
+		expires := aws.ToTime(resp.Credentials.Expiration)
+		return &storage.Credentials{
+			AccessKeyId:     aws.ToString(resp.Credentials.AccessKeyId),
+			AccessKeySecret: aws.ToString(resp.Credentials.SecretAccessKey),
+			SecurityToken:   aws.ToString(resp.Credentials.SessionToken),
+			Expiration:      expires.UTC().Format(time.RFC3339),
+		}, expires, nil
+	}
+	return s, nil
+}
+
+// NewOssSTS 使用 OssConfig 的 RoleArn 调用阿里云STS，设置了 RegionId 时使用该地区的接口地址
+func NewOssSTS(config OssConfig, opts ...STSOption) (*STS, error) {
+	if config.AccessKeyId == "" || config.AccessKeySecret == "" || config.RoleArn == "" || config.Bucket == "" {
+		return nil, errors.New("please set configuration")
+	}
+	endpoint := "https://sts.aliyuncs.com"
+	if config.RegionId != "" {
+		endpoint = "https://sts." + config.RegionId + ".aliyuncs.com"
+	}
+	s := newSTS(endpoint, opts)
+	s.assume = func(ctx context.Context, prefix string) (*storage.Credentials, time.Time, error) {
+		policy, err := json.Marshal(map[string]any{
+			"Version": "1",
+			"Statement": []map[string]any{{
+				"Effect": "Allow",
+				"Action": []string{"oss:PutObject", "oss:InitiateMultipartUpload", "oss:UploadPart", "oss:CompleteMultipartUpload",
+					"oss:AbortMultipartUpload", "oss:ListParts"},
+				"Resource": []string{"acs:oss:*:*:" + config.Bucket + "/" + prefix + "*"},
+			}},
+		})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		params := url.Values{
+			"Action":           {"AssumeRole"},
+			"Version":          {"2015-04-01"},
+			"Format":           {"JSON"},
+			"AccessKeyId":      {config.AccessKeyId},
+			"SignatureMethod":  {"HMAC-SHA1"},
+			"SignatureVersion": {"1.0"},
+			"SignatureNonce":   {uuid.New().String()},
+			"Timestamp":        {time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+			"RoleArn":          {config.RoleArn},
+			"RoleSessionName":  {s.sessionName},
+			"DurationSeconds":  {strconv.Itoa(int(s.duration.Seconds()))},
+			"Policy":           {string(policy)},
+		}
+		query := aliyunQuery(params)
+		signature := aliyunSignature(http.MethodGet, query, config.AccessKeySecret)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint+"/?"+query+"&Signature="+percentEncode(signature), nil)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		var res struct {
+			Code        string
+			Message     string
+			Credentials struct {
+				AccessKeyId     string
+				AccessKeySecret string
+				SecurityToken   string
+				Expiration      string
+			}
+		}
+		status, err := s.do(req, &res)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if status != http.StatusOK || res.Code != "" {
+			return nil, time.Time{}, fmt.Errorf("aliyun sts: %d %s %s", status, res.Code, res.Message)
+		}
+		expires, err := time.Parse(time.RFC3339, res.Credentials.Expiration)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		return &storage.Credentials{
+			AccessKeyId:     res.Credentials.AccessKeyId,
+			AccessKeySecret: res.Credentials.AccessKeySecret,
+			SecurityToken:   res.Credentials.SecurityToken,
+			Expiration:      expires.UTC().Format(time.RFC3339),
+		}, expires, nil
+	}
+	return s, nil
+}
+
+// NewCosSTS 使用 CosConfig 的 RoleArn 调用腾讯云STS
+func NewCosSTS(config CosConfig, opts ...STSOption) (*STS, error) {
+	bucket, region := cosBucket(config)
+	i := strings.LastIndex(bucket, "-")
+	if config.AccessKeyId == "" || config.AccessKeySecret == "" || config.RoleArn == "" || region == "" || i < 0 {
+		return nil, errors.New("please set configuration")
+	}
+	//存储桶名称为 <name>-<appid>
+	appId := bucket[i+1:]
+	s := newSTS("https://sts.tencentcloudapi.com", opts)
+	s.assume = func(ctx context.Context, prefix string) (*storage.Credentials, time.Time, error) {
+		policy, err := json.Marshal(map[string]any{
+			"version": "2.0",
+			"statement": []map[string]any{{
+				"effect": "allow",
+				"action": []string{"name/cos:PutObject", "name/cos:PostObject", "name/cos:InitiateMultipartUpload", "name/cos:UploadPart",
+					"name/cos:CompleteMultipartUpload", "name/cos:AbortMultipartUpload", "name/cos:ListParts"},
+				"resource": []string{"qcs::cos:" + region + ":uid/" + appId + ":" + bucket + "/" + prefix + "*"},
+			}},
+		})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		payload, err := json.Marshal(map[string]any{
+			"RoleArn":         config.RoleArn,
+			"RoleSessionName": s.sessionName,
+			"DurationSeconds": int(s.duration.Seconds()),
+			"Policy":          url.QueryEscape(string(policy)),
+		})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/", strings.NewReader(string(payload)))
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		timestamp := time.Now().Unix()
+		contentType := "application/json; charset=utf-8"
+		date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+		signature := tc3Signature(config.AccessKeySecret, "sts", contentType, req.URL.Host, timestamp, payload)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-TC-Action", "AssumeRole")
+		req.Header.Set("X-TC-Version", "2018-08-13")
+		req.Header.Set("X-TC-Region", region)
+		req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+		req.Header.Set("Authorization", "TC3-HMAC-SHA256 Credential="+config.AccessKeyId+"/"+date+"/sts/tc3_request, SignedHeaders=content-type;host, Signature="+signature)
+		var res struct {
+			Response struct {
+				Credentials struct {
+					Token        string
+					TmpSecretId  string
+					TmpSecretKey string
+				}
+				ExpiredTime int64
+				Error       *struct {
+					Code    string
+					Message string
+				}
+			}
+		}
+		status, err := s.do(req, &res)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if e := res.Response.Error; status != http.StatusOK || e != nil {
+			if e == nil {
+				return nil, time.Time{}, fmt.Errorf("tencent sts: %d", status)
+			}
+			return nil, time.Time{}, fmt.Errorf("tencent sts: %s %s", e.Code, e.Message)
+		}
+		expires := time.Unix(res.Response.ExpiredTime, 0)
+		return &storage.Credentials{
+			AccessKeyId:     res.Response.Credentials.TmpSecretId,
+			AccessKeySecret: res.Response.Credentials.TmpSecretKey,
+			SecurityToken:   res.Response.Credentials.Token,
+			Expiration:      expires.UTC().Format(time.RFC3339),
+		}, expires, nil
+	}
+	return s, nil
+}
+
+// Credentials 同一目录的凭证在剩余有效期超过1/5时直接返回缓存，prefix为根目录时返回 ErrInvalidKey
+func (s *STS) Credentials(ctx context.Context, prefix string) (*storage.Credentials, error) {
+	prefix, err := dirKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	//策略中的通配符及变量会扩大授权范围
+	if strings.ContainsAny(prefix, "*?$") {
+		return nil, &fs.PathError{Op: "credentials", Path: prefix, Err: ErrInvalidKey}
+	}
+	s.mu.Lock()
+	e := s.cache[prefix]
+	s.mu.Unlock()
+	if e != nil && time.Now().Before(e.refresh) {
+		creds := e.creds
+		return &creds, nil
+	}
+	v, err, _ := s.group.Do(prefix, func() (interface{}, error) {
+		creds, expires, err := s.assume(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		e := &stsEntry{creds: *creds, refresh: now.Add(expires.Sub(now) * 4 / 5)}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for k, old := range s.cache {
+			if now.After(old.refresh) {
+				delete(s.cache, k)
+			}
+		}
+		s.cache[prefix] = e
+		return e, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	creds := v.(*stsEntry).creds
+	return &creds, nil
+}
+
+// do 发送请求并解析json响应，返回http状态码
+func (s *STS) do(req *http.Request, v any) (int, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("sts response %d: %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// cosBucket 存储桶及地区，配置为空时从api入口 https://<bucket>.cos.<region>.myqcloud.com 中获取
+func cosBucket(config CosConfig) (bucket, region string) {
+	bucket, region = config.Bucket, config.Region
+	if u, err := url.Parse(config.Endpoint); err == nil {
+		parts := strings.Split(u.Hostname(), ".")
+		if len(parts) >= 3 && parts[1] == "cos" {
+			if bucket == "" {
+				bucket = parts[0]
+			}
+			if region == "" {
+				region = parts[2]
+			}
+		}
+	}
+	return bucket, region
+}
+
+// percentEncode 阿里云签名使用的编码，空格编码为%20，~不编码
+func percentEncode(s string) string {
+	return strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(url.QueryEscape(s))
+}
+
+// aliyunQuery 按参数名排序的请求参数
+func aliyunQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = percentEncode(k) + "=" + percentEncode(params.Get(k))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// aliyunSignature 阿里云RPC接口签名 https://help.aliyun.com/document_detail/315526.html
+func aliyunSignature(method, query, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(method + "&" + percentEncode("/") + "&" + percentEncode(query)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// tc3Signature 腾讯云API 3.0 签名，签名的请求头为 content-type 和 host https://cloud.tencent.com/document/api/213/30654
+func tc3Signature(secret, service, contentType, host string, timestamp int64, payload []byte) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	hashed := sha256.Sum256(payload)
+	canonical := "POST\n/\n\ncontent-type:" + contentType + "\nhost:" + host + "\n\ncontent-type;host\n" + hex.EncodeToString(hashed[:])
+	hashed = sha256.Sum256([]byte(canonical))
+	stringToSign := "TC3-HMAC-SHA256\n" + strconv.FormatInt(timestamp, 10) + "\n" + date + "/" + service + "/tc3_request\n" + hex.EncodeToString(hashed[:])
+	key := hmacSHA256([]byte("TC3"+secret), date)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "tc3_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
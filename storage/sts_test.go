@@ -0,0 +1,133 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	storage2 "github.com/opdss/common/storage"
+	"github.com/opdss/common/storage/storagetest"
+)
+
+func TestAliyunSignature(t *testing.T) {
+	//阿里云文档中的示例
+	query := "AccessKeyId=testid&Action=DescribeRegions&Format=XML&SignatureMethod=HMAC-SHA1&SignatureNonce=3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf" +
+		"&SignatureVersion=1.0&Timestamp=2016-02-23T12%3A46%3A24Z&Version=2014-05-26"
+	if sig := storage2.AliyunSignature("GET", query, "testsecret"); sig != "OLeaidS1JvxuMvnyHOwuJ+uX5qY=" {
+		t.Errorf("signature = %s", sig)
+	}
+}
+
+func TestTC3Signature(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		service     string
+		contentType string
+		host        string
+		timestamp   int64
+		payload     string
+		expected    string
+	}{
+		//腾讯云文档 签名方法 v3 中的示例
+		{
+			name:        "cvm",
+			secret:      "Gu5t9xGARNpq86cd98joQYCN3EXAMPLE",
+			service:     "cvm",
+			contentType: "application/json; charset=utf-8",
+			host:        "cvm.tencentcloudapi.com",
+			timestamp:   1551113065,
+			payload:     `{"Limit": 1, "Filters": [{"Values": ["\u672a\u547d\u540d"], "Name": "instance-name"}]}`,
+			expected:    "72e494ea809ad7a8c8f7a4507b9bddcbaa8e581f516e8da2f66e2c5a96525168",
+		},
+	}
+	for _, test := range tests {
+		sig := storage2.TC3Signature(test.secret, test.service, test.contentType, test.host, test.timestamp, []byte(test.payload))
+		if sig != test.expected {
+			t.Errorf("%s signature = %s, want %s", test.name, sig, test.expected)
+		}
+	}
+}
+
+func TestSTS(t *testing.T) {
+	ctx := context.Background()
+	server := storagetest.NewSTSServer()
+	defer server.Close()
+	opts := []storage2.STSOption{storage2.WithSTSEndpoint(server.URL), storage2.WithSTSDuration(20 * time.Minute), storage2.WithSTSSessionName("upload")}
+
+	s3STS, err := storage2.NewS3STS(storage2.S3Config{AccessKeyId: "id", AccessKeySecret: "secret", RoleArn: "arn:aws-cn:iam::123:role/upload", Bucket: "b"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ossSTS, err := storage2.NewOssSTS(storage2.OssConfig{AccessKeyId: "id", AccessKeySecret: "secret", RoleArn: "acs:ram::123:role/upload", Bucket: "b"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosSTS, err := storage2.NewCosSTS(storage2.CosConfig{AccessKeyId: "id", AccessKeySecret: "secret", RoleArn: "qcs::cam::uin/1:roleName/upload",
+		Endpoint: "https://b-1250000000.cos.ap-guangzhou.myqcloud.com"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		sts      *storage2.STS
+		vendor   string
+		resource string
+	}{
+		{"s3", s3STS, storagetest.STSVendorAWS, `"arn:aws-cn:s3:::b/user/1/*"`},
+		{"oss", ossSTS, storagetest.STSVendorAliyun, `"acs:oss:*:*:b/user/1/*"`},
+		{"cos", cosSTS, storagetest.STSVendorTencent, `"qcs::cos:ap-guangzhou:uid/1250000000:b-1250000000/user/1/*"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(server.Requests())
+			creds, err := test.sts.Credentials(ctx, "/user/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expires, err := time.Parse(time.RFC3339, creds.Expiration)
+			if err != nil || creds.AccessKeyId == "" || creds.AccessKeySecret == "" || creds.SecurityToken == "" ||
+				time.Until(expires) < 19*time.Minute {
+				t.Fatalf("Credentials = %+v, %v", creds, err)
+			}
+			requests := server.Requests()[before:]
+			if len(requests) != 1 {
+				t.Fatalf("requests = %d", len(requests))
+			}
+			req := requests[0]
+			if req.Vendor != test.vendor || req.SessionName != "upload" || req.Duration != 1200 || !strings.Contains(req.Policy, test.resource) {
+				t.Fatalf("request = %+v", req)
+			}
+
+			//缓存到剩余有效期不足1/5
+			again, err := test.sts.Credentials(ctx, "user/1/")
+			if err != nil || *again != *creds || len(server.Requests()) != before+1 {
+				t.Fatalf("cached Credentials = %+v, %v", again, err)
+			}
+			if _, err = test.sts.Credentials(ctx, "user/2"); err != nil || len(server.Requests()) != before+2 {
+				t.Fatalf("other prefix: %v", err)
+			}
+			if _, err = test.sts.Credentials(ctx, "user/*"); err == nil {
+				t.Error("wildcard prefix should fail")
+			}
+			if _, err = test.sts.Credentials(ctx, "../x"); err == nil {
+				t.Error("escaping prefix should fail")
+			}
+			//根目录的凭证可以上传到整个存储桶
+			for _, prefix := range []string{"", "/", "."} {
+				if _, err = test.sts.Credentials(ctx, prefix); !errors.Is(err, storage2.ErrInvalidKey) {
+					t.Errorf("root prefix %q error = %v", prefix, err)
+				}
+			}
+		})
+	}
+
+	server.SetError("AccessDenied")
+	for _, test := range tests {
+		if _, err = test.sts.Credentials(ctx, "user/3"); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+			t.Errorf("%s error = %v", test.name, err)
+		}
+	}
+}
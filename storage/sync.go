@@ -0,0 +1,397 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/opdss/common/contracts/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+type SyncCompare string
+
+// SyncCompareSize 大小一致时跳过
+const SyncCompareSize SyncCompare = "size"
+
+// SyncCompareModTime 大小一致且目标文件不早于源文件时跳过
+const SyncCompareModTime SyncCompare = "mtime"
+
+// SyncCompareChecksum 大小和内容sha256一致时跳过，需要读取两边的文件内容
+const SyncCompareChecksum SyncCompare = "checksum"
+
+const SyncActionCopy = "copy"
+const SyncActionSkip = "skip"
+const SyncActionDelete = "delete"
+
+// SyncEvent 同步单个文件的结果，Err不为空时表示失败
+type SyncEvent struct {
+	Action string
+	Key    string
+	Size   int64
+	Err    error
+}
+
+type SyncResult struct {
+	Copied  int   `json:"copied"`
+	Skipped int   `json:"skipped"`
+	Deleted int   `json:"deleted"`
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"` //复制的字节数
+}
+
+type SyncOption func(s *Syncer)
+
+func WithSyncCompare(compare SyncCompare) SyncOption {
+	return func(s *Syncer) {
+		s.compare = compare
+	}
+}
+
+func WithSyncConcurrency(n int) SyncOption {
+	return func(s *Syncer) {
+		if n > 0 {
+			s.concurrency = n
+		}
+	}
+}
+
+// WithSyncDryRun 只输出需要同步的文件，不实际复制、删除
+func WithSyncDryRun(dryRun bool) SyncOption {
+	return func(s *Syncer) {
+		s.dryRun = dryRun
+	}
+}
+
+// WithSyncDelete 删除目标中源不存在的文件和目录
+func WithSyncDelete(del bool) SyncOption {
+	return func(s *Syncer) {
+		s.delete = del
+	}
+}
+
+// WithSyncStateFile 记录已同步的文件，中断后再次同步时跳过，全部成功后删除该文件
+func WithSyncStateFile(path string) SyncOption {
+	return func(s *Syncer) {
+		s.stateFile = path
+	}
+}
+
+// WithSyncNotify 每个文件同步完成后回调，可能被并发调用
+func WithSyncNotify(fn func(ev SyncEvent)) SyncOption {
+	return func(s *Syncer) {
+		s.notify = fn
+	}
+}
+
+// Syncer 通过 ListObjects 分页遍历源存储，把新增或变化的文件复制到目标存储
+type Syncer struct {
+	src         storage.FileSystem
+	dst         storage.FileSystem
+	compare     SyncCompare
+	concurrency int
+	dryRun      bool
+	delete      bool
+	stateFile   string
+	notify      func(ev SyncEvent)
+}
+
+func NewSyncer(src, dst storage.FileSystem, opts ...SyncOption) *Syncer {
+	s := &Syncer{src: src, dst: dst, compare: SyncCompareSize, concurrency: 4}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Sync 同步目录，directory为空时同步全部文件
+// 单个文件失败不影响其他文件，结束后返回第一个错误
+func (s *Syncer) Sync(ctx context.Context, directory string) (*SyncResult, error) {
+	prefix, err := validPath(directory)
+	if err != nil {
+		return nil, err
+	}
+	switch s.compare {
+	case SyncCompareSize, SyncCompareModTime, SyncCompareChecksum:
+	default:
+		return nil, fmt.Errorf("unknown sync compare %q", s.compare)
+	}
+	state, err := openSyncState(s.stateFile, s.dryRun)
+	if err != nil {
+		return nil, err
+	}
+	defer state.close()
+
+	run := &syncRun{Syncer: s, state: state, res: &SyncResult{}}
+	run.g.SetLimit(s.concurrency)
+	err = run.walk(ctx, prefix)
+	_ = run.g.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && run.err != nil {
+		err = fmt.Errorf("%d files failed: %w", run.res.Failed, run.err)
+	}
+	if err == nil {
+		state.remove()
+	}
+	return run.res, err
+}
+
+type syncRun struct {
+	*Syncer
+	state *syncState
+	g     errgroup.Group
+	mu    sync.Mutex
+	res   *SyncResult
+	err   error //第一个失败的文件
+}
+
+// walk 同步一个目录，子目录递归处理
+func (r *syncRun) walk(ctx context.Context, prefix string) error {
+	src, err := listAll(ctx, r.src, prefix)
+	if err != nil {
+		return err
+	}
+	dst, err := listAll(ctx, r.dst, prefix)
+	if err != nil {
+		return err
+	}
+	extraneous := make(map[string]storage.ListObject, len(dst))
+	for _, item := range dst {
+		extraneous[listName(item)] = item
+	}
+	for _, item := range src {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		target, exists := extraneous[listName(item)]
+		delete(extraneous, listName(item))
+		if item.IsDir {
+			if err = r.walk(ctx, prefix+item.Name+"/"); err != nil {
+				return err
+			}
+			continue
+		}
+		item := item
+		r.g.Go(func() error {
+			r.syncFile(ctx, prefix+item.Name, item, target, exists)
+			return nil
+		})
+	}
+	if !r.delete {
+		return nil
+	}
+	for _, item := range extraneous {
+		item := item
+		r.g.Go(func() error {
+			r.deleteExtraneous(ctx, prefix+item.Name, item)
+			return nil
+		})
+	}
+	return nil
+}
+
+func (r *syncRun) syncFile(ctx context.Context, key string, src, dst storage.ListObject, exists bool) {
+	ev := SyncEvent{Action: SyncActionSkip, Key: key, Size: src.Size}
+	if r.state.done(key) {
+		r.record(ev)
+		return
+	}
+	if exists {
+		same, err := r.same(ctx, key, src, dst)
+		if err != nil {
+			ev.Err = err
+			r.record(ev)
+			return
+		}
+		if same {
+			ev.Err = r.state.mark(key)
+			r.record(ev)
+			return
+		}
+	}
+	ev.Action = SyncActionCopy
+	if !r.dryRun {
+		if ev.Err = r.copy(ctx, key); ev.Err == nil {
+			ev.Err = r.state.mark(key)
+		}
+	}
+	r.record(ev)
+}
+
+func (r *syncRun) same(ctx context.Context, key string, src, dst storage.ListObject) (bool, error) {
+	if src.Size != dst.Size {
+		return false, nil
+	}
+	switch r.compare {
+	case SyncCompareModTime:
+		return !dst.LastModified.Before(src.LastModified), nil
+	case SyncCompareChecksum:
+		srcSum, err := checksum(ctx, r.src, key)
+		if err != nil {
+			return false, err
+		}
+		dstSum, err := checksum(ctx, r.dst, key)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(srcSum, dstSum), nil
+	default:
+		return true, nil
+	}
+}
+
+func (r *syncRun) copy(ctx context.Context, key string) error {
+	rc, err := r.src.GetStream(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return r.dst.PutStream(ctx, key, rc)
+}
+
+func (r *syncRun) deleteExtraneous(ctx context.Context, key string, item storage.ListObject) {
+	ev := SyncEvent{Action: SyncActionDelete, Key: key, Size: item.Size}
+	if item.IsDir {
+		ev.Key += "/"
+	}
+	if !r.dryRun {
+		if item.IsDir {
+			ev.Err = r.dst.DeleteDirectory(ctx, key)
+		} else {
+			ev.Err = r.dst.Delete(ctx, key)
+		}
+	}
+	r.record(ev)
+}
+
+func (r *syncRun) record(ev SyncEvent) {
+	r.mu.Lock()
+	switch {
+	case ev.Err != nil:
+		r.res.Failed++
+		if r.err == nil {
+			r.err = fmt.Errorf("%s %s: %w", ev.Action, ev.Key, ev.Err)
+		}
+	case ev.Action == SyncActionCopy:
+		r.res.Copied++
+		r.res.Bytes += ev.Size
+	case ev.Action == SyncActionDelete:
+		r.res.Deleted++
+	default:
+		r.res.Skipped++
+	}
+	r.mu.Unlock()
+	if r.notify != nil {
+		r.notify(ev)
+	}
+}
+
+// listAll 分页列出目录下的全部文件及子目录
+func listAll(ctx context.Context, fs storage.FileSystem, prefix string) ([]storage.ListObject, error) {
+	var list []storage.ListObject
+	opt := &storage.ListObjectOpts{Directory: prefix, MaxKeys: storage.MaxFileNum}
+	for {
+		res, err := fs.ListObjects(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res.List...)
+		if !res.HasMore || res.NextToken == "" {
+			return list, nil
+		}
+		opt.NextToken = res.NextToken
+	}
+}
+
+// listName 目录名称以/结尾，与同名文件区分
+func listName(item storage.ListObject) string {
+	if item.IsDir {
+		return item.Name + "/"
+	}
+	return item.Name
+}
+
+func checksum(ctx context.Context, fs storage.FileSystem, key string) ([]byte, error) {
+	rc, err := fs.GetStream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	h := sha256.New()
+	if _, err = io.Copy(h, rc); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// syncState 已同步文件记录，每行一个文件路径
+type syncState struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+	keys map[string]struct{}
+}
+
+// openSyncState path为空或dryRun时不记录进度
+func openSyncState(path string, dryRun bool) (*syncState, error) {
+	s := &syncState{path: path, keys: make(map[string]struct{})}
+	if path == "" {
+		return s, nil
+	}
+	if b, err := os.ReadFile(path); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(b))
+		for scanner.Scan() {
+			s.keys[scanner.Text()] = struct{}{}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if dryRun {
+		return s, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.f = f
+	return s, nil
+}
+
+func (s *syncState) done(key string) bool {
+	_, ok := s.keys[key]
+	return ok
+}
+
+func (s *syncState) mark(key string) error {
+	if s.f == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.f.WriteString(key + "\n")
+	return err
+}
+
+func (s *syncState) close() {
+	if s.f != nil {
+		_ = s.f.Close()
+	}
+}
+
+func (s *syncState) remove() {
+	if s.f != nil {
+		s.close()
+		_ = os.Remove(s.path)
+	}
+}
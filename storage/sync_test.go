@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncer(t *testing.T) {
+	ctx := context.Background()
+	src, dst := NewMemFS(""), NewMemFS("")
+	_ = src.Put(ctx, "a.txt", []byte("a"))
+	_ = src.Put(ctx, "dir/b.txt", []byte("b"))
+	_ = src.Put(ctx, "dir/sub/c.txt", []byte("c"))
+	_ = dst.Put(ctx, "old.txt", []byte("old"))
+	_ = dst.Put(ctx, "olddir/d.txt", []byte("d"))
+
+	sync := func(want SyncResult, opts ...SyncOption) {
+		t.Helper()
+		res, err := NewSyncer(src, dst, opts...).Sync(ctx, "")
+		if err != nil || *res != want {
+			t.Fatalf("Sync = %+v, %v, want %+v", res, err, want)
+		}
+	}
+	sync(SyncResult{Copied: 3, Deleted: 2, Bytes: 3}, WithSyncDelete(true), WithSyncDryRun(true))
+	if dst.Exists(ctx, "a.txt") || !dst.Exists(ctx, "old.txt") {
+		t.Fatal("dry run changed destination")
+	}
+	sync(SyncResult{Copied: 3, Deleted: 2, Bytes: 3}, WithSyncDelete(true))
+	if b, _ := dst.Get(ctx, "dir/sub/c.txt"); string(b) != "c" || dst.Exists(ctx, "old.txt") || dst.Exists(ctx, "olddir/d.txt") {
+		t.Fatal("destination not synced")
+	}
+	sync(SyncResult{Skipped: 3})
+
+	//大小一致但内容不同时只有checksum能发现
+	_ = src.Put(ctx, "a.txt", []byte("x"))
+	sync(SyncResult{Skipped: 3})
+	sync(SyncResult{Copied: 1, Skipped: 2, Bytes: 1}, WithSyncCompare(SyncCompareChecksum))
+	_ = src.Put(ctx, "a.txt", []byte("y"))
+	sync(SyncResult{Copied: 1, Skipped: 2, Bytes: 1}, WithSyncCompare(SyncCompareModTime))
+
+	//进度文件中的文件不再同步，全部成功后删除进度文件
+	state := filepath.Join(t.TempDir(), "state")
+	_ = os.WriteFile(state, []byte("dir/b.txt\n"), 0644)
+	_ = src.Put(ctx, "dir/b.txt", []byte("bb"))
+	_ = src.Put(ctx, "e.txt", []byte("e"))
+	sync(SyncResult{Copied: 1, Skipped: 3, Bytes: 1}, WithSyncStateFile(state))
+	if b, _ := dst.Get(ctx, "dir/b.txt"); string(b) != "b" {
+		t.Fatalf("dir/b.txt = %q", b)
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Fatalf("state file not removed: %v", err)
+	}
+}
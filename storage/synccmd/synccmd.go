@@ -0,0 +1,69 @@
+// Package synccmd 存储同步命令，在服务的根命令中添加:
+//
+//	root.AddCommand(synccmd.NewCommand())
+//	process.Exec(root)
+//
+// 源和目标存储通过配置文件的 source、destination 或命令行参数设置，如:
+//
+//	app sync --source.driver=local --source.local.root=/data --destination.driver=oss --destination.oss.bucket=backup
+package synccmd
+
+import (
+	"fmt"
+
+	"github.com/opdss/common/process"
+	"github.com/opdss/common/storage"
+	"github.com/spf13/cobra"
+)
+
+type Config struct {
+	Source      storage.Config
+	Destination storage.Config
+	Directory   string `help:"同步的目录，默认同步全部文件" default:""`
+	Compare     string `help:"比较方式,可选[size|mtime|checksum]" default:"size"`
+	Concurrency int    `help:"并发数" default:"4"`
+	DryRun      bool   `help:"只输出需要同步的文件，不实际复制、删除" default:"false"`
+	Delete      bool   `help:"删除目标中源不存在的文件" default:"false"`
+	StateFile   string `help:"进度文件，中断后再次执行时跳过已同步的文件，为空时不记录" default:""`
+}
+
+func NewCommand() *cobra.Command {
+	var conf Config
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "sync files from source storage to destination storage",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := process.Ctx(cmd)
+			defer cancel()
+			src, err := conf.Source.FileSystem()
+			if err != nil {
+				return fmt.Errorf("source: %w", err)
+			}
+			dst, err := conf.Destination.FileSystem()
+			if err != nil {
+				return fmt.Errorf("destination: %w", err)
+			}
+			syncer := storage.NewSyncer(src, dst,
+				storage.WithSyncCompare(storage.SyncCompare(conf.Compare)),
+				storage.WithSyncConcurrency(conf.Concurrency),
+				storage.WithSyncDryRun(conf.DryRun),
+				storage.WithSyncDelete(conf.Delete),
+				storage.WithSyncStateFile(conf.StateFile),
+				storage.WithSyncNotify(func(ev storage.SyncEvent) {
+					if ev.Err != nil {
+						cmd.PrintErrf("%s %s: %s\n", ev.Action, ev.Key, ev.Err)
+					} else if ev.Action != storage.SyncActionSkip {
+						cmd.Printf("%s %s\n", ev.Action, ev.Key)
+					}
+				}),
+			)
+			res, err := syncer.Sync(ctx, conf.Directory)
+			if res != nil {
+				cmd.Printf("copied: %d, skipped: %d, deleted: %d, failed: %d, bytes: %d\n", res.Copied, res.Skipped, res.Deleted, res.Failed, res.Bytes)
+			}
+			return err
+		},
+	}
+	process.Bind(cmd, &conf)
+	return cmd
+}
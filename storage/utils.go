@@ -3,7 +3,11 @@ package storage
 import (
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/opdss/common/contracts/storage"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -38,3 +42,61 @@ func getPageSize(maxKeys int32) int32 {
 	}
 	return storage.DefaultFileNum
 }
+
+// pageObjects 列表按名称排序后按前缀过滤并分页，NextToken为本页最后一项的名称
+func pageObjects(list []storage.ListObject, opt *storage.ListObjectOpts) *storage.ListObjectRes {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	res := &storage.ListObjectRes{
+		List: make([]storage.ListObject, 0),
+	}
+	size := int(getPageSize(opt.MaxKeys))
+	for _, item := range list {
+		if !strings.HasPrefix(item.Name, opt.Prefix) || (opt.NextToken != "" && item.Name <= opt.NextToken) {
+			continue
+		}
+		if len(res.List) == size {
+			res.HasMore = true
+			res.NextToken = res.List[size-1].Name
+			break
+		}
+		res.List = append(res.List, item)
+	}
+	return res
+}
+
+// lowerKeys 自定义元信息的key转为小写，各云存储返回的大小写不一致
+func lowerKeys(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[strings.ToLower(k)] = v
+	}
+	return res
+}
+
+// headerObjectInfo 从响应头中获取对象信息，metaPrefix为自定义元信息请求头前缀
+func headerObjectInfo(key string, header http.Header, metaPrefix, storageClass string) (*storage.ObjectInfo, error) {
+	info := &storage.ObjectInfo{
+		Key:                key,
+		ContentType:        header.Get("Content-Type"),
+		ContentDisposition: header.Get("Content-Disposition"),
+		CacheControl:       header.Get("Cache-Control"),
+		ETag:               strings.Trim(header.Get("ETag"), `"`),
+		StorageClass:       header.Get(storageClass),
+		Metadata:           make(map[string]string),
+	}
+	var err error
+	if info.Size, err = strconv.ParseInt(header.Get("Content-Length"), 10, 64); err != nil {
+		return nil, err
+	}
+	if info.LastModified, err = http.ParseTime(header.Get("Last-Modified")); err != nil {
+		return nil, err
+	}
+	for k := range header {
+		if name, ok := strings.CutPrefix(k, http.CanonicalHeaderKey(metaPrefix)); ok {
+			info.Metadata[strings.ToLower(name)] = header.Get(k)
+		}
+	}
+	return info, nil
+}